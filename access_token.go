@@ -0,0 +1,147 @@
+package traefik_github_oauth_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/dghubble/sling"
+)
+
+const (
+	// accessTokenCacheTTL how long an access token that does not expire is kept in memory before the server is asked again.
+	accessTokenCacheTTL = 5 * time.Minute
+	// accessTokenRefreshMargin the refresh margin of the server, a cached token is dropped when the server would refresh it.
+	accessTokenRefreshMargin = time.Minute
+	// accessTokenCacheSize the number of cached access tokens above which the cache is pruned.
+	accessTokenCacheSize = 10000
+)
+
+// forwardAccessToken sets the provider access token of the user on the access token header forwarded upstream.
+// Only the server can open the sealed cookie, the middleware asks it for the access token, which it refreshes when it expires.
+func (p *TraefikGithubOauthMiddleware) forwardAccessToken(rw http.ResponseWriter, req *http.Request, user *jwt.PayloadUser) {
+	if len(p.accessTokenHeader) == 0 {
+		return
+	}
+	sealedAccessToken, err := readChunkedCookie(req, constant.COOKIE_NAME_ACCESS_TOKEN)
+	if err != nil {
+		return
+	}
+	accessToken, err := p.getAccessToken(req, sealedAccessToken)
+	if err != nil {
+		// the upstream gets the request without the access token
+		p.logger.Warning("forwardAccessToken: getAccessToken", newRequestLogEntry(req, user, err))
+		return
+	}
+	if accessToken.UserID != user.Id {
+		p.logger.Warning("forwardAccessToken: the access token belongs to another user", newRequestLogEntry(req, user, nil))
+		return
+	}
+	if 0 < len(accessToken.SealedAccessToken) {
+		setAccessTokenCookie(rw, req, accessToken.SealedAccessToken)
+	}
+	req.Header.Set(p.accessTokenHeader, accessToken.AccessToken)
+}
+
+// getAccessToken returns the access token sealed in a cookie, from the cache or from the server.
+func (p *TraefikGithubOauthMiddleware) getAccessToken(parentReq *http.Request, sealedAccessToken string) (*model.ResponseAccessToken, error) {
+	if accessToken, found := p.accessTokens.get(sealedAccessToken); found {
+		return accessToken, nil
+	}
+	req := sling.New().Client(p.httpClient).Base(p.apiBaseUrl).Post(constant.ROUTER_GROUP_PATH_OAUTH + "/" + constant.ROUTER_PATH_OAUTH_TOKEN)
+	if 0 < len(p.apiSecretKey) {
+		req.Set(constant.HTTP_HEADER_AUTHORIZATION, fmt.Sprintf("%s %s", constant.AUTHORIZATION_PREFIX_TOKEN, p.apiSecretKey))
+	}
+	setCorrelationHeaders(req, parentReq)
+	reqBody := model.RequestAccessToken{
+		SealedAccessToken: sealedAccessToken,
+	}
+	var respBody model.ResponseAccessToken
+	var errRespBody model.ResponseError
+	start := time.Now()
+	_, err := req.BodyJSON(reqBody).Receive(&respBody, &errRespBody)
+	p.logServerCall(parentReq, constant.ROUTER_PATH_OAUTH_TOKEN, start, err)
+	if err != nil {
+		return nil, err
+	}
+	if 0 < len(errRespBody.Message) {
+		return nil, fmt.Errorf("rpc failed, message: %s", errRespBody.Message)
+	}
+
+	expiresAt := time.Now().Add(accessTokenCacheTTL)
+	if 0 < respBody.ExpiresAt {
+		expiresAt = time.Unix(respBody.ExpiresAt, 0).Add(-accessTokenRefreshMargin)
+	}
+	p.accessTokens.set(sealedAccessToken, &respBody, expiresAt)
+	if 0 < len(respBody.SealedAccessToken) {
+		// the refreshed cookie is served from the cache too, there is nothing left to replace
+		refreshed := respBody
+		refreshed.SealedAccessToken = ""
+		p.accessTokens.set(respBody.SealedAccessToken, &refreshed, expiresAt)
+	}
+	return &respBody, nil
+}
+
+func setAccessTokenCookie(rw http.ResponseWriter, req *http.Request, sealedAccessToken string) {
+	setChunkedCookie(rw, req, &http.Cookie{
+		Name:     constant.COOKIE_NAME_ACCESS_TOKEN,
+		Value:    sealedAccessToken,
+		HttpOnly: true,
+	})
+}
+
+// accessTokenCache the access tokens by sealed cookie value, until they expire or need a refresh.
+type accessTokenCache struct {
+	mu      sync.Mutex
+	entries map[string]accessTokenCacheEntry
+	now     func() time.Time
+}
+
+type accessTokenCacheEntry struct {
+	accessToken *model.ResponseAccessToken
+	expiresAt   time.Time
+}
+
+func newAccessTokenCache() *accessTokenCache {
+	return &accessTokenCache{
+		entries: make(map[string]accessTokenCacheEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *accessTokenCache) get(key string) (*model.ResponseAccessToken, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.entries[key]
+	if !found || !c.now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.accessToken, true
+}
+
+func (c *accessTokenCache) set(key string, accessToken *model.ResponseAccessToken, expiresAt time.Time) {
+	now := c.now()
+	if !now.Before(expiresAt) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if accessTokenCacheSize <= len(c.entries) {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if accessTokenCacheSize <= len(c.entries) {
+			c.entries = make(map[string]accessTokenCacheEntry)
+		}
+	}
+	c.entries[key] = accessTokenCacheEntry{
+		accessToken: accessToken,
+		expiresAt:   expiresAt,
+	}
+}
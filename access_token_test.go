@@ -0,0 +1,117 @@
+package traefik_github_oauth_plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessTokenCache_Expiry(t *testing.T) {
+	// setup
+	now := time.Unix(1700000000, 0)
+	c := newAccessTokenCache()
+	c.now = func() time.Time { return now }
+	accessToken := &model.ResponseAccessToken{AccessToken: "gho_token"}
+	c.set("sealed", accessToken, now.Add(time.Minute))
+	c.set("expired", accessToken, now)
+
+	// execution
+	cached, found := c.get("sealed")
+	_, foundExpired := c.get("expired")
+	_, foundUnknown := c.get("unknown")
+	now = now.Add(time.Minute)
+	_, foundLater := c.get("sealed")
+
+	// assertion
+	assert.True(t, found)
+	assert.Equal(t, accessToken, cached)
+	assert.False(t, foundExpired)
+	assert.False(t, foundUnknown)
+	assert.False(t, foundLater)
+	assert.Len(t, c.entries, 1)
+}
+
+func TestAccessTokenCache_Prune(t *testing.T) {
+	// setup
+	now := time.Unix(1700000000, 0)
+	c := newAccessTokenCache()
+	c.now = func() time.Time { return now }
+	accessToken := &model.ResponseAccessToken{AccessToken: "gho_token"}
+	for i := 0; i < accessTokenCacheSize; i++ {
+		expiresAt := now.Add(time.Hour)
+		if i%2 == 0 {
+			expiresAt = now.Add(time.Minute)
+		}
+		c.set(fmt.Sprintf("sealed-%d", i), accessToken, expiresAt)
+	}
+	now = now.Add(time.Minute)
+
+	// execution
+	c.set("pruned", accessToken, now.Add(time.Hour))
+	pruned := len(c.entries)
+	for i := 0; len(c.entries) < accessTokenCacheSize; i++ {
+		c.set(fmt.Sprintf("refill-%d", i), accessToken, now.Add(time.Hour))
+	}
+	c.set("cleared", accessToken, now.Add(time.Hour))
+
+	// assertion
+	assert.Equal(t, accessTokenCacheSize/2+1, pruned)
+	assert.Len(t, c.entries, 1)
+	_, found := c.get("cleared")
+	assert.True(t, found)
+}
+
+func TestForwardAccessToken_RefreshFromNestedPath(t *testing.T) {
+	// setup
+	refreshes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, constant.ROUTER_PATH_REVOCATIONS) {
+			rw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		refreshes++
+		var body model.RequestAccessToken
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(model.ResponseAccessToken{
+			AccessToken:       "ghu_refreshed",
+			UserID:            testUser.Id,
+			SealedAccessToken: body.SealedAccessToken + "-refreshed",
+		})
+	}))
+	defer server.Close()
+	config, handler, upstream := newTestMiddleware(t, func(config *Config) {
+		config.ApiBaseUrl = server.URL
+		config.AccessTokenHeader = "X-Forwarded-Access-Token"
+	})
+
+	// execution
+	req := newTestRequest(t, config, "/nested/dir/page", testUser)
+	req.AddCookie(&http.Cookie{Name: constant.COOKIE_NAME_ACCESS_TOKEN, Value: "sealed"})
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	refreshed := rw.Result().Cookies()
+	// the browser sends the refreshed cookie to the other paths
+	req = newTestRequest(t, config, "/other", testUser)
+	req.AddCookie(refreshed[0])
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	// assertion
+	assert.Len(t, refreshed, 1)
+	assert.Equal(t, constant.COOKIE_NAME_ACCESS_TOKEN, refreshed[0].Name)
+	assert.Equal(t, "sealed-refreshed", refreshed[0].Value)
+	assert.Equal(t, "/", refreshed[0].Path)
+	assert.Equal(t, "ghu_refreshed", upstream.req.Header.Get("X-Forwarded-Access-Token"))
+	// the refreshed cookie is served from the cache, the single-use refresh token is not spent again
+	assert.Equal(t, 1, refreshes)
+	assert.Empty(t, rw.Result().Cookies())
+}
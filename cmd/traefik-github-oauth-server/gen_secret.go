@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// genSecret prints a random secret for API_SECRET_KEY, SESSION_SECRET_KEY and the like,
+// or a PEM encoded private key for SESSION_SIGNING_KEY_FILE.
+func genSecret(args []string) int {
+	fs := newFlagSet("gen-secret", "gen-secret [-length <bytes>] [-signing-key ec|rsa|ed25519]")
+	length := fs.Int("length", 32, "the number of random bytes of the secret")
+	signingKey := fs.String("signing-key", "", "print a private key of the algorithm instead of a secret: ec, rsa or ed25519")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if 0 < len(*signingKey) {
+		pemKey, err := generatePEMSigningKey(*signingKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Print(pemKey)
+		return 0
+	}
+
+	if *length < 16 {
+		fmt.Fprintln(os.Stderr, "the length must be at least 16 bytes")
+		return 2
+	}
+	b := make([]byte, *length)
+	if _, err := rand.Read(b); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(base64.RawURLEncoding.EncodeToString(b))
+	return 0
+}
+
+func generatePEMSigningKey(algorithm string) (string, error) {
+	var key crypto.Signer
+	var err error
+	switch algorithm {
+	case "ec":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("unsupported signing key algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
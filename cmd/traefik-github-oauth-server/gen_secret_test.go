@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		length int
+		stderr string
+	}{
+		{name: "default", args: nil, code: 0, length: 32},
+		{name: "length", args: []string{"-length", "48"}, code: 0, length: 48},
+		{name: "too short", args: []string{"-length", "8"}, code: 2, stderr: "at least 16 bytes"},
+		{name: "unknown flag", args: []string{"-unknown"}, code: 2, stderr: "Usage: traefik-github-oauth-server gen-secret"},
+		{name: "help", args: []string{"-h"}, code: 0, stderr: "Usage: traefik-github-oauth-server gen-secret"},
+		{name: "unsupported signing key", args: []string{"-signing-key", "dsa"}, code: 1, stderr: "unsupported signing key algorithm: dsa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// execution
+			code, stdout, stderr := runCommand(t, genSecret, tt.args, "")
+
+			// assertion
+			assert.Equal(t, tt.code, code)
+			assert.Contains(t, stderr, tt.stderr)
+			if 0 < tt.length {
+				secret, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(stdout))
+				assert.NoError(t, err)
+				assert.Len(t, secret, tt.length)
+			}
+		})
+	}
+}
+
+func TestGenSecret_SigningKey(t *testing.T) {
+	for _, algorithm := range []string{"ec", "rsa", "ed25519"} {
+		t.Run(algorithm, func(t *testing.T) {
+			// execution
+			code, stdout, _ := runCommand(t, genSecret, []string{"-signing-key", algorithm}, "")
+
+			// assertion
+			assert.Equal(t, 0, code)
+			keyFile := filepath.Join(t.TempDir(), "key.pem")
+			assert.NoError(t, os.WriteFile(keyFile, []byte(stdout), 0o600))
+			_, err := LoadSigningKey(keyFile)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	. "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+)
+
+// healthcheck probes the health endpoint of the server, exiting 0 when it answers 200.
+// The image has no curl or wget, so the binary probes itself for the Docker HEALTHCHECK.
+// The server address and TLS are the ones of the config of serve, its file, env vars and the flags after --.
+func healthcheck(args []string) int {
+	fs := newFlagSet("healthcheck", "healthcheck [-url <url>] [-socket <path>] [-insecure] [-timeout <duration>] [-- <serve flags>]")
+	url := fs.String("url", "", "the url of the health endpoint, defaults to the local SERVER_ADDRESS")
+	socket := fs.String("socket", "", "the Unix domain socket to connect to instead of the host of the url, defaults to the one of SERVER_ADDRESS")
+	insecure := fs.Bool("insecure", false, "skip the verification of the server certificate, the default for the local probe of a TLS server")
+	timeout := fs.Duration("timeout", 5*time.Second, "the timeout of the probe")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	config, err := NewConfigLoader(fs.Args()).Load()
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %s\n", err)
+		return 1
+	}
+	setFlags := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	tlsEnabled := 0 < len(config.TLSCertFile)
+	if !setFlags["url"] {
+		*url = defaultHealthURL(config.ServerAddress, tlsEnabled)
+	}
+	if !setFlags["socket"] {
+		*socket, _ = UnixSocketPath(config.ServerAddress)
+	}
+	if !setFlags["insecure"] {
+		*insecure = tlsEnabled
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if *insecure {
+		//nolint:gosec // the local server certificate is not issued for the loopback address
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	if 0 < len(*socket) {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", *socket)
+		}
+	}
+	resp, err := (&http.Client{Transport: transport, Timeout: *timeout}).Get(*url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unhealthy: %s\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "unhealthy: %s\n", resp.Status)
+		return 1
+	}
+	return 0
+}
+
+// defaultHealthURL the health endpoint on the loopback interface at the port of the server address,
+// the host does not matter for a Unix domain socket.
+func defaultHealthURL(serverAddress string, tlsEnabled bool) string {
+	scheme := "http"
+	if tlsEnabled {
+		scheme = "https"
+	}
+	// the default SERVER_ADDRESS
+	port := "80"
+	if _, isSocket := UnixSocketPath(serverAddress); !isSocket {
+		if _, p, err := net.SplitHostPort(serverAddress); err == nil && 0 < len(p) {
+			port = p
+		}
+	}
+	return fmt.Sprintf("%s://%s/%s", scheme, net.JoinHostPort("127.0.0.1", port), constant.ROUTER_PATH_OAUTH_HEALTH)
+}
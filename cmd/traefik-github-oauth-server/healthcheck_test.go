@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthcheck(t *testing.T) {
+	// setup
+	healthy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+	tests := []struct {
+		name   string
+		env    map[string]string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{name: "healthy", env: requiredEnv, args: []string{"-url", healthy.URL + "/health/live"}, code: 0},
+		{name: "unhealthy", env: requiredEnv, args: []string{"-url", unhealthy.URL + "/health/live"}, code: 1, stderr: "unhealthy: 503 Service Unavailable"},
+		{name: "unreachable", env: requiredEnv, args: []string{"-url", "http://127.0.0.1:0/health/live", "-timeout", "1s"}, code: 1, stderr: "unhealthy:"},
+		{name: "invalid config", env: map[string]string{"API_BASE_URL": ""}, args: []string{"-url", healthy.URL}, code: 1, stderr: "unhealthy: "},
+		{name: "serve flags", env: requiredEnv, args: []string{"-url", healthy.URL, "--", "--server-address", ":8080"}, code: 0},
+		{name: "unknown flag", env: requiredEnv, args: []string{"-unknown"}, code: 2, stderr: "Usage: traefik-github-oauth-server healthcheck"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			setEnv(t, tt.env)
+
+			// execution
+			code, stdout, stderr := runCommand(t, healthcheck, tt.args, "")
+
+			// assertion
+			assert.Equal(t, tt.code, code)
+			assert.Empty(t, stdout)
+			assert.Contains(t, stderr, tt.stderr)
+		})
+	}
+}
+
+func TestDefaultHealthURL(t *testing.T) {
+	tests := []struct {
+		serverAddress string
+		tlsEnabled    bool
+		url           string
+	}{
+		{serverAddress: ":80", url: "http://127.0.0.1:80/health"},
+		{serverAddress: "0.0.0.0:8080", url: "http://127.0.0.1:8080/health"},
+		{serverAddress: ":8443", tlsEnabled: true, url: "https://127.0.0.1:8443/health"},
+		{serverAddress: "unix:/run/oauth.sock", url: "http://127.0.0.1:80/health"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverAddress, func(t *testing.T) {
+			// execution & assertion
+			assert.Equal(t, tt.url, defaultHealthURL(tt.serverAddress, tt.tlsEnabled))
+		})
+	}
+}
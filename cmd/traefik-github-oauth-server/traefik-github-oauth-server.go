@@ -1,12 +1,94 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
 	. "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/router"
 )
 
+// set by goreleaser
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+	builtBy = ""
+)
+
+const usage = `Usage: traefik-github-oauth-server [command] [flags]
+
+Commands:
+  serve            run the server, the default command
+  validate-config  load and validate the config, then exit
+  gen-secret       print a random secret, or a private key signing the session tokens
+  verify-token     decode a session token and verify its signature
+  healthcheck      probe the health endpoint of a running server, e.g. as a Docker HEALTHCHECK
+
+Run traefik-github-oauth-server <command> -h for the flags of a command.
+`
+
+// commands the subcommands, each returns the exit code of the process.
+var commands = map[string]func(args []string) int{
+	"serve":           serve,
+	"validate-config": validateConfig,
+	"gen-secret":      genSecret,
+	"verify-token":    verifyToken,
+	"healthcheck":     healthcheck,
+}
+
 func main() {
-	app := NewDefaultApp()
+	args := os.Args[1:]
+	// without a command the flags are the ones of serve, as before the subcommands
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		os.Exit(serve(args))
+	}
+	command, found := commands[args[0]]
+	if !found {
+		if args[0] != "help" {
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", args[0])
+		}
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	os.Exit(command(args[1:]))
+}
+
+func serve(args []string) int {
+	app, err := NewDefaultApp(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	app.BuildInfo = NewBuildInfo(version, commit, date, builtBy)
 	router.RegisterRoutes(app)
 	app.Run()
+	return 0
+}
+
+// newFlagSet creates the flag set of a command, printing its usage to stderr.
+func newFlagSet(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: traefik-github-oauth-server %s\n\nFlags:\n", synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the flags of a command, returning the exit code when the command must stop.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0, false
+		}
+		return 2, false
+	}
+	return 0, true
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// requiredEnv the env vars of a valid config.
+var requiredEnv = map[string]string{
+	"API_BASE_URL":               "http://oauth.example.com",
+	"GITHUB_OAUTH_CLIENT_ID":     "client-id",
+	"GITHUB_OAUTH_CLIENT_SECRET": "client-secret",
+}
+
+// mergeEnv the env vars of base overridden by the ones of env.
+func mergeEnv(base, env map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(env))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range env {
+		merged[key] = value
+	}
+	return merged
+}
+
+// setEnv sets the env vars for the test.
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+}
+
+// runCommand runs the command with stdin, capturing its exit code, stdout and stderr.
+func runCommand(t *testing.T, command func(args []string) int, args []string, stdin string) (int, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	stdinFile := filepath.Join(dir, "stdin")
+	assert.NoError(t, os.WriteFile(stdinFile, []byte(stdin), 0o600))
+	files := make(map[string]*os.File)
+	for _, name := range []string{"stdin", "stdout", "stderr"} {
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_RDWR|os.O_CREATE, 0o600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer f.Close()
+		files[name] = f
+	}
+	origStdin, origStdout, origStderr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = files["stdin"], files["stdout"], files["stderr"]
+	defer func() {
+		os.Stdin, os.Stdout, os.Stderr = origStdin, origStdout, origStderr
+	}()
+
+	code := command(args)
+
+	stdout, _ := os.ReadFile(filepath.Join(dir, "stdout"))
+	stderr, _ := os.ReadFile(filepath.Join(dir, "stderr"))
+	return code, string(stdout), string(stderr)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	. "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+)
+
+// validateConfig loads the config like serve, and builds the OAuth clients and the signing key it refers to,
+// so missing or broken CA bundles and key files are reported before deploying.
+func validateConfig(args []string) int {
+	config, err := NewConfigLoader(args).Load()
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := NewOAuthClients(config); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid OAuth clients: %s\n", err)
+		return 1
+	}
+	if 0 < len(config.SessionSigningKeyFile) {
+		if _, err := LoadSigningKey(config.SessionSigningKeyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid session signing key: %s\n", err)
+			return 1
+		}
+	}
+	fmt.Println("config is valid")
+	return 0
+}
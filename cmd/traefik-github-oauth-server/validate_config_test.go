@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	_ = os.WriteFile(keyFile, []byte("not a key"), 0o600)
+	tests := []struct {
+		name   string
+		env    map[string]string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{name: "valid", env: requiredEnv, code: 0, stdout: "config is valid"},
+		{name: "flags", env: requiredEnv, args: []string{"--server-address", ":8080"}, code: 0, stdout: "config is valid"},
+		{name: "missing client", env: map[string]string{"API_BASE_URL": "http://oauth.example.com"}, code: 1, stderr: "GITHUB_OAUTH_CLIENT_ID"},
+		{name: "invalid signing key", env: mergeEnv(requiredEnv, map[string]string{"SESSION_SIGNING_KEY_FILE": keyFile}), code: 1, stderr: "invalid session signing key"},
+		{name: "help", args: []string{"-h"}, code: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			setEnv(t, mergeEnv(map[string]string{"API_BASE_URL": "", "GITHUB_OAUTH_CLIENT_ID": "", "GITHUB_OAUTH_CLIENT_SECRET": ""}, tt.env))
+
+			// execution
+			code, stdout, stderr := runCommand(t, validateConfig, tt.args, "")
+
+			// assertion
+			assert.Equal(t, tt.code, code)
+			assert.Contains(t, stdout, tt.stdout)
+			assert.Contains(t, stderr, tt.stderr)
+		})
+	}
+}
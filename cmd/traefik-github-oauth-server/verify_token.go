@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwks"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	gojwt "github.com/golang-jwt/jwt/v4"
+)
+
+// verifyToken prints the header and the claims of a session token, then verifies its signature
+// with the secret of the HS256 tokens, a JWKS or a PEM key.
+func verifyToken(args []string) int {
+	fs := newFlagSet("verify-token", "verify-token [-secret <key>] [-jwks <url> | -key <file>] <token | ->")
+	secret := fs.String("secret", "", "the secret key of the tokens signed with HS256 by older middlewares")
+	jwksURL := fs.String("jwks", "", "the url of the JWKS of the server, e.g. http://127.0.0.1/.well-known/jwks.json")
+	keyFile := fs.String("key", "", "a PEM encoded public or private key verifying the token")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 || (0 < len(*jwksURL) && 0 < len(*keyFile)) {
+		fs.Usage()
+		return 2
+	}
+
+	tokenString, err := readToken(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	token, _, err := gojwt.NewParser().ParseUnverified(tokenString, gojwt.MapClaims{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to decode the token: %s\n", err)
+		return 1
+	}
+	printJSON("header", token.Header)
+	printJSON("claims", token.Claims)
+	// an empty secret would verify the tokens forged with an empty secret too
+	if _, signedBySecret := token.Method.(*gojwt.SigningMethodHMAC); signedBySecret && len(*secret) == 0 {
+		fmt.Fprintf(os.Stderr, "the token is signed with %s, its secret key is required: -secret <key>\n", token.Method.Alg())
+		return 2
+	}
+
+	var keys jwt.KeySource
+	switch {
+	case 0 < len(*jwksURL):
+		keys = jwks.NewCache(*jwksURL, &http.Client{Timeout: 10 * time.Second})
+	case 0 < len(*keyFile):
+		publicKey, err := loadPublicKey(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		keys = staticKeySource{publicKey: publicKey}
+	}
+	if _, err := jwt.ParseTokenStringWithKeys(context.Background(), tokenString, *secret, keys); err != nil {
+		fmt.Printf("invalid: %s\n", err)
+		return 1
+	}
+	fmt.Println("valid")
+	return 0
+}
+
+// readToken reads the token from stdin for "-", keeping it out of the shell history.
+func readToken(arg string) (string, error) {
+	if arg != "-" {
+		return strings.TrimSpace(arg), nil
+	}
+	b, err := io.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func printJSON(name string, v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Printf("%s: %s\n", name, b)
+}
+
+// loadPublicKey loads a PEM encoded public key, or the public key of a private key.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in %s", path)
+	}
+	if block.Type == "PUBLIC KEY" {
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	signer, err := LoadSigningKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+// staticKeySource verifies every token with the same public key.
+type staticKeySource struct {
+	publicKey crypto.PublicKey
+}
+
+func (s staticKeySource) Key(context.Context, string) (crypto.PublicKey, error) {
+	return s.publicKey, nil
+}
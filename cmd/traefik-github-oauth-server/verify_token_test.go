@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyToken(t *testing.T) {
+	// setup
+	user := &jwt.PayloadUser{Id: "1", Login: "alice"}
+	hs256Token, _ := jwt.GenerateUserJwtTokenString(user, "secret")
+	dir := t.TempDir()
+	keyFile, otherKeyFile := filepath.Join(dir, "key.pem"), filepath.Join(dir, "other.pem")
+	for _, file := range []string{keyFile, otherKeyFile} {
+		pemKey, _ := generatePEMSigningKey("ec")
+		_ = os.WriteFile(file, []byte(pemKey), 0o600)
+	}
+	signingKey, _ := LoadSigningKey(keyFile)
+	es256Token, _ := jwt.SignSessionTokenString(user, signingKey, "kid", "default", time.Hour)
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{name: "hs256", args: []string{"-secret", "secret", hs256Token}, code: 0, stdout: "valid"},
+		{name: "hs256 from stdin", args: []string{"-secret", "secret", "-"}, stdin: hs256Token + "\n", code: 0, stdout: "valid"},
+		{name: "hs256 wrong secret", args: []string{"-secret", "other", hs256Token}, code: 1, stdout: "invalid: signature is invalid"},
+		{name: "hs256 without secret", args: []string{hs256Token}, code: 2, stderr: "the token is signed with HS256, its secret key is required"},
+		{name: "es256", args: []string{"-key", keyFile, es256Token}, code: 0, stdout: "valid"},
+		{name: "es256 other key", args: []string{"-key", otherKeyFile, es256Token}, code: 1, stdout: "invalid:"},
+		{name: "es256 without key", args: []string{es256Token}, code: 1, stdout: "invalid: "},
+		{name: "missing key file", args: []string{"-key", filepath.Join(dir, "missing.pem"), es256Token}, code: 1, stderr: "no such file"},
+		{name: "malformed token", args: []string{"-secret", "secret", "not-a-token"}, code: 1, stderr: "failed to decode the token"},
+		{name: "no token", args: []string{"-secret", "secret"}, code: 2, stderr: "Usage: traefik-github-oauth-server verify-token"},
+		{name: "jwks and key", args: []string{"-jwks", "http://127.0.0.1/.well-known/jwks.json", "-key", keyFile, es256Token}, code: 2, stderr: "Usage:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// execution
+			code, stdout, stderr := runCommand(t, verifyToken, tt.args, tt.stdin)
+
+			// assertion
+			assert.Equal(t, tt.code, code)
+			assert.Contains(t, stdout, tt.stdout)
+			assert.Contains(t, stderr, tt.stderr)
+			if tt.code == 0 {
+				assert.Contains(t, stdout, `"login": "alice"`)
+				assert.NotContains(t, stdout, "invalid")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package traefik_github_oauth_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// cookieChunkSize the largest value of a cookie, browsers drop a cookie whose name and value exceed 4096 bytes.
+const cookieChunkSize = 3800
+
+// setChunkedCookie sets the cookie, split in the cookies name.0, name.1, ... if its value is too large for one cookie,
+// and expires the cookie or the chunks of the request it replaces.
+// Every cookie and chunk has the path /, a cookie set without a path is scoped to the directory of the request,
+// and would not replace the one of the other paths, e.g. the access token cookie refreshed from a nested path.
+func setChunkedCookie(rw http.ResponseWriter, req *http.Request, cookie *http.Cookie) {
+	scoped := *cookie
+	scoped.Path = "/"
+	scoped.Secure = req.TLS != nil
+	cookie = &scoped
+	value := cookie.Value
+	chunks := 0
+	if cookieChunkSize < len(value) {
+		for ; 0 < len(value); chunks++ {
+			size := cookieChunkSize
+			if len(value) < size {
+				size = len(value)
+			}
+			chunk := *cookie
+			chunk.Name = chunkCookieName(cookie.Name, chunks)
+			chunk.Value = value[:size]
+			http.SetCookie(rw, &chunk)
+			value = value[size:]
+		}
+		if _, err := req.Cookie(cookie.Name); err == nil {
+			expireCookie(rw, cookie, cookie.Name)
+		}
+	} else {
+		http.SetCookie(rw, cookie)
+	}
+	for i := chunks; ; i++ {
+		name := chunkCookieName(cookie.Name, i)
+		if _, err := req.Cookie(name); err != nil {
+			break
+		}
+		expireCookie(rw, cookie, name)
+	}
+}
+
+// readChunkedCookie reads the value of a cookie set by setChunkedCookie, joining its chunks.
+func readChunkedCookie(req *http.Request, name string) (string, error) {
+	if cookie, err := req.Cookie(name); err == nil {
+		return cookie.Value, nil
+	}
+	var value strings.Builder
+	for i := 0; ; i++ {
+		chunk, err := req.Cookie(chunkCookieName(name, i))
+		if err != nil {
+			if i == 0 {
+				return "", err
+			}
+			break
+		}
+		value.WriteString(chunk.Value)
+	}
+	return value.String(), nil
+}
+
+func chunkCookieName(name string, i int) string {
+	return fmt.Sprintf("%s.%d", name, i)
+}
+
+// expireCookie removes the cookie name set with the attributes of cookie.
+func expireCookie(rw http.ResponseWriter, cookie *http.Cookie, name string) {
+	expired := *cookie
+	expired.Name = name
+	expired.Value = ""
+	expired.MaxAge = -1
+	http.SetCookie(rw, &expired)
+}
@@ -0,0 +1,147 @@
+package traefik_github_oauth_plugin
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCookieName = "session"
+
+// newCookieRequest a request carrying the cookies.
+func newCookieRequest(cookies ...*http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range cookies {
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	return req
+}
+
+// setCookieNames the names of the cookies set and of the ones expired by a response.
+func setCookieNames(rw *httptest.ResponseRecorder) (set []string, expired []string) {
+	for _, cookie := range rw.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			expired = append(expired, cookie.Name)
+		} else {
+			set = append(set, cookie.Name)
+		}
+	}
+	return set, expired
+}
+
+func TestSetChunkedCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		chunks []string
+	}{
+		{name: "small", size: 10, chunks: []string{"session"}},
+		{name: "chunk size", size: cookieChunkSize, chunks: []string{"session"}},
+		{name: "chunk size + 1", size: cookieChunkSize + 1, chunks: []string{"session.0", "session.1"}},
+		{name: "3 chunks", size: 3 * cookieChunkSize, chunks: []string{"session.0", "session.1", "session.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			value := strings.Repeat("a", tt.size-1) + "z"
+			rw := httptest.NewRecorder()
+
+			// execution
+			setChunkedCookie(rw, newCookieRequest(), &http.Cookie{Name: testCookieName, Value: value})
+			read, err := readChunkedCookie(newCookieRequest(rw.Result().Cookies()...), testCookieName)
+
+			// assertion
+			set, expired := setCookieNames(rw)
+			assert.Equal(t, tt.chunks, set)
+			assert.Empty(t, expired)
+			assert.NoError(t, err)
+			assert.Equal(t, value, read)
+			for _, cookie := range rw.Result().Cookies() {
+				assert.LessOrEqual(t, len(cookie.Value), cookieChunkSize)
+			}
+		})
+	}
+}
+
+func TestSetChunkedCookie_ReplaceChunked(t *testing.T) {
+	// setup
+	req := newCookieRequest(
+		&http.Cookie{Name: "session.0", Value: "a"},
+		&http.Cookie{Name: "session.1", Value: "b"},
+		&http.Cookie{Name: "session.2", Value: "c"},
+	)
+	rw := httptest.NewRecorder()
+
+	// execution
+	setChunkedCookie(rw, req, &http.Cookie{Name: testCookieName, Value: "small"})
+
+	// assertion
+	set, expired := setCookieNames(rw)
+	assert.Equal(t, []string{"session"}, set)
+	assert.Equal(t, []string{"session.0", "session.1", "session.2"}, expired)
+}
+
+func TestSetChunkedCookie_ReplaceLongerChunked(t *testing.T) {
+	// setup
+	req := newCookieRequest(
+		&http.Cookie{Name: "session", Value: "old"},
+		&http.Cookie{Name: "session.0", Value: "a"},
+		&http.Cookie{Name: "session.1", Value: "b"},
+		&http.Cookie{Name: "session.2", Value: "c"},
+	)
+	rw := httptest.NewRecorder()
+
+	// execution
+	setChunkedCookie(rw, req, &http.Cookie{Name: testCookieName, Value: strings.Repeat("a", cookieChunkSize+1)})
+
+	// assertion
+	set, expired := setCookieNames(rw)
+	assert.Equal(t, []string{"session.0", "session.1"}, set)
+	assert.Equal(t, []string{"session", "session.2"}, expired)
+}
+
+func TestSetChunkedCookie_Path(t *testing.T) {
+	tests := []struct {
+		name   string
+		tls    bool
+		secure bool
+	}{
+		{name: "http", tls: false, secure: false},
+		{name: "https", tls: true, secure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			req := httptest.NewRequest(http.MethodGet, "/nested/dir/page", nil)
+			req.AddCookie(&http.Cookie{Name: "session.0", Value: "a"})
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			rw := httptest.NewRecorder()
+
+			// execution
+			setChunkedCookie(rw, req, &http.Cookie{Name: testCookieName, Value: "small", HttpOnly: true})
+
+			// assertion
+			cookies := rw.Result().Cookies()
+			assert.Len(t, cookies, 2)
+			for _, cookie := range cookies {
+				assert.Equal(t, "/", cookie.Path)
+				assert.Equal(t, tt.secure, cookie.Secure)
+				assert.True(t, cookie.HttpOnly)
+			}
+		})
+	}
+}
+
+func TestReadChunkedCookie_Missing(t *testing.T) {
+	// execution
+	_, err := readChunkedCookie(newCookieRequest(), testCookieName)
+
+	// assertion
+	assert.ErrorIs(t, err, http.ErrNoCookie)
+}
@@ -0,0 +1,100 @@
+package traefik_github_oauth_server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/seal"
+	"golang.org/x/oauth2"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const SEAL_PURPOSE_ACCESS_TOKEN = "access_token"
+
+// AccessTokenRefreshMargin how long before its expiry an access token is refreshed,
+// so it does not expire while the upstream uses it.
+const AccessTokenRefreshMargin = time.Minute
+
+var (
+	ErrAccessTokenExpired         = errors.New("access token expired")
+	ErrAccessTokenForwardDisabled = errors.New("the access token forwarding is disabled, ACCESS_TOKEN_SECRET_KEY is not set")
+)
+
+// AccessToken the provider token of a user, sealed into the cookie the middleware forwards it upstream from.
+// Only the server can open it, the middleware asks the server for the access token and its refresh.
+type AccessToken struct {
+	// Client the name of the OAuth client the token was issued to, it refreshes the token.
+	Client string `json:"client"`
+	// UserID the user the token belongs to, the middleware checks it against the session.
+	UserID       string `json:"user_id"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// ExpiresAt unix seconds, 0 for a token that does not expire, e.g. the tokens of GitHub OAuth Apps.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// NewAccessToken creates the AccessToken of the token the client issued to the user.
+func NewAccessToken(client, userID string, token *oauth2.Token) *AccessToken {
+	at := &AccessToken{
+		Client:       client,
+		UserID:       userID,
+		AccessToken:  token.AccessToken,
+		TokenType:    token.TokenType,
+		RefreshToken: token.RefreshToken,
+	}
+	if !token.Expiry.IsZero() {
+		at.ExpiresAt = token.Expiry.Unix()
+	}
+	return at
+}
+
+// AccessTokenSealer seals the provider tokens of the users, so they are only stored encrypted.
+type AccessTokenSealer struct {
+	sealer *seal.Sealer
+	now    func() time.Time
+}
+
+// NewAccessTokenSealer creates a new AccessTokenSealer.
+func NewAccessTokenSealer(secret string) (*AccessTokenSealer, error) {
+	sealer, err := seal.NewSealer(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessTokenSealer{
+		sealer: sealer,
+		now:    time.Now,
+	}, nil
+}
+
+// newAccessTokenSealer creates the AccessTokenSealer of the config, nil when the access token forwarding is disabled.
+func newAccessTokenSealer(config *Config) (*AccessTokenSealer, error) {
+	if len(config.AccessTokenSecretKey) == 0 {
+		return nil, nil
+	}
+	return NewAccessTokenSealer(config.AccessTokenSecretKey)
+}
+
+// Seal seals the access token.
+func (s *AccessTokenSealer) Seal(at *AccessToken) (string, error) {
+	return s.sealer.Seal(SEAL_PURPOSE_ACCESS_TOKEN, at)
+}
+
+// Open opens a token sealed by Seal, expired or not.
+func (s *AccessTokenSealer) Open(sealed string) (*AccessToken, error) {
+	at := &AccessToken{}
+	if err := s.sealer.Open(SEAL_PURPOSE_ACCESS_TOKEN, sealed, at); err != nil {
+		return nil, err
+	}
+	return at, nil
+}
+
+// NeedsRefresh reports whether the token expires within AccessTokenRefreshMargin.
+func (s *AccessTokenSealer) NeedsRefresh(at *AccessToken) bool {
+	return 0 < at.ExpiresAt && !s.now().Add(AccessTokenRefreshMargin).Before(time.Unix(at.ExpiresAt, 0))
+}
+
+// Expired reports whether the token expired.
+func (s *AccessTokenSealer) Expired(at *AccessToken) bool {
+	return 0 < at.ExpiresAt && !s.now().Before(time.Unix(at.ExpiresAt, 0))
+}
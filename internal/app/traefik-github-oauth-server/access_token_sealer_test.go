@@ -0,0 +1,61 @@
+package traefik_github_oauth_server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/seal"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestAccessTokenSealer_Open(t *testing.T) {
+	// setup
+	sealer, _ := NewAccessTokenSealer("secretKey")
+	now := time.Unix(1700000000, 0)
+	sealer.now = func() time.Time { return now }
+	sealed, err := sealer.Seal(NewAccessToken("default", "996", &oauth2.Token{
+		AccessToken:  "ghu_access",
+		TokenType:    "bearer",
+		RefreshToken: "ghr_refresh",
+		Expiry:       now.Add(30 * time.Second),
+	}))
+	assert.NoError(t, err)
+
+	// execution
+	at, err := sealer.Open(sealed)
+
+	// assertion
+	assert.NoError(t, err)
+	assert.NotContains(t, sealed, "ghu_access")
+	assert.Equal(t, &AccessToken{
+		Client:       "default",
+		UserID:       "996",
+		AccessToken:  "ghu_access",
+		TokenType:    "bearer",
+		RefreshToken: "ghr_refresh",
+		ExpiresAt:    now.Add(30 * time.Second).Unix(),
+	}, at)
+	assert.True(t, sealer.NeedsRefresh(at))
+	assert.False(t, sealer.Expired(at))
+
+	// the other seals cannot be opened as access tokens
+	otherSealer, _ := seal.NewSealer("secretKey")
+	state, _ := otherSealer.Seal(SEAL_PURPOSE_STATE, at)
+	_, err = sealer.Open(state)
+	assert.ErrorIs(t, err, seal.ErrInvalidSealed)
+}
+
+func TestAccessTokenSealer_NeedsRefresh(t *testing.T) {
+	// setup
+	sealer, _ := NewAccessTokenSealer("secretKey")
+	now := time.Unix(1700000000, 0)
+	sealer.now = func() time.Time { return now }
+
+	// execution & assertion
+	assert.False(t, sealer.NeedsRefresh(&AccessToken{}))
+	assert.False(t, sealer.Expired(&AccessToken{}))
+	assert.False(t, sealer.NeedsRefresh(&AccessToken{ExpiresAt: now.Add(time.Hour).Unix()}))
+	assert.True(t, sealer.NeedsRefresh(&AccessToken{ExpiresAt: now.Unix()}))
+	assert.True(t, sealer.Expired(&AccessToken{ExpiresAt: now.Unix()}))
+}
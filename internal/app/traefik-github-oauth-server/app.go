@@ -3,30 +3,58 @@ package traefik_github_oauth_server
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/store"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit/syslogsink"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/ratelimit"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/tracing"
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache"
 	"github.com/rs/zerolog"
-	"golang.org/x/oauth2"
-	oauth2github "golang.org/x/oauth2/github"
 )
 
+// ErrDraining the readiness error of a server shutting down.
+var ErrDraining = errors.New("server is draining")
+
 func init() {
 	gin.SetMode(gin.ReleaseMode)
 }
 
 // App the Traefik GitHub OAuth server application.
 type App struct {
-	Config             *Config
+	// config and oAuthClients are swapped on reload, read them with Config and OAuthClient.
+	config       atomic.Pointer[Config]
+	oAuthClients atomic.Pointer[map[string]*OAuthClient]
+	// draining is set on shutdown, the readiness check fails from then on.
+	draining atomic.Bool
+
+	ConfigLoader       *ConfigLoader
 	Server             *http.Server
 	Engine             *gin.Engine
-	GitHubOAuthConfig  *oauth2.Config
 	AuthRequestManager *AuthRequestManager
-	Logger             *zerolog.Logger
+	// AuthRequestSealer is set in stateless mode, it replaces AuthRequestManager.
+	AuthRequestSealer *AuthRequestSealer
+	SessionIssuer     *SessionIssuer
+	// AccessTokenSealer is nil when the access token forwarding is disabled.
+	AccessTokenSealer *AccessTokenSealer
+	RevocationStore   *RevocationStore
+	LoginHistory      *LoginHistory
+	BuildInfo         BuildInfo
+	IPRateLimiter     *ratelimit.Limiter
+	ApiKeyRateLimiter *ratelimit.Limiter
+	Logger            *zerolog.Logger
+	AuditLogger       *audit.Logger
+	// Tracer is nil when tracing is disabled, it starts no span then.
+	Tracer *tracing.Tracer
 }
 
 func NewApp(
@@ -34,79 +62,311 @@ func NewApp(
 	server *http.Server,
 	engine *gin.Engine,
 	authRequestManager *AuthRequestManager,
+	authRequestSealer *AuthRequestSealer,
 	logger *zerolog.Logger,
-) *App {
+	auditLogger *audit.Logger,
+) (*App, error) {
 	gin.DebugPrintRouteFunc = ginDebugPrintRouteFunc(logger)
-	if config.DebugMode {
-		gin.SetMode(gin.DebugMode)
-		config.LogLevel = "DEBUG"
-	}
-
-	switch config.LogLevel {
-	case "DEBUG", "debug":
-		logger.Level(zerolog.DebugLevel)
-	case "INFO", "info":
-		logger.Level(zerolog.InfoLevel)
-	case "WARNING", "warning", "WARN", "warn":
-		logger.Level(zerolog.WarnLevel)
-	case "ERROR", "error":
-		logger.Level(zerolog.ErrorLevel)
-	}
 
 	server.Addr = config.ServerAddress
 	server.Handler = engine
+	// the client IP is the remote address unless it is a trusted proxy, so X-Forwarded-For cannot be spoofed
+	if err := engine.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
 	app := &App{
-		Config: config,
-		Server: server,
-		Engine: engine,
-		GitHubOAuthConfig: &oauth2.Config{
-			ClientID:     config.GitHubOAuthClientID,
-			ClientSecret: config.GitHubOAuthClientSecret,
-			Endpoint:     oauth2github.Endpoint,
-		},
+		Server:             server,
+		Engine:             engine,
 		AuthRequestManager: authRequestManager,
+		AuthRequestSealer:  authRequestSealer,
+		IPRateLimiter:      ratelimit.NewLimiter(config.RateLimitIPRPS, config.RateLimitIPBurst),
+		ApiKeyRateLimiter:  ratelimit.NewLimiter(config.RateLimitApiKeyRPS, config.RateLimitApiKeyBurst),
+		LoginHistory:       NewLoginHistory(DefaultLoginHistorySize),
 		Logger:             logger,
+		AuditLogger:        auditLogger,
+	}
+	if err := app.applyConfig(config); err != nil {
+		return nil, err
 	}
 
-	return app
+	return app, nil
 }
 
-func NewDefaultApp() *App {
+// NewDefaultApp creates the App from the config loaded from args, env vars and the config file.
+func NewDefaultApp(args []string) (*App, error) {
 	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	configLoader := NewConfigLoader(args)
+	config, err := configLoader.Load()
+	if err != nil {
+		return nil, err
+	}
+	// the gin mode is global and read by every request, it is only set here, DEBUG_MODE requires a restart
+	if config.DebugMode {
+		gin.SetMode(gin.DebugMode)
+	}
 	engine := gin.New()
-	engine.Use(NewLoggerMiddleware(&logger), gin.Recovery())
-	return NewApp(
-		NewConfigFromEnv(),
+	engine.Use(NewRequestIDMiddleware(&logger), NewLoggerMiddleware(&logger), gin.Recovery())
+	auditLogger, err := NewAuditLogger(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit logger: %w", err)
+	}
+	authRequestStore, err := store.New(store.Options{
+		Type:           config.AuthRequestStore,
+		FilePath:       config.AuthRequestStoreFile,
+		RedisURL:       config.AuthRequestStoreRedis,
+		RedisKeyPrefix: config.AuthRequestStorePrefix,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create auth request store: %w", err)
+	}
+	var authRequestSealer *AuthRequestSealer
+	if config.StatelessMode {
+		authRequestSealer, err = NewAuthRequestSealer(config.StateSecretKey, config.AuthRequestTTL, config.AuthResultTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create auth request sealer: %w", err)
+		}
+	}
+	app, err := NewApp(
+		config,
 		&http.Server{
 			ReadHeaderTimeout: 5 * time.Second,
 		},
 		engine,
-		NewAuthRequestManager(cache.New(10*time.Minute, 30*time.Minute)),
+		NewAuthRequestManager(
+			authRequestStore,
+			config.AuthRequestTTL,
+			config.AuthResultTTL,
+			config.AuthRequestMaxPending,
+		),
+		authRequestSealer,
 		&logger,
+		auditLogger,
 	)
+	if err != nil {
+		return nil, err
+	}
+	app.ConfigLoader = configLoader
+	app.Server.TLSConfig, err = NewTLSConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS config: %w", err)
+	}
+	app.SessionIssuer, err = newSessionIssuer(config, &logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session issuer: %w", err)
+	}
+	app.AccessTokenSealer, err = newAccessTokenSealer(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access token sealer: %w", err)
+	}
+	app.Tracer, err = NewTracer(config, &logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tracer: %w", err)
+	}
+	app.RevocationStore, err = NewRevocationStore(config.RevocationListFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open revocation list: %w", err)
+	}
+	return app, nil
+}
+
+// Config returns the current config.
+func (app *App) Config() *Config {
+	return app.config.Load()
+}
+
+// RequestLogger returns the logger of the request of ctx, it logs the request id.
+// Outside of a request it is the app logger.
+func (app *App) RequestLogger(ctx context.Context) *zerolog.Logger {
+	if logger, ok := ctx.Value(requestLoggerKey{}).(*zerolog.Logger); ok {
+		return logger
+	}
+	return app.Logger
+}
+
+// OAuthClient returns the current OAuth client by name, an empty name is the default client.
+func (app *App) OAuthClient(name string) (*OAuthClient, bool) {
+	if len(name) == 0 {
+		name = DefaultOAuthClientName
+	}
+	client, found := (*app.oAuthClients.Load())[name]
+	return client, found
+}
+
+// Ready reports whether the server should receive traffic: it is not draining and the auth request store is reachable.
+func (app *App) Ready(ctx context.Context) error {
+	if app.draining.Load() {
+		return ErrDraining
+	}
+	if err := app.AuthRequestManager.Ping(ctx); err != nil {
+		return fmt.Errorf("auth request store unreachable: %w", err)
+	}
+	return nil
+}
+
+// Reload loads the config again and applies the settings that do not need a restart.
+// In-flight logins are kept, and the current config stays in place if the new one is invalid.
+func (app *App) Reload() error {
+	if app.ConfigLoader == nil {
+		return errors.New("no config loader")
+	}
+	config, err := app.ConfigLoader.Load()
+	if err != nil {
+		return err
+	}
+	if changed := app.Config().RestartRequiredChanges(config); 0 < len(changed) {
+		app.Logger.Warn().Strs("settings", changed).Msg("Settings changed that require a restart, ignoring them")
+	}
+	return app.applyConfig(config)
+}
+
+// applyConfig applies the reloadable settings of config, nothing is applied if it fails.
+func (app *App) applyConfig(config *Config) error {
+	oAuthClients, err := NewOAuthClients(config)
+	if err != nil {
+		return err
+	}
+
+	// the debug mode of the startup keeps the debug level, a reload only changes LOG_LEVEL
+	logLevel, _ := parseLogLevel(config.LogLevel)
+	if gin.IsDebugging() {
+		logLevel = zerolog.DebugLevel
+	}
+	zerolog.SetGlobalLevel(logLevel)
+
+	app.IPRateLimiter.SetLimit(config.RateLimitIPRPS, config.RateLimitIPBurst)
+	app.ApiKeyRateLimiter.SetLimit(config.RateLimitApiKeyRPS, config.RateLimitApiKeyBurst)
+
+	app.oAuthClients.Store(&oAuthClients)
+	app.config.Store(config)
+	return nil
+}
+
+// NewAuditLogger creates the audit logger from the config.
+func NewAuditLogger(config *Config) (*audit.Logger, error) {
+	opts := audit.Options{
+		FilePath:   config.AuditLogFile,
+		MaxSizeMB:  config.AuditLogMaxSizeMB,
+		MaxBackups: config.AuditLogMaxBackups,
+	}
+	if config.AuditLogSyslog {
+		writer, err := syslogsink.New(config.AuditLogSyslogNetwork, config.AuditLogSyslogAddress, "traefik-github-oauth-server")
+		if err != nil {
+			return nil, err
+		}
+		opts.Sinks = append(opts.Sinks, writer)
+	}
+	logger, err := audit.NewLoggerFromOptions(opts)
+	if err != nil {
+		for _, sink := range opts.Sinks {
+			_ = sink.(io.Closer).Close()
+		}
+		return nil, err
+	}
+	return logger, nil
+}
+
+// Audit writes an audit event from the server, logging the failure if the event cannot be written.
+func (app *App) Audit(event audit.Event) {
+	event.Source = audit.SOURCE_SERVER
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if isLogin(event) {
+		app.LoginHistory.Add(event)
+	}
+	if err := app.AuditLogger.Log(event); err != nil {
+		app.Logger.Error().Err(err).Str("event", event.Event).Msg("failed to write audit event")
+	}
+}
+
+// isLogin reports whether the event is a successful login, from the browser or the device flow.
+func isLogin(event audit.Event) bool {
+	switch event.Event {
+	case audit.EVENT_CODE_EXCHANGED, audit.EVENT_SESSION_ISSUED:
+		return event.Decision != audit.DECISION_DENIED
+	}
+	return false
 }
 
 func (app *App) Run() {
+	listener, err := NewListener(app.Server.Addr)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msgf("Failed to listen: %s\n", err)
+	}
 	go func() {
-		if err := app.Server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			app.Logger.Fatal().Err(err).Msgf("Failed to listen: %s\n", err)
+		var err error
+		if app.Server.TLSConfig != nil {
+			// the certificate comes from TLSConfig.GetCertificate
+			err = app.Server.ServeTLS(listener, "", "")
+		} else {
+			err = app.Server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.Logger.Fatal().Err(err).Msgf("Failed to serve: %s\n", err)
 		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
-	app.Logger.Info().Msg("Shutdown Server ...")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			app.Logger.Info().Str("signal", sig.String()).Msg("Shutdown Server ...")
+			break
+		}
+		if err := app.Reload(); err != nil {
+			app.Logger.Error().Err(err).Msg("Failed to reload config, keeping the current one")
+			continue
+		}
+		app.Logger.Info().Msg("Config reloaded")
+	}
+	app.Shutdown()
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	if err := app.Server.Shutdown(ctx); err != nil {
-		app.Logger.Fatal().Err(err).Msgf("Error while shutting down server: %s\n", err)
+// Shutdown drains the server: it reports not ready for SHUTDOWN_DRAIN_DELAY, so load balancers stop sending logins,
+// then stops accepting connections and waits up to SHUTDOWN_TIMEOUT for the in-flight requests.
+func (app *App) Shutdown() {
+	config := app.Config()
+	app.draining.Store(true)
+	if 0 < config.ShutdownDrainDelay {
+		app.Logger.Info().Dur("delay", config.ShutdownDrainDelay).Msg("Draining")
+		time.Sleep(config.ShutdownDrainDelay)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
 	defer cancel()
+	if err := app.Server.Shutdown(ctx); err != nil {
+		app.Logger.Error().Err(err).Msg("Error while shutting down server, closing the remaining connections")
+		_ = app.Server.Close()
+	}
+	if err := app.AuthRequestManager.Close(); err != nil {
+		app.Logger.Error().Err(err).Msg("Error while closing auth request store")
+	}
+	if err := app.AuditLogger.Close(); err != nil {
+		app.Logger.Error().Err(err).Msg("Error while closing audit logger")
+	}
+	if err := app.Tracer.Close(); err != nil {
+		app.Logger.Error().Err(err).Msg("Error while closing tracer")
+	}
 	app.Logger.Info().Msg("Server exiting")
 }
 
+// parseLogLevel parses a log level, an empty level is info.
+func parseLogLevel(level string) (zerolog.Level, bool) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return zerolog.DebugLevel, true
+	case "", "info":
+		return zerolog.InfoLevel, true
+	case "warning", "warn":
+		return zerolog.WarnLevel, true
+	case "error":
+		return zerolog.ErrorLevel, true
+	default:
+		return zerolog.InfoLevel, false
+	}
+}
+
 func ginDebugPrintRouteFunc(logger *zerolog.Logger) func(httpMethod, absolutePath, handlerName string, nuHandlers int) {
 	return func(httpMethod, absolutePath, handlerName string, nuHandlers int) {
 		logger.Debug().
@@ -1,39 +1,75 @@
 package traefik_github_oauth_server
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
-	"github.com/patrickmn/go-cache"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/store"
 	"github.com/rs/xid"
 )
 
+// ErrTooManyAuthRequests is returned by Insert when the number of outstanding auth requests reached the cap.
+var ErrTooManyAuthRequests = errors.New("too many outstanding auth requests")
+
 type AuthRequestManager struct {
-	cache *cache.Cache
+	store      store.Store
+	ttl        time.Duration
+	resultTTL  time.Duration
+	maxPending int
 }
 
-func NewAuthRequestManager(cache *cache.Cache) *AuthRequestManager {
+// NewAuthRequestManager creates a new AuthRequestManager.
+// ttl is how long a login may take, resultTTL is how long the middleware has to claim the result once
+// GitHub redirected back, and maxPending caps the number of outstanding auth requests, 0 means unlimited.
+func NewAuthRequestManager(store store.Store, ttl, resultTTL time.Duration, maxPending int) *AuthRequestManager {
 	return &AuthRequestManager{
-		cache: cache,
+		store:      store,
+		ttl:        ttl,
+		resultTTL:  resultTTL,
+		maxPending: maxPending,
 	}
 }
 
-func (m *AuthRequestManager) Insert(aq *model.AuthRequest) string {
+// Insert stores a new auth request and returns its request id.
+// The cap on the outstanding auth requests is enforced by the store, atomically with the insert.
+func (m *AuthRequestManager) Insert(ctx context.Context, aq *model.AuthRequest) (string, error) {
 	rid := xid.New().String()
-	m.cache.SetDefault(rid, aq)
-	return rid
+	aq.CreatedAt = time.Now().Unix()
+	err := m.store.Insert(ctx, rid, aq, m.ttl, m.maxPending)
+	if errors.Is(err, store.ErrFull) {
+		return "", ErrTooManyAuthRequests
+	}
+	if err != nil {
+		return "", err
+	}
+	return rid, nil
 }
 
-func (m *AuthRequestManager) Get(rid string) (*model.AuthRequest, bool) {
-	authRequest, found := m.cache.Get(rid)
-	if !found {
-		return nil, false
-	}
-	return authRequest.(*model.AuthRequest), true
+func (m *AuthRequestManager) Get(ctx context.Context, rid string) (*model.AuthRequest, error) {
+	return m.store.Get(ctx, rid)
 }
 
-func (m *AuthRequestManager) Pop(rid string) (*model.AuthRequest, bool) {
-	aq, found := m.Get(rid)
-	if found {
-		m.cache.Delete(rid)
-	}
-	return aq, found
+// Update stores the auth request with the result of the login, it expires after the result ttl.
+func (m *AuthRequestManager) Update(ctx context.Context, rid string, aq *model.AuthRequest) error {
+	return m.store.Update(ctx, rid, aq, m.resultTTL)
+}
+
+func (m *AuthRequestManager) Pop(ctx context.Context, rid string) (*model.AuthRequest, error) {
+	return m.store.Pop(ctx, rid)
+}
+
+// List returns the pending auth requests by request id.
+func (m *AuthRequestManager) List(ctx context.Context) (map[string]*model.AuthRequest, error) {
+	return m.store.List(ctx)
+}
+
+// Ping checks that the auth request store is reachable.
+func (m *AuthRequestManager) Ping(ctx context.Context) error {
+	return m.store.Ping(ctx)
+}
+
+func (m *AuthRequestManager) Close() error {
+	return m.store.Close()
 }
@@ -0,0 +1,112 @@
+package traefik_github_oauth_server
+
+import (
+	"errors"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/seal"
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/xid"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	SEAL_PURPOSE_STATE  = "auth_request_state"
+	SEAL_PURPOSE_RESULT = "auth_request_result"
+)
+
+var (
+	ErrAuthRequestExpired  = errors.New("auth request expired")
+	ErrAuthRequestReplayed = errors.New("auth request already used")
+)
+
+// AuthRequestSealer the stateless alternative to AuthRequestManager.
+// The auth request travels sealed in the OAuth state, and its result comes back to the middleware as
+// a sealed single-use token, so any replica sharing the secret can finish any login.
+//
+// Replay protection relies on a nonce cache local to each replica, a token can be replayed against
+// another replica until it expires, so keep the result ttl short.
+type AuthRequestSealer struct {
+	sealer    *seal.Sealer
+	ttl       time.Duration
+	resultTTL time.Duration
+	nonces    *cache.Cache
+	now       func() time.Time
+}
+
+// NewAuthRequestSealer creates a new AuthRequestSealer.
+func NewAuthRequestSealer(secret string, ttl, resultTTL time.Duration) (*AuthRequestSealer, error) {
+	sealer, err := seal.NewSealer(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthRequestSealer{
+		sealer:    sealer,
+		ttl:       ttl,
+		resultTTL: resultTTL,
+		nonces:    cache.New(cache.NoExpiration, time.Minute),
+		now:       time.Now,
+	}, nil
+}
+
+// SealState seals a new auth request into an OAuth state, setting its nonce and expiry.
+func (s *AuthRequestSealer) SealState(aq *model.AuthRequest) (string, error) {
+	aq.Nonce = xid.New().String()
+	aq.ExpiresAt = s.now().Add(s.ttl).Unix()
+	return s.sealer.Seal(SEAL_PURPOSE_STATE, aq)
+}
+
+// OpenState opens an OAuth state, each state can only be opened once.
+func (s *AuthRequestSealer) OpenState(state string) (*model.AuthRequest, error) {
+	return s.open(SEAL_PURPOSE_STATE, state)
+}
+
+// SealResult seals a completed auth request into a single-use result token with a new expiry.
+// The nonce of the state is kept, it identifies the login like a request id.
+func (s *AuthRequestSealer) SealResult(aq *model.AuthRequest) (string, error) {
+	aq.ExpiresAt = s.now().Add(s.resultTTL).Unix()
+	return s.sealer.Seal(SEAL_PURPOSE_RESULT, aq)
+}
+
+// OpenResult opens a result token, each token can only be opened once.
+func (s *AuthRequestSealer) OpenResult(token string) (*model.AuthRequest, error) {
+	return s.open(SEAL_PURPOSE_RESULT, token)
+}
+
+// PeekState opens an OAuth state without using it, e.g. for the sign-in page shown before the provider redirects back.
+func (s *AuthRequestSealer) PeekState(state string) (*model.AuthRequest, error) {
+	aq, _, err := s.openUnused(SEAL_PURPOSE_STATE, state)
+	if err != nil {
+		return nil, err
+	}
+	if _, used := s.nonces.Get(SEAL_PURPOSE_STATE + ":" + aq.Nonce); used {
+		return nil, ErrAuthRequestReplayed
+	}
+	return aq, nil
+}
+
+func (s *AuthRequestSealer) open(purpose, sealed string) (*model.AuthRequest, error) {
+	aq, expiresAt, err := s.openUnused(purpose, sealed)
+	if err != nil {
+		return nil, err
+	}
+	// nonces are remembered per purpose until the sealed value expires, after which it is rejected anyway
+	if err := s.nonces.Add(purpose+":"+aq.Nonce, struct{}{}, expiresAt.Sub(s.now())); err != nil {
+		return nil, ErrAuthRequestReplayed
+	}
+	return aq, nil
+}
+
+// openUnused opens a sealed auth request that has not expired, without checking or recording its nonce.
+func (s *AuthRequestSealer) openUnused(purpose, sealed string) (*model.AuthRequest, time.Time, error) {
+	aq := &model.AuthRequest{}
+	if err := s.sealer.Open(purpose, sealed, aq); err != nil {
+		return nil, time.Time{}, err
+	}
+	expiresAt := time.Unix(aq.ExpiresAt, 0)
+	if !s.now().Before(expiresAt) {
+		return nil, time.Time{}, ErrAuthRequestExpired
+	}
+	return aq, expiresAt, nil
+}
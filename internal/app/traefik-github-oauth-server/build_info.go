@@ -0,0 +1,37 @@
+package traefik_github_oauth_server
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfo the version of the running server, set at build time by goreleaser.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	BuiltBy   string `json:"built_by"`
+	GoVersion string `json:"go_version"`
+}
+
+// NewBuildInfo creates the BuildInfo, the commit and date fall back to the VCS info embedded by go build.
+func NewBuildInfo(version, commit, date, builtBy string) BuildInfo {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		BuiltBy:   builtBy,
+		GoVersion: runtime.Version(),
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && len(info.Commit) == 0:
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && len(info.Date) == 0:
+				info.Date = setting.Value
+			}
+		}
+	}
+	return info
+}
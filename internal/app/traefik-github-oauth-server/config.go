@@ -1,29 +1,639 @@
 package traefik_github_oauth_server
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/store"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/spf13/cast"
+	"gopkg.in/yaml.v3"
 )
 
+// Config the server configuration.
+//
+// Every field is loaded, in increasing order of precedence, from its default tag, the config file key
+// (the lower-cased env tag), the env var named by its env tag and the command-line flag (the kebab-cased env tag).
+// Fields tagged reload:"restart" are not applied on SIGHUP.
 type Config struct {
-	ApiBaseURL              string
-	ApiSecretKey            string
-	ServerAddress           string
-	DebugMode               bool
-	LogLevel                string
-	GitHubOAuthClientID     string
-	GitHubOAuthClientSecret string
-}
-
-func NewConfigFromEnv() *Config {
-	return &Config{
-		ApiBaseURL:              os.Getenv("API_BASE_URL"),
-		ApiSecretKey:            os.Getenv("API_SECRET_KEY"),
-		ServerAddress:           os.Getenv("SERVER_ADDRESS"),
-		DebugMode:               cast.ToBool(os.Getenv("DEBUG_MODE")),
-		LogLevel:                os.Getenv("LOG_LEVEL"),
-		GitHubOAuthClientID:     os.Getenv("GITHUB_OAUTH_CLIENT_ID"),
-		GitHubOAuthClientSecret: os.Getenv("GITHUB_OAUTH_CLIENT_SECRET"),
+	ApiBaseURL              string                       `env:"API_BASE_URL" usage:"the base URL of the server"`
+	ApiSecretKey            string                       `env:"API_SECRET_KEY" secret:"true" usage:"the api secret key"`
+	AdminSecretKey          string                       `env:"ADMIN_SECRET_KEY" secret:"true" usage:"the admin secret key, the admin api is disabled if empty"`
+	ServerAddress           string                       `env:"SERVER_ADDRESS" default:":80" reload:"restart" usage:"the server address, or unix:<path> for a Unix domain socket"`
+	TLSCertFile             string                       `env:"TLS_CERT_FILE" reload:"restart" usage:"the PEM certificate of the server, TLS is disabled if empty, reloaded when it changes"`
+	TLSKeyFile              string                       `env:"TLS_KEY_FILE" reload:"restart" usage:"the PEM private key of the certificate, reloaded when it changes"`
+	TLSClientCAFile         string                       `env:"TLS_CLIENT_CA_FILE" reload:"restart" usage:"the PEM bundle of the CAs of the middleware client certificates, required by the middleware routes if set"`
+	ShutdownTimeout         time.Duration                `env:"SHUTDOWN_TIMEOUT" default:"30s" reload:"restart" usage:"how long the in-flight requests have to complete on shutdown"`
+	ShutdownDrainDelay      time.Duration                `env:"SHUTDOWN_DRAIN_DELAY" default:"0s" reload:"restart" usage:"how long the server reports not ready before it stops accepting connections on shutdown"`
+	DebugMode               bool                         `env:"DEBUG_MODE" reload:"restart" usage:"enable debug mode and set log level to debug"`
+	LogLevel                string                       `env:"LOG_LEVEL" default:"info" usage:"the log level: debug, info, warn, error"`
+	GitHubOAuthClientID     string                       `env:"GITHUB_OAUTH_CLIENT_ID" usage:"the OAuth App client id"`
+	GitHubOAuthClientSecret string                       `env:"GITHUB_OAUTH_CLIENT_SECRET" secret:"true" usage:"the OAuth App client secret"`
+	OAuthProvider           string                       `env:"OAUTH_PROVIDER" default:"github" usage:"the identity provider: github, gitlab, gitea, oidc"`
+	OAuthProviderURL        string                       `env:"OAUTH_PROVIDER_URL" usage:"the base URL of GitLab or Gitea, or the issuer URL of the OIDC provider"`
+	GitHubAuthURL           string                       `env:"GITHUB_AUTH_URL" usage:"the GitHub authorize URL, for GitHub Enterprise"`
+	GitHubTokenURL          string                       `env:"GITHUB_TOKEN_URL" usage:"the GitHub token URL, for GitHub Enterprise"`
+	GitHubApiURL            string                       `env:"GITHUB_API_URL" usage:"the GitHub API base URL, for GitHub Enterprise"`
+	GitHubCACertFile        string                       `env:"GITHUB_CA_CERT_FILE" usage:"a PEM bundle of additional CAs trusted for provider calls"`
+	GitHubClientType        string                       `env:"GITHUB_CLIENT_TYPE" default:"oauth_app" usage:"the type of the GitHub clients: oauth_app, github_app"`
+	GitHubAppInstallations  []string                     `env:"GITHUB_APP_INSTALLATIONS" usage:"the GitHub App installations, by id or account login, the user must access one of, * for any"`
+	OAuthScopes             []string                     `env:"OAUTH_SCOPES" usage:"the scopes every login requests in addition to the ones of the provider, e.g. read:org"`
+	OAuthAllowedScopes      []string                     `env:"OAUTH_ALLOWED_SCOPES" usage:"the scopes the middlewares may request in addition to OAUTH_SCOPES, any other is refused"`
+	OAuthAllowSignup        bool                         `env:"OAUTH_ALLOW_SIGNUP" default:"true" usage:"offer to sign up on the GitHub login page"`
+	OAuthClients            map[string]OAuthClientConfig `env:"OAUTH_CLIENTS" usage:"the named OAuth clients in addition to the default one, as a JSON object"`
+	LoginPage               bool                         `env:"LOGIN_PAGE" usage:"show a sign-in page naming the application before redirecting to the provider"`
+	LoginPageLogoURL        string                       `env:"LOGIN_PAGE_LOGO_URL" usage:"the URL of the logo of the sign-in page"`
+	LoginPageMessage        string                       `env:"LOGIN_PAGE_MESSAGE" usage:"the message of the sign-in page"`
+	TracingExporter         string                       `env:"TRACING_EXPORTER" default:"none" reload:"restart" usage:"the exporter of the traces: none, stdout, otlp"`
+	TracingOTLPEndpoint     string                       `env:"TRACING_OTLP_ENDPOINT" default:"http://localhost:4318/v1/traces" reload:"restart" usage:"the OTLP/HTTP traces endpoint of the otlp exporter"`
+	TracingOTLPHeaders      []string                     `env:"TRACING_OTLP_HEADERS" secret:"true" reload:"restart" usage:"the key=value headers sent to the OTLP endpoint, e.g. an api key"`
+	TracingServiceName      string                       `env:"TRACING_SERVICE_NAME" default:"traefik-github-oauth-server" reload:"restart" usage:"the service name of the spans"`
+	AuditLogFile            string                       `env:"AUDIT_LOG_FILE" reload:"restart" usage:"the audit log file"`
+	AuditLogMaxSizeMB       int                          `env:"AUDIT_LOG_MAX_SIZE_MB" default:"100" reload:"restart" usage:"the audit log size in megabytes after which it is rotated"`
+	AuditLogMaxBackups      int                          `env:"AUDIT_LOG_MAX_BACKUPS" default:"3" reload:"restart" usage:"the number of rotated audit log files to keep"`
+	AuditLogSyslog          bool                         `env:"AUDIT_LOG_SYSLOG" reload:"restart" usage:"also send the audit events to syslog"`
+	AuditLogSyslogNetwork   string                       `env:"AUDIT_LOG_SYSLOG_NETWORK" reload:"restart" usage:"the syslog network"`
+	AuditLogSyslogAddress   string                       `env:"AUDIT_LOG_SYSLOG_ADDRESS" reload:"restart" usage:"the syslog address"`
+	TrustedProxies          []string                     `env:"TRUSTED_PROXIES" reload:"restart" usage:"the IPs or CIDRs of the proxies whose X-Forwarded-For header sets the client IP, none by default"`
+	RateLimitIPRPS          float64                      `env:"RATE_LIMIT_IP_RPS" default:"10" usage:"the requests per second allowed per client IP"`
+	RateLimitIPBurst        int                          `env:"RATE_LIMIT_IP_BURST" default:"100" usage:"the burst of requests allowed per client IP"`
+	RateLimitApiKeyRPS      float64                      `env:"RATE_LIMIT_API_KEY_RPS" default:"50" usage:"the requests per second allowed per api key"`
+	RateLimitApiKeyBurst    int                          `env:"RATE_LIMIT_API_KEY_BURST" default:"200" usage:"the burst of requests allowed per api key"`
+	AuthRequestMaxPending   int                          `env:"AUTH_REQUEST_MAX_PENDING" default:"10000" reload:"restart" usage:"the maximum number of outstanding auth requests"`
+	AuthRequestTTL          time.Duration                `env:"AUTH_REQUEST_TTL" default:"10m" reload:"restart" usage:"how long a login may take"`
+	AuthResultTTL           time.Duration                `env:"AUTH_RESULT_TTL" default:"1m" reload:"restart" usage:"how long the middleware has to claim a login result"`
+	AuthRequestStore        string                       `env:"AUTH_REQUEST_STORE" default:"memory" reload:"restart" usage:"the auth request store: memory, file, redis"`
+	AuthRequestStoreFile    string                       `env:"AUTH_REQUEST_STORE_FILE" default:"auth_requests.json" reload:"restart" usage:"the file of the file store"`
+	AuthRequestStoreRedis   string                       `env:"AUTH_REQUEST_STORE_REDIS_URL" secret:"url" default:"redis://localhost:6379/0" reload:"restart" usage:"the url of the redis store"`
+	AuthRequestStorePrefix  string                       `env:"AUTH_REQUEST_STORE_REDIS_KEY_PREFIX" reload:"restart" usage:"the key prefix of the redis store"`
+	StatelessMode           bool                         `env:"STATELESS_MODE" reload:"restart" usage:"seal auth requests into the OAuth state instead of storing them"`
+	StateSecretKey          string                       `env:"STATE_SECRET_KEY" secret:"true" reload:"restart" usage:"the secret used to seal auth requests"`
+	SessionSigningKeyFile   string                       `env:"SESSION_SIGNING_KEY_FILE" reload:"restart" usage:"the PEM private key (RSA, EC or Ed25519) signing the session tokens, generated at startup if empty"`
+	SessionSigningKeyID     string                       `env:"SESSION_SIGNING_KEY_ID" reload:"restart" usage:"the kid of the signing key in the JWKS, its thumbprint if empty"`
+	SessionTokenTTL         time.Duration                `env:"SESSION_TOKEN_TTL" default:"8h" reload:"restart" usage:"how long the session tokens are valid"`
+	RevocationListFile      string                       `env:"REVOCATION_LIST_FILE" reload:"restart" usage:"the file persisting the session revocation list, kept in memory if empty"`
+	SessionSecretKey        string                       `env:"SESSION_SECRET_KEY" secret:"true" reload:"restart" usage:"the secret sealing the device codes of the device flow, the device flow is disabled if empty"`
+	AccessTokenSecretKey    string                       `env:"ACCESS_TOKEN_SECRET_KEY" secret:"true" reload:"restart" usage:"the secret sealing the provider access tokens the middlewares forward upstream, the forwarding is disabled if empty"`
+}
+
+// OAuthClientConfig the configuration of a named OAuth App.
+type OAuthClientConfig struct {
+	ClientID     string `yaml:"client_id" json:"client_id,omitempty"`
+	ClientSecret string `yaml:"client_secret" json:"client_secret,omitempty"`
+	// Provider and ProviderURL default to OAUTH_PROVIDER and OAUTH_PROVIDER_URL.
+	Provider    string `yaml:"provider" json:"provider,omitempty"`
+	ProviderURL string `yaml:"provider_url" json:"provider_url,omitempty"`
+	// ApiBaseURL the base URL of the server registered as callback of the app, defaults to API_BASE_URL.
+	ApiBaseURL string `yaml:"api_base_url" json:"api_base_url,omitempty"`
+	// AuthURL, TokenURL, GitHubApiURL and CACertFile default to the GITHUB_* settings.
+	AuthURL      string `yaml:"auth_url" json:"auth_url,omitempty"`
+	TokenURL     string `yaml:"token_url" json:"token_url,omitempty"`
+	GitHubApiURL string `yaml:"github_api_url" json:"github_api_url,omitempty"`
+	CACertFile   string `yaml:"ca_cert_file" json:"ca_cert_file,omitempty"`
+	// ClientType and Installations default to GITHUB_CLIENT_TYPE and GITHUB_APP_INSTALLATIONS.
+	ClientType    string   `yaml:"client_type" json:"client_type,omitempty"`
+	Installations []string `yaml:"installations" json:"installations,omitempty"`
+	// Scopes, AllowedScopes and AllowSignup default to OAUTH_SCOPES, OAUTH_ALLOWED_SCOPES and OAUTH_ALLOW_SIGNUP.
+	Scopes        []string `yaml:"scopes" json:"scopes,omitempty"`
+	AllowedScopes []string `yaml:"allowed_scopes" json:"allowed_scopes,omitempty"`
+	AllowSignup   *bool    `yaml:"allow_signup" json:"allow_signup,omitempty"`
+	// LoginPage, LoginPageLogoURL and LoginPageMessage default to LOGIN_PAGE, LOGIN_PAGE_LOGO_URL and LOGIN_PAGE_MESSAGE.
+	LoginPage        *bool  `yaml:"login_page" json:"login_page,omitempty"`
+	LoginPageLogoURL string `yaml:"login_page_logo_url" json:"login_page_logo_url,omitempty"`
+	LoginPageMessage string `yaml:"login_page_message" json:"login_page_message,omitempty"`
+}
+
+// REDACTED replaces the secrets in Config.Redacted.
+const REDACTED = "REDACTED"
+
+// ConfigFileEnv the env var, and lower-cased the flag, holding the path of the config file.
+const ConfigFileEnv = "CONFIG_FILE"
+
+// ConfigLoader loads the Config from defaults, a config file, env vars and command-line flags.
+// It keeps its sources, so the same Config can be loaded again on reload.
+type ConfigLoader struct {
+	Args   []string
+	Getenv func(key string) (string, bool)
+}
+
+// NewConfigLoader creates a ConfigLoader reading the process env vars and the given command-line arguments.
+func NewConfigLoader(args []string) *ConfigLoader {
+	return &ConfigLoader{
+		Args:   args,
+		Getenv: os.LookupEnv,
+	}
+}
+
+// Load loads and validates the Config.
+func (l *ConfigLoader) Load() (*Config, error) {
+	config := &Config{}
+	fields := configFields()
+	for _, field := range fields {
+		if err := field.set(config, field.defaultValue); err != nil {
+			return nil, fmt.Errorf("invalid default of %s: %w", field.env, err)
+		}
+	}
+
+	flagValues, configFile, err := l.parseFlags(fields)
+	if err != nil {
+		return nil, err
+	}
+	if len(configFile) == 0 {
+		configFile, _ = l.Getenv(ConfigFileEnv)
+	}
+	if 0 < len(configFile) {
+		if err := loadConfigFile(config, fields, configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range fields {
+		if value, ok := l.Getenv(field.env); ok {
+			if err := field.set(config, value); err != nil {
+				return nil, fmt.Errorf("invalid env %s: %w", field.env, err)
+			}
+		}
+	}
+	for _, field := range fields {
+		if value, ok := flagValues[field.flag]; ok {
+			if err := field.set(config, value); err != nil {
+				return nil, fmt.Errorf("invalid flag --%s: %w", field.flag, err)
+			}
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (l *ConfigLoader) parseFlags(fields []configField) (map[string]string, string, error) {
+	fs := flag.NewFlagSet("traefik-github-oauth-server", flag.ContinueOnError)
+	configFile := fs.String(strings.ToLower(strings.ReplaceAll(ConfigFileEnv, "_", "-")), "", "the config file (yaml, json or toml)")
+	values := make(map[string]*configFlagValue, len(fields))
+	for _, field := range fields {
+		value := &configFlagValue{isBool: field.kind == reflect.Bool}
+		values[field.flag] = value
+		fs.Var(value, field.flag, field.usage)
+	}
+	if err := fs.Parse(l.Args); err != nil {
+		return nil, "", err
+	}
+	if 0 < fs.NArg() {
+		return nil, "", fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	flagValues := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if value, ok := values[f.Name]; ok {
+			flagValues[f.Name] = value.value
+		}
+	})
+	return flagValues, *configFile, nil
+}
+
+// Validate checks the whole Config and reports every problem at once.
+func (c *Config) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.GitHubOAuthClientID) == 0 && len(c.GitHubOAuthClientSecret) == 0 && len(c.OAuthClients) == 0 {
+		addProblem("GITHUB_OAUTH_CLIENT_ID and GITHUB_OAUTH_CLIENT_SECRET, or OAUTH_CLIENTS, are required")
+	}
+	if _, found := c.OAuthClients[DefaultOAuthClientName]; found && 0 < len(c.GitHubOAuthClientID) {
+		addProblem("OAUTH_CLIENTS must not define the %q client when GITHUB_OAUTH_CLIENT_ID is set", DefaultOAuthClientName)
+	}
+	if 0 < len(c.ApiBaseURL) && !isAbsoluteHTTPURL(c.ApiBaseURL) {
+		addProblem("API_BASE_URL must be an absolute http(s) URL, got %q", c.ApiBaseURL)
+	}
+	for name, client := range c.OAuthClientConfigs() {
+		if len(client.ClientID) == 0 || len(client.ClientSecret) == 0 {
+			addProblem("the client id and secret of OAuth client %q are required", name)
+		}
+		if len(client.ApiBaseURL) == 0 {
+			addProblem("the api base url of OAuth client %q is required, set API_BASE_URL", name)
+		} else if !isAbsoluteHTTPURL(client.ApiBaseURL) {
+			addProblem("the api base url of OAuth client %q must be an absolute http(s) URL, got %q", name, client.ApiBaseURL)
+		}
+		switch client.Provider {
+		case provider.TYPE_GITHUB, provider.TYPE_GITLAB:
+		case provider.TYPE_GITEA, provider.TYPE_OIDC:
+			if len(client.ProviderURL) == 0 {
+				addProblem("the provider url of OAuth client %q is required by the %s provider", name, client.Provider)
+			}
+		default:
+			addProblem("the provider of OAuth client %q must be one of github, gitlab, gitea, oidc, got %q", name, client.Provider)
+		}
+		if 0 < len(client.ProviderURL) && !isAbsoluteHTTPURL(client.ProviderURL) {
+			addProblem("the provider url of OAuth client %q must be an absolute http(s) URL, got %q", name, client.ProviderURL)
+		}
+		for _, endpoint := range []string{client.AuthURL, client.TokenURL, client.GitHubApiURL} {
+			if 0 < len(endpoint) && !isAbsoluteHTTPURL(endpoint) {
+				addProblem("the GitHub URLs of OAuth client %q must be absolute http(s) URLs, got %q", name, endpoint)
+			}
+		}
+		if (len(client.AuthURL) == 0) != (len(client.TokenURL) == 0) {
+			addProblem("the auth url and token url of OAuth client %q must be set together", name)
+		}
+		if 0 < len(client.CACertFile) {
+			if _, err := os.Stat(client.CACertFile); err != nil {
+				addProblem("the CA cert file of OAuth client %q is not readable: %s", name, err)
+			}
+		}
+		if 0 < len(client.LoginPageLogoURL) && !isAbsoluteHTTPURL(client.LoginPageLogoURL) {
+			addProblem("the login page logo url of OAuth client %q must be an absolute http(s) URL, got %q", name, client.LoginPageLogoURL)
+		}
+		switch client.ClientType {
+		case CLIENT_TYPE_OAUTH_APP:
+			if 0 < len(client.Installations) {
+				addProblem("the installations of OAuth client %q require the %s client type", name, CLIENT_TYPE_GITHUB_APP)
+			}
+		case CLIENT_TYPE_GITHUB_APP:
+			if client.Provider != provider.TYPE_GITHUB {
+				addProblem("the %s client type of OAuth client %q requires the github provider", CLIENT_TYPE_GITHUB_APP, name)
+			}
+			if 0 < len(client.Scopes) || 0 < len(client.AllowedScopes) {
+				addProblem("OAuth client %q of the %s client type has no scopes, the permissions of a GitHub App are set on the app", name, CLIENT_TYPE_GITHUB_APP)
+			}
+		default:
+			addProblem("the client type of OAuth client %q must be one of %s, %s, got %q",
+				name, CLIENT_TYPE_OAUTH_APP, CLIENT_TYPE_GITHUB_APP, client.ClientType)
+		}
+	}
+	if len(c.ServerAddress) == 0 {
+		addProblem("SERVER_ADDRESS is required")
+	}
+	if path, ok := UnixSocketPath(c.ServerAddress); ok && len(path) == 0 {
+		addProblem("SERVER_ADDRESS must name the socket file, e.g. unix:/run/traefik-github-oauth-server.sock")
+	}
+	if c.ShutdownTimeout <= 0 || c.ShutdownDrainDelay < 0 {
+		addProblem("SHUTDOWN_TIMEOUT must be positive and SHUTDOWN_DRAIN_DELAY must not be negative")
+	}
+	if (0 < len(c.TLSCertFile)) != (0 < len(c.TLSKeyFile)) {
+		addProblem("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if 0 < len(c.TLSClientCAFile) && len(c.TLSCertFile) == 0 {
+		addProblem("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE")
+	}
+	if _, ok := parseLogLevel(c.LogLevel); !ok {
+		addProblem("LOG_LEVEL must be one of debug, info, warn, error, got %q", c.LogLevel)
+	}
+	switch c.TracingExporter {
+	case "", TRACING_EXPORTER_NONE, TRACING_EXPORTER_STDOUT:
+	case TRACING_EXPORTER_OTLP:
+		if u, err := url.Parse(c.TracingOTLPEndpoint); err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+			addProblem("TRACING_OTLP_ENDPOINT must be an http(s):// URL, got %q", c.TracingOTLPEndpoint)
+		}
+		if _, err := parseTracingHeaders(c.TracingOTLPHeaders); err != nil {
+			addProblem("TRACING_OTLP_HEADERS: %s", err)
+		}
+	default:
+		addProblem("TRACING_EXPORTER must be one of none, stdout, otlp, got %q", c.TracingExporter)
+	}
+	if c.AuditLogMaxSizeMB < 0 || c.AuditLogMaxBackups < 0 {
+		addProblem("AUDIT_LOG_MAX_SIZE_MB and AUDIT_LOG_MAX_BACKUPS must not be negative")
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			addProblem("TRUSTED_PROXIES must be IPs or CIDRs, got %q", proxy)
+		}
+	}
+	if c.RateLimitIPRPS < 0 || c.RateLimitIPBurst < 0 || c.RateLimitApiKeyRPS < 0 || c.RateLimitApiKeyBurst < 0 {
+		addProblem("RATE_LIMIT_* must not be negative")
+	}
+	if c.AuthRequestMaxPending < 0 {
+		addProblem("AUTH_REQUEST_MAX_PENDING must not be negative")
+	}
+	if c.AuthRequestTTL <= 0 || c.AuthResultTTL <= 0 {
+		addProblem("AUTH_REQUEST_TTL and AUTH_RESULT_TTL must be positive")
+	}
+	switch c.AuthRequestStore {
+	case store.TYPE_MEMORY:
+	case store.TYPE_FILE:
+		if len(c.AuthRequestStoreFile) == 0 {
+			addProblem("AUTH_REQUEST_STORE_FILE is required by the file store")
+		}
+	case store.TYPE_REDIS:
+		if u, err := url.Parse(c.AuthRequestStoreRedis); err != nil || len(u.Scheme) == 0 {
+			addProblem("AUTH_REQUEST_STORE_REDIS_URL must be a redis:// URL, got %q", c.AuthRequestStoreRedis)
+		}
+	default:
+		addProblem("AUTH_REQUEST_STORE must be one of memory, file, redis, got %q", c.AuthRequestStore)
+	}
+	if c.StatelessMode && len(c.StateSecretKey) == 0 {
+		addProblem("STATE_SECRET_KEY is required in stateless mode")
+	}
+	if c.SessionTokenTTL <= 0 {
+		addProblem("SESSION_TOKEN_TTL must be positive")
+	}
+
+	if 0 < len(problems) {
+		return fmt.Errorf("invalid config:\n  - %s", strings.Join(problems, "\n  - "))
 	}
+	return nil
+}
+
+// OAuthClientConfigs returns every OAuth client by name, including the default one,
+// with the api base url defaulting to API_BASE_URL.
+func (c *Config) OAuthClientConfigs() map[string]OAuthClientConfig {
+	clients := make(map[string]OAuthClientConfig, len(c.OAuthClients)+1)
+	if 0 < len(c.GitHubOAuthClientID) || 0 < len(c.GitHubOAuthClientSecret) {
+		clients[DefaultOAuthClientName] = OAuthClientConfig{
+			ClientID:     c.GitHubOAuthClientID,
+			ClientSecret: c.GitHubOAuthClientSecret,
+		}
+	}
+	for name, client := range c.OAuthClients {
+		clients[name] = client
+	}
+	for name, client := range clients {
+		client.ApiBaseURL = stringOrDefault(client.ApiBaseURL, c.ApiBaseURL)
+		client.Provider = stringOrDefault(client.Provider, c.OAuthProvider)
+		client.ProviderURL = stringOrDefault(client.ProviderURL, c.OAuthProviderURL)
+		client.AuthURL = stringOrDefault(client.AuthURL, c.GitHubAuthURL)
+		client.TokenURL = stringOrDefault(client.TokenURL, c.GitHubTokenURL)
+		client.GitHubApiURL = stringOrDefault(client.GitHubApiURL, c.GitHubApiURL)
+		client.CACertFile = stringOrDefault(client.CACertFile, c.GitHubCACertFile)
+		client.ClientType = stringOrDefault(client.ClientType, c.GitHubClientType)
+		if client.Installations == nil {
+			client.Installations = c.GitHubAppInstallations
+		}
+		if client.Scopes == nil {
+			client.Scopes = c.OAuthScopes
+		}
+		if client.AllowedScopes == nil {
+			client.AllowedScopes = c.OAuthAllowedScopes
+		}
+		if client.AllowSignup == nil {
+			allowSignup := c.OAuthAllowSignup
+			client.AllowSignup = &allowSignup
+		}
+		if client.LoginPage == nil {
+			loginPage := c.LoginPage
+			client.LoginPage = &loginPage
+		}
+		client.LoginPageLogoURL = stringOrDefault(client.LoginPageLogoURL, c.LoginPageLogoURL)
+		client.LoginPageMessage = stringOrDefault(client.LoginPageMessage, c.LoginPageMessage)
+		clients[name] = client
+	}
+	return clients
+}
+
+func stringOrDefault(s, defaultValue string) string {
+	if len(s) == 0 {
+		return defaultValue
+	}
+	return s
+}
+
+func isAbsoluteHTTPURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && 0 < len(u.Host)
+}
+
+// RestartRequiredChanges returns the env names of the fields tagged reload:"restart" that differ in other.
+func (c *Config) RestartRequiredChanges(other *Config) []string {
+	var changed []string
+	current, next := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	for _, field := range configFields() {
+		if field.restartRequired && !reflect.DeepEqual(current.Field(field.index).Interface(), next.Field(field.index).Interface()) {
+			changed = append(changed, field.env)
+		}
+	}
+	return changed
+}
+
+// Redacted returns the config by config file key, with the fields tagged secret and the client secrets redacted.
+// The password of the fields tagged secret:"url" is redacted, the rest of the URL is kept.
+func (c *Config) Redacted() map[string]interface{} {
+	redacted := make(map[string]interface{})
+	v := reflect.ValueOf(c).Elem()
+	for _, field := range configFields() {
+		value := v.Field(field.index).Interface()
+		switch {
+		case field.isDuration:
+			value = value.(time.Duration).String()
+		case field.secret == "url":
+			if u, err := url.Parse(value.(string)); err == nil {
+				value = u.Redacted()
+			} else {
+				value = REDACTED
+			}
+		case 0 < len(field.secret) && !v.Field(field.index).IsZero():
+			value = REDACTED
+		}
+		redacted[field.key] = value
+	}
+	clients := make(map[string]OAuthClientConfig, len(c.OAuthClients))
+	for name, client := range c.OAuthClients {
+		if 0 < len(client.ClientSecret) {
+			client.ClientSecret = REDACTED
+		}
+		clients[name] = client
+	}
+	redacted["oauth_clients"] = clients
+	return redacted
+}
+
+// configField a Config field with the names it is loaded from.
+type configField struct {
+	index           int
+	kind            reflect.Kind
+	isDuration      bool
+	env             string
+	key             string
+	flag            string
+	usage           string
+	defaultValue    string
+	restartRequired bool
+	// secret true for a secret, url for a URL that may hold a password.
+	secret string
+}
+
+func configFields() []configField {
+	t := reflect.TypeOf(Config{})
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		env, ok := f.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		fields = append(fields, configField{
+			index:           i,
+			kind:            f.Type.Kind(),
+			isDuration:      f.Type == reflect.TypeOf(time.Duration(0)),
+			env:             env,
+			key:             strings.ToLower(env),
+			flag:            strings.ToLower(strings.ReplaceAll(env, "_", "-")),
+			usage:           f.Tag.Get("usage"),
+			defaultValue:    f.Tag.Get("default"),
+			restartRequired: f.Tag.Get("reload") == "restart",
+			secret:          f.Tag.Get("secret"),
+		})
+	}
+	return fields
+}
+
+// set converts value, a string from env vars and flags or any scalar from a config file, to the field type.
+func (f configField) set(config *Config, value interface{}) error {
+	v := reflect.ValueOf(config).Elem().Field(f.index)
+	switch {
+	case f.isDuration:
+		d, err := cast.ToDurationE(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case f.kind == reflect.String:
+		s, err := cast.ToStringE(value)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case f.kind == reflect.Bool:
+		if s, ok := value.(string); ok && len(s) == 0 {
+			v.SetBool(false)
+			return nil
+		}
+		b, err := cast.ToBoolE(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case f.kind == reflect.Int:
+		n, err := cast.ToIntE(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case f.kind == reflect.Map:
+		return setConfigMap(v, value)
+	case f.kind == reflect.Slice:
+		strs, err := toConfigStrings(value)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(strs))
+	case f.kind == reflect.Float64:
+		n, err := cast.ToFloat64E(value)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported config field kind: %s", f.kind)
+	}
+	return nil
+}
+
+// toConfigStrings converts a comma separated string, or a list from a config file, to a string slice.
+func toConfigStrings(value interface{}) ([]string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return cast.ToStringSliceE(value)
+	}
+	var strs []string
+	for _, str := range strings.Split(s, ",") {
+		if str = strings.TrimSpace(str); 0 < len(str) {
+			strs = append(strs, str)
+		}
+	}
+	return strs, nil
+}
+
+// setConfigMap decodes a map of structs from a JSON or YAML string, or from a config file value.
+// Unknown keys of the structs are rejected.
+func setConfigMap(v reflect.Value, value interface{}) error {
+	var data []byte
+	if s, ok := value.(string); ok {
+		data = []byte(s)
+	} else {
+		var err error
+		if data, err = yaml.Marshal(value); err != nil {
+			return err
+		}
+	}
+	m := reflect.New(v.Type())
+	if 0 < len(bytes.TrimSpace(data)) {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(m.Interface()); err != nil {
+			return err
+		}
+	}
+	v.Set(m.Elem())
+	return nil
+}
+
+// loadConfigFile applies a yaml, json or toml config file, unknown keys are rejected.
+func loadConfigFile(config *Config, fields []configField, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".yaml", ".yml", ".json":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("unsupported config file extension: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	fieldsByKey := make(map[string]configField, len(fields))
+	for _, field := range fields {
+		fieldsByKey[field.key] = field
+	}
+	var unknownKeys []string
+	for key, value := range values {
+		field, ok := fieldsByKey[key]
+		if !ok {
+			unknownKeys = append(unknownKeys, key)
+			continue
+		}
+		if err := field.set(config, value); err != nil {
+			return fmt.Errorf("invalid config file key %s: %w", key, err)
+		}
+	}
+	if 0 < len(unknownKeys) {
+		sort.Strings(unknownKeys)
+		return fmt.Errorf("unknown config file keys: %s", strings.Join(unknownKeys, ", "))
+	}
+	return nil
+}
+
+// configFlagValue a flag.Value remembering the raw value, converted like an env var.
+type configFlagValue struct {
+	value  string
+	isBool bool
+}
+
+func (v *configFlagValue) String() string {
+	return v.value
+}
+
+func (v *configFlagValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *configFlagValue) IsBoolFlag() bool {
+	return v.isBool
 }
@@ -0,0 +1,298 @@
+package traefik_github_oauth_server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConfigLoader(args []string, env map[string]string) *ConfigLoader {
+	return &ConfigLoader{
+		Args: args,
+		Getenv: func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		},
+	}
+}
+
+var requiredEnv = map[string]string{
+	"API_BASE_URL":               "http://oauth.example.com",
+	"GITHUB_OAUTH_CLIENT_ID":     "client-id",
+	"GITHUB_OAUTH_CLIENT_SECRET": "client-secret",
+}
+
+func TestConfigLoader_Load_Defaults(t *testing.T) {
+	// execution
+	config, err := newTestConfigLoader(nil, requiredEnv).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, ":80", config.ServerAddress)
+	assert.Equal(t, 10*time.Minute, config.AuthRequestTTL)
+	assert.Equal(t, "memory", config.AuthRequestStore)
+}
+
+func TestConfigLoader_Load_Precedence(t *testing.T) {
+	// setup
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	_ = os.WriteFile(configFile, []byte("server_address: ':8080'\nlog_level: warn\nauth_request_ttl: 5m\n"), 0o600)
+	env := map[string]string{"LOG_LEVEL": "error"}
+	for key, value := range requiredEnv {
+		env[key] = value
+	}
+
+	// execution
+	config, err := newTestConfigLoader([]string{"--config-file", configFile, "--log-level", "debug"}, env).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", config.ServerAddress)
+	assert.Equal(t, 5*time.Minute, config.AuthRequestTTL)
+	assert.Equal(t, "debug", config.LogLevel)
+}
+
+func TestConfigLoader_Load_Toml(t *testing.T) {
+	// setup
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	_ = os.WriteFile(configFile, []byte("debug_mode = true\nrate_limit_ip_rps = 2.5\n"), 0o600)
+
+	// execution
+	config, err := newTestConfigLoader([]string{"--config-file", configFile}, requiredEnv).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	assert.True(t, config.DebugMode)
+	assert.Equal(t, 2.5, config.RateLimitIPRPS)
+}
+
+func TestConfigLoader_Load_UnknownKey(t *testing.T) {
+	// setup
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	_ = os.WriteFile(configFile, []byte("sever_address: ':8080'\n"), 0o600)
+
+	// execution
+	_, err := newTestConfigLoader([]string{"--config-file", configFile}, requiredEnv).Load()
+
+	// assertion
+	assert.ErrorContains(t, err, "unknown config file keys: sever_address")
+}
+
+func TestConfigLoader_Load_Invalid(t *testing.T) {
+	// execution
+	_, err := newTestConfigLoader(nil, map[string]string{
+		"API_BASE_URL":       "oauth.example.com",
+		"AUTH_REQUEST_STORE": "sql",
+	}).Load()
+
+	// assertion
+	assert.ErrorContains(t, err, "GITHUB_OAUTH_CLIENT_ID and GITHUB_OAUTH_CLIENT_SECRET, or OAUTH_CLIENTS, are required")
+	assert.ErrorContains(t, err, "API_BASE_URL must be an absolute http(s) URL")
+	assert.ErrorContains(t, err, "AUTH_REQUEST_STORE must be one of memory, file, redis")
+}
+
+func TestConfigLoader_Load_OAuthClients(t *testing.T) {
+	// setup
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	_ = os.WriteFile(configFile, []byte(`
+oauth_clients:
+  sales:
+    client_id: sales-client-id
+    client_secret: sales-client-secret
+    api_base_url: https://oauth.sales.example.com
+`), 0o600)
+	env := map[string]string{
+		"API_BASE_URL":  "https://oauth.example.com",
+		"OAUTH_CLIENTS": `{"ops": {"client_id": "ops-client-id", "client_secret": "ops-client-secret"}}`,
+	}
+
+	// execution
+	config, err := newTestConfigLoader([]string{"--config-file", configFile}, env).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	clients := config.OAuthClientConfigs()
+	assert.Len(t, clients, 1)
+	assert.Equal(t, "https://oauth.example.com", clients["ops"].ApiBaseURL)
+
+	// execution
+	config, err = newTestConfigLoader([]string{"--config-file", configFile}, map[string]string{}).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, "https://oauth.sales.example.com", config.OAuthClientConfigs()["sales"].ApiBaseURL)
+}
+
+func TestConfigLoader_Load_GitHubApp(t *testing.T) {
+	// setup
+	env := map[string]string{
+		"GITHUB_CLIENT_TYPE":       "github_app",
+		"GITHUB_APP_INSTALLATIONS": "42, acme",
+		"OAUTH_CLIENTS":            `{"ops": {"client_id": "ops-client-id", "client_secret": "ops-client-secret", "client_type": "oauth_app", "installations": []}}`,
+	}
+	for key, value := range requiredEnv {
+		env[key] = value
+	}
+
+	// execution
+	config, err := newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	clients := config.OAuthClientConfigs()
+	assert.Equal(t, CLIENT_TYPE_GITHUB_APP, clients[DefaultOAuthClientName].ClientType)
+	assert.Equal(t, []string{"42", "acme"}, clients[DefaultOAuthClientName].Installations)
+	assert.Equal(t, CLIENT_TYPE_OAUTH_APP, clients["ops"].ClientType)
+	assert.Empty(t, clients["ops"].Installations)
+
+	// execution
+	env["GITHUB_CLIENT_TYPE"] = "oauth_app"
+	_, err = newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.ErrorContains(t, err, `the installations of OAuth client "default" require the github_app client type`)
+}
+
+func TestConfigLoader_Load_Scopes(t *testing.T) {
+	// setup
+	env := map[string]string{
+		"OAUTH_SCOPES":         "read:org",
+		"OAUTH_ALLOWED_SCOPES": "user:email",
+		"OAUTH_CLIENTS":        `{"ops": {"client_id": "ops-client-id", "client_secret": "ops-client-secret", "scopes": [], "allow_signup": false}}`,
+	}
+	for key, value := range requiredEnv {
+		env[key] = value
+	}
+
+	// execution
+	config, err := newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	clients := config.OAuthClientConfigs()
+	assert.Equal(t, []string{"read:org"}, clients[DefaultOAuthClientName].Scopes)
+	assert.Equal(t, []string{"user:email"}, clients[DefaultOAuthClientName].AllowedScopes)
+	assert.True(t, *clients[DefaultOAuthClientName].AllowSignup)
+	assert.Empty(t, clients["ops"].Scopes)
+	assert.False(t, *clients["ops"].AllowSignup)
+
+	// execution
+	oAuthClients, err := NewOAuthClients(config)
+
+	// assertion
+	assert.NoError(t, err)
+	scopes, err := oAuthClients[DefaultOAuthClientName].LoginScopes([]string{"user:email", "read:org"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"read:org", "user:email"}, scopes)
+	_, err = oAuthClients[DefaultOAuthClientName].LoginScopes([]string{"repo"})
+	assert.ErrorIs(t, err, ErrScopeNotAllowed)
+
+	// execution
+	env["GITHUB_CLIENT_TYPE"] = "github_app"
+	_, err = newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.ErrorContains(t, err, `OAuth client "default" of the github_app client type has no scopes`)
+}
+
+func TestConfigLoader_Load_LoginPage(t *testing.T) {
+	// setup
+	env := map[string]string{
+		"LOGIN_PAGE":          "true",
+		"LOGIN_PAGE_LOGO_URL": "https://example.com/logo.png",
+		"OAUTH_CLIENTS":       `{"ops": {"client_id": "ops-client-id", "client_secret": "ops-client-secret", "login_page": false, "login_page_message": "Ops only"}}`,
+	}
+	for key, value := range requiredEnv {
+		env[key] = value
+	}
+
+	// execution
+	config, err := newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	clients := config.OAuthClientConfigs()
+	assert.True(t, *clients[DefaultOAuthClientName].LoginPage)
+	assert.Equal(t, "https://example.com/logo.png", clients[DefaultOAuthClientName].LoginPageLogoURL)
+	assert.False(t, *clients["ops"].LoginPage)
+	assert.Equal(t, "https://example.com/logo.png", clients["ops"].LoginPageLogoURL)
+	assert.Equal(t, "Ops only", clients["ops"].LoginPageMessage)
+
+	// execution
+	env["LOGIN_PAGE_LOGO_URL"] = "javascript:alert(1)"
+	_, err = newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.ErrorContains(t, err, `the login page logo url of OAuth client "default" must be an absolute http(s) URL`)
+}
+
+func TestConfigLoader_Load_Provider(t *testing.T) {
+	// setup
+	env := map[string]string{
+		"OAUTH_PROVIDER": "gitea",
+		"OAUTH_CLIENTS":  `{"sso": {"client_id": "sso-client-id", "client_secret": "sso-client-secret", "provider": "oidc", "provider_url": "https://sso.example.com"}}`,
+	}
+	for key, value := range requiredEnv {
+		env[key] = value
+	}
+
+	// execution
+	_, err := newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.ErrorContains(t, err, `the provider url of OAuth client "default" is required by the gitea provider`)
+
+	// execution
+	env["OAUTH_PROVIDER_URL"] = "https://gitea.example.com"
+	config, err := newTestConfigLoader(nil, env).Load()
+
+	// assertion
+	assert.NoError(t, err)
+	clients := config.OAuthClientConfigs()
+	assert.Equal(t, "gitea", clients[DefaultOAuthClientName].Provider)
+	assert.Equal(t, "oidc", clients["sso"].Provider)
+	assert.Equal(t, "https://sso.example.com", clients["sso"].ProviderURL)
+}
+
+func TestConfig_RestartRequiredChanges(t *testing.T) {
+	// setup
+	current, _ := newTestConfigLoader(nil, requiredEnv).Load()
+	next := *current
+	next.ServerAddress = ":8080"
+	next.LogLevel = "debug"
+	next.DebugMode = true
+
+	// execution
+	changed := current.RestartRequiredChanges(&next)
+
+	// assertion
+	assert.Equal(t, []string{"SERVER_ADDRESS", "DEBUG_MODE"}, changed)
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	// setup
+	config := &Config{
+		ApiBaseURL:              "http://oauth.example.com",
+		GitHubOAuthClientSecret: "client-secret",
+		AuthRequestStoreRedis:   "redis://:password@localhost:6379/0",
+		AuthRequestTTL:          10 * time.Minute,
+		OAuthClients: map[string]OAuthClientConfig{
+			"sales": {ClientID: "sales-id", ClientSecret: "sales-secret"},
+		},
+	}
+
+	// execution
+	redacted := config.Redacted()
+
+	// assertion
+	assert.Equal(t, "http://oauth.example.com", redacted["api_base_url"])
+	assert.Equal(t, REDACTED, redacted["github_oauth_client_secret"])
+	assert.Equal(t, "", redacted["api_secret_key"])
+	assert.Equal(t, "redis://:xxxxx@localhost:6379/0", redacted["auth_request_store_redis_url"])
+	assert.Equal(t, "10m0s", redacted["auth_request_ttl"])
+	assert.Equal(t, REDACTED, redacted["oauth_clients"].(map[string]OAuthClientConfig)["sales"].ClientSecret)
+	assert.Equal(t, "client-secret", config.GitHubOAuthClientSecret)
+}
@@ -0,0 +1,125 @@
+package traefik_github_oauth_server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnixSocketAddressPrefix the prefix of a SERVER_ADDRESS listening on a Unix domain socket, e.g. unix:/run/oauth.sock.
+const UnixSocketAddressPrefix = "unix:"
+
+// UnixSocketPath returns the socket path of a unix: server address.
+func UnixSocketPath(address string) (string, bool) {
+	if !strings.HasPrefix(address, UnixSocketAddressPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(address, UnixSocketAddressPrefix), true
+}
+
+// NewListener listens on the TCP address, or on the Unix domain socket of a unix: address.
+// A socket file left behind by a previous process is removed first.
+func NewListener(address string) (net.Listener, error) {
+	path, ok := UnixSocketPath(address)
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// NewTLSConfig creates the TLS config of the server, nil without TLS_CERT_FILE.
+// With TLS_CLIENT_CA_FILE the clients may present a certificate, NewClientCertificateMiddleware requires it per route.
+func NewTLSConfig(config *Config) (*tls.Config, error) {
+	if len(config.TLSCertFile) == 0 {
+		return nil, nil
+	}
+	reloader, err := NewCertificateReloader(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if 0 < len(config.TLSClientCAFile) {
+		pem, err := os.ReadFile(config.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", config.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// CertificateReloader serves a certificate and key pair, loaded again when either file changes,
+// so a renewed certificate is used without a restart.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// NewCertificateReloader loads the certificate and key pair.
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+// The current certificate is kept while the files fail to load, e.g. when the key is not written yet.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.reload()
+	return r.cert, nil
+}
+
+// reload loads the pair again if a file changed since it was last loaded.
+func (r *CertificateReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
@@ -0,0 +1,101 @@
+package traefik_github_oauth_server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSelfSignedCertificate(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the modification times are set explicitly, the file system may not tell apart quick writes
+	_ = os.Chtimes(certFile, modTime, modTime)
+	_ = os.Chtimes(keyFile, modTime, modTime)
+}
+
+func commonNameOf(t *testing.T, r *CertificateReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateReloader_GetCertificate(t *testing.T) {
+	// setup
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	now := time.Now()
+	writeSelfSignedCertificate(t, certFile, keyFile, "first", now.Add(-time.Minute))
+	r, err := NewCertificateReloader(certFile, keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", commonNameOf(t, r))
+
+	// execution & assertion
+	writeSelfSignedCertificate(t, certFile, keyFile, "renewed", now)
+	assert.Equal(t, "renewed", commonNameOf(t, r))
+
+	// a broken pair keeps the current certificate
+	assert.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	_ = os.Chtimes(keyFile, now.Add(time.Minute), now.Add(time.Minute))
+	assert.Equal(t, "renewed", commonNameOf(t, r))
+}
+
+func TestNewListener_UnixSocket(t *testing.T) {
+	// setup
+	path := filepath.Join(t.TempDir(), "server.sock")
+	stale, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	// leave the socket file behind like a killed process
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.NoError(t, stale.Close())
+
+	// execution
+	listener, err := NewListener(UnixSocketAddressPrefix + path)
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, "unix", listener.Addr().Network())
+	assert.NoError(t, listener.Close())
+}
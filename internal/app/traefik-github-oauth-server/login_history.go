@@ -0,0 +1,52 @@
+package traefik_github_oauth_server
+
+import (
+	"sync"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit"
+)
+
+// DefaultLoginHistorySize the number of recent logins kept for the admin api.
+const DefaultLoginHistorySize = 100
+
+// LoginHistory keeps the most recent successful logins in memory, the oldest are dropped first.
+type LoginHistory struct {
+	mu     sync.Mutex
+	logins []audit.Event
+	next   int
+	full   bool
+}
+
+// NewLoginHistory creates a LoginHistory keeping size logins.
+func NewLoginHistory(size int) *LoginHistory {
+	return &LoginHistory{logins: make([]audit.Event, size)}
+}
+
+// Add records a login.
+func (h *LoginHistory) Add(login audit.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.logins) == 0 {
+		return
+	}
+	h.logins[h.next] = login
+	h.next = (h.next + 1) % len(h.logins)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Recent returns the recorded logins, the most recent first.
+func (h *LoginHistory) Recent() []audit.Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := h.next
+	if h.full {
+		n = len(h.logins)
+	}
+	recent := make([]audit.Event, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, h.logins[(h.next-i+len(h.logins))%len(h.logins)])
+	}
+	return recent
+}
@@ -0,0 +1,26 @@
+package traefik_github_oauth_server
+
+import (
+	"testing"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginHistory_Recent(t *testing.T) {
+	// setup
+	h := NewLoginHistory(2)
+
+	// execution
+	empty := h.Recent()
+	h.Add(audit.Event{UserLogin: "alice"})
+	one := h.Recent()
+	h.Add(audit.Event{UserLogin: "bob"})
+	h.Add(audit.Event{UserLogin: "carol"})
+	wrapped := h.Recent()
+
+	// assertion
+	assert.Empty(t, empty)
+	assert.Equal(t, []audit.Event{{UserLogin: "alice"}}, one)
+	assert.Equal(t, []audit.Event{{UserLogin: "carol"}, {UserLogin: "bob"}}, wrapped)
+}
@@ -1,33 +1,179 @@
 package traefik_github_oauth_server
 
 import (
+	"context"
+	"crypto/subtle"
 	"fmt"
+	"math"
 	"net/http"
 	"time"
 
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/ratelimit"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/requestid"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 )
 
+//goland:noinspection GoSnakeCaseUsage
+const (
+	// CONTEXT_KEY_REQUEST_ID the gin context key of the request id.
+	CONTEXT_KEY_REQUEST_ID = "request_id"
+	// CONTEXT_KEY_RID the gin context key of the auth request a handler started, logged with the request.
+	CONTEXT_KEY_RID = "rid"
+	// CONTEXT_KEY_API_KEY the gin context key of the secret key a request authenticated with, api or admin.
+	CONTEXT_KEY_API_KEY = "api_key"
+)
+
+// requestLoggerKey the context key of the logger of a request.
+type requestLoggerKey struct{}
+
+// NewRequestIDMiddleware returns a middleware that reads the request id of the X-Request-Id header, e.g. sent by the
+// middleware plugin, or generates one. The id is echoed in the response, and App.RequestLogger logs it.
+func NewRequestIDMiddleware(logger *zerolog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestid.FromHeader(c.GetHeader(constant.HTTP_HEADER_X_REQUEST_ID))
+		c.Set(CONTEXT_KEY_REQUEST_ID, id)
+		c.Header(constant.HTTP_HEADER_X_REQUEST_ID, id)
+		requestLogger := logger.With().Str("request_id", id).Logger()
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestLoggerKey{}, &requestLogger))
+		c.Next()
+	}
+}
+
 // NewApiSecretKeyMiddleware returns a middleware that checks the api secret key.
-func NewApiSecretKeyMiddleware(apiSecretKey string) gin.HandlerFunc {
+// The key is read on every request, so it follows config reloads.
+func NewApiSecretKeyMiddleware(getApiSecretKey func() string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		apiSecretKey := getApiSecretKey()
 		if len(apiSecretKey) == 0 {
 			c.Next()
 			return
 		}
 		reqSecretKey := c.GetHeader(constant.HTTP_HEADER_AUTHORIZATION)
 		if reqSecretKey != fmt.Sprintf("%s %s", constant.AUTHORIZATION_PREFIX_TOKEN, apiSecretKey) {
-			c.JSON(http.StatusUnauthorized, model.ResponseError{
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ResponseError{
 				Message: "invalid api secret key",
 			})
+			return
+		}
+		c.Set(CONTEXT_KEY_API_KEY, "api")
+		c.Next()
+	}
+}
+
+// NewAdminSecretKeyMiddleware returns a middleware that checks the admin secret key.
+// The admin api is hidden while no key is configured, the key is read on every request.
+func NewAdminSecretKeyMiddleware(getAdminSecretKey func() string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminSecretKey := getAdminSecretKey()
+		if len(adminSecretKey) == 0 {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		reqSecretKey := c.GetHeader(constant.HTTP_HEADER_AUTHORIZATION)
+		if subtle.ConstantTimeCompare(
+			[]byte(reqSecretKey),
+			[]byte(fmt.Sprintf("%s %s", constant.AUTHORIZATION_PREFIX_TOKEN, adminSecretKey)),
+		) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ResponseError{
+				Message: "invalid admin secret key",
+			})
+			return
+		}
+		c.Set(CONTEXT_KEY_API_KEY, "admin")
+		c.Next()
+	}
+}
+
+// NewClientCertificateMiddleware returns a middleware that requires a client certificate verified against TLS_CLIENT_CA_FILE,
+// it lets every request through unless required.
+func NewClientCertificateMiddleware(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if required && (c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, model.ResponseError{
+				Message: "client certificate required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// NewTracingMiddleware returns a middleware that traces every request in a server span,
+// continuing the trace of the traceparent header, e.g. sent by the middleware plugin.
+func NewTracingMiddleware(tracer *tracing.Tracer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tracer == nil {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		if parent, ok := tracing.ParseTraceparent(c.GetHeader(constant.HTTP_HEADER_TRACEPARENT)); ok {
+			ctx = tracing.ContextWithSpanContext(ctx, parent)
+		}
+		route := c.FullPath()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+		ctx, span := tracer.Start(ctx, c.Request.Method+" "+route, tracing.SPAN_KIND_SERVER)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.status_code", c.Writer.Status())
+		span.SetAttribute("client.address", c.ClientIP())
+		span.SetAttribute("http.request_id", c.GetString(CONTEXT_KEY_REQUEST_ID))
+		if http.StatusInternalServerError <= c.Writer.Status() {
+			span.SetError(fmt.Errorf("%s", http.StatusText(c.Writer.Status())))
+		}
+	}
+}
+
+// NewRateLimitMiddleware returns a middleware that limits the requests per client IP.
+func NewRateLimitMiddleware(ipLimiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if allowed, retryAfter := ipLimiter.Allow(c.ClientIP()); !allowed {
+			AbortWithTooManyRequests(c, retryAfter)
+			return
 		}
 		c.Next()
 	}
 }
 
+// NewApiKeyRateLimitMiddleware returns a middleware that limits the requests per api key, it follows the secret key
+// middleware of the route so only the keys that authenticated get a bucket. The requests of the servers behind one IP,
+// e.g. every Traefik replica, share no IP bucket. Without a configured key the requests are limited per client IP.
+func NewApiKeyRateLimitMiddleware(ipLimiter, apiKeyLimiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var allowed bool
+		var retryAfter time.Duration
+		if apiKey := c.GetString(CONTEXT_KEY_API_KEY); 0 < len(apiKey) {
+			allowed, retryAfter = apiKeyLimiter.Allow(apiKey)
+		} else {
+			allowed, retryAfter = ipLimiter.Allow(c.ClientIP())
+		}
+		if !allowed {
+			AbortWithTooManyRequests(c, retryAfter)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortWithTooManyRequests aborts the request with 429 and a Retry-After header.
+func AbortWithTooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	c.Header(constant.HTTP_HEADER_RETRY_AFTER, fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, model.ResponseError{
+		Message: "too many requests",
+	})
+}
+
 // NewLoggerMiddleware returns a middleware that logs the request.
 func NewLoggerMiddleware(logger *zerolog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -46,6 +192,10 @@ func NewLoggerMiddleware(logger *zerolog.Logger) gin.HandlerFunc {
 		method := c.Request.Method
 		statusCode := c.Writer.Status()
 		errorMessage := c.Errors.ByType(gin.ErrorTypePrivate).String()
+		rid := c.GetString(CONTEXT_KEY_RID)
+		if len(rid) == 0 {
+			rid = c.Query(constant.QUERY_KEY_REQUEST_ID)
+		}
 
 		if raw != "" {
 			path = path + "?" + raw
@@ -59,7 +209,11 @@ func NewLoggerMiddleware(logger *zerolog.Logger) gin.HandlerFunc {
 			Str("client_ip", clientIP).
 			Str("method", method).
 			Str("path", path).
+			Str("request_id", c.GetString(CONTEXT_KEY_REQUEST_ID)).
 			Func(func(e *zerolog.Event) {
+				if rid != "" {
+					e.Str("rid", rid)
+				}
 				if errorMessage != "" {
 					e.Str("error", errorMessage)
 				}
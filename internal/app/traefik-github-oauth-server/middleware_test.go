@@ -0,0 +1,62 @@
+package traefik_github_oauth_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewApiKeyRateLimitMiddleware(t *testing.T) {
+	// setup
+	gin.SetMode(gin.TestMode)
+	ipLimiter := ratelimit.NewLimiter(1, 1)
+	apiKeyLimiter := ratelimit.NewLimiter(1, 2)
+	engine := gin.New()
+	engine.GET("/",
+		NewApiSecretKeyMiddleware(func() string { return "secret" }),
+		NewApiKeyRateLimitMiddleware(ipLimiter, apiKeyLimiter),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+	serve := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(constant.HTTP_HEADER_AUTHORIZATION, authorization)
+		rw := httptest.NewRecorder()
+		engine.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	// execution & assertion
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, serve("token random"))
+	}
+	assert.Equal(t, http.StatusOK, serve("token secret"))
+	assert.Equal(t, http.StatusOK, serve("token secret"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("token secret"))
+}
+
+func TestNewRateLimitMiddleware_TrustedProxies(t *testing.T) {
+	// setup
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	assert.NoError(t, engine.SetTrustedProxies(nil))
+	engine.GET("/",
+		NewRateLimitMiddleware(ratelimit.NewLimiter(1, 1)),
+		func(c *gin.Context) { c.Status(http.StatusOK) },
+	)
+	serve := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rw := httptest.NewRecorder()
+		engine.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	// execution & assertion
+	assert.Equal(t, http.StatusOK, serve("203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("203.0.113.2"))
+}
@@ -1,8 +1,35 @@
 package model
 
+import "time"
+
 type RequestGenerateOAuthPageURL struct {
 	RedirectURI string `json:"redirect_uri" binding:"required"`
 	AuthURL     string `json:"auth_url" binding:"required"`
+	// ClientIP the IP of the user being authenticated, used for auditing.
+	ClientIP string `json:"client_ip,omitempty"`
+	// Client the name of the OAuth client to log in with, empty for the default client.
+	Client string `json:"client,omitempty"`
+	// ForwardAccessToken the middleware forwards the provider access token upstream, the result carries it sealed.
+	ForwardAccessToken bool `json:"forward_access_token,omitempty"`
+	// Scopes the scopes requested in addition to the ones of the client, they must be allowed by the server.
+	Scopes []string `json:"scopes,omitempty"`
+	// DisallowSignup hides the sign up option of the GitHub login page.
+	DisallowSignup bool `json:"disallow_signup,omitempty"`
+	// LoginHint the account the user is expected to log in with.
+	LoginHint string `json:"login_hint,omitempty"`
+	// LoginPage the sign-in page of the middleware, nil to follow the OAuth client.
+	LoginPage *LoginPage `json:"login_page,omitempty"`
+}
+
+// LoginPage the sign-in page shown before the redirect to the provider.
+type LoginPage struct {
+	// Enabled shows the page even if the OAuth client does not.
+	Enabled bool `json:"enabled,omitempty"`
+	// AppName the application signed into, defaults to its host.
+	AppName string `json:"app_name,omitempty"`
+	// LogoURL and Message replace the ones of the OAuth client.
+	LogoURL string `json:"logo_url,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
 type ResponseGenerateOAuthPageURL struct {
@@ -10,8 +37,16 @@ type ResponseGenerateOAuthPageURL struct {
 }
 
 type RequestRedirect struct {
-	RID  string `form:"rid" url:"rid" binding:"required"`
-	Code string `form:"code" url:"code" binding:"required"`
+	// RID the request id, empty in stateless mode where the auth request is sealed in State.
+	RID   string `form:"rid" url:"rid"`
+	Code  string `form:"code" url:"code" binding:"required"`
+	State string `form:"state" url:"state"`
+}
+
+// RequestLoginPage the sign-in page names the auth request like the redirect, by rid or by state in stateless mode.
+type RequestLoginPage struct {
+	RID   string `form:"rid" url:"rid"`
+	State string `form:"state" url:"state"`
 }
 
 type RequestGetAuthResult struct {
@@ -22,6 +57,13 @@ type ResponseGetAuthResult struct {
 	RedirectURI     string `json:"redirect_uri"`
 	GitHubUserID    string `json:"github_user_id"`
 	GitHubUserLogin string `json:"github_user_login"`
+	// Provider the identity provider of the user, e.g. github or gitea.
+	Provider string   `json:"provider,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	// SessionToken the session token of the user signed by the server, the middleware sets it as its cookie.
+	SessionToken string `json:"session_token,omitempty"`
+	// SealedAccessToken the provider token of the user sealed by the server, empty if the forwarding is disabled.
+	SealedAccessToken string `json:"sealed_access_token,omitempty"`
 }
 
 type ResponseError struct {
@@ -29,8 +71,103 @@ type ResponseError struct {
 }
 
 type AuthRequest struct {
-	RedirectURI     string `json:"redirect_uri"`
-	AuthURL         string `json:"auth_url"`
-	GitHubUserID    string `json:"github_user_id"`
-	GitHubUserLogin string `json:"github_user_login"`
+	RedirectURI string `json:"redirect_uri"`
+	AuthURL     string `json:"auth_url"`
+	ClientIP    string `json:"client_ip"`
+	Client      string `json:"client,omitempty"`
+	// ForwardAccessToken keep the provider token of the user for the middleware, see SealedAccessToken.
+	ForwardAccessToken bool `json:"forward_access_token,omitempty"`
+	// Scopes the scopes of the login the user must grant.
+	Scopes []string `json:"scopes,omitempty"`
+	// DisallowSignup and LoginHint the options of the OAuth page, kept to build it again from the sign-in page.
+	DisallowSignup bool   `json:"disallow_signup,omitempty"`
+	LoginHint      string `json:"login_hint,omitempty"`
+	// LoginPage the sign-in page shown before the OAuth page, nil if there is none.
+	LoginPage *LoginPage `json:"login_page,omitempty"`
+	// GitHubUserID and GitHubUserLogin identify the user on any Provider, the names are kept for compatibility.
+	GitHubUserID    string   `json:"github_user_id"`
+	GitHubUserLogin string   `json:"github_user_login"`
+	Provider        string   `json:"provider,omitempty"`
+	Groups          []string `json:"groups,omitempty"`
+	// Nonce and ExpiresAt protect sealed auth requests in stateless mode.
+	Nonce     string `json:"nonce,omitempty"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	// CreatedAt when the login started, unix seconds.
+	CreatedAt int64 `json:"created_at,omitempty"`
+	// SealedAccessToken the provider token of the user, sealed so it is never stored in clear.
+	SealedAccessToken string `json:"sealed_access_token,omitempty"`
+}
+
+type RequestDeviceCode struct {
+	// Client the name of the OAuth client to log in with, empty for the default client.
+	Client string `form:"client" json:"client"`
+}
+
+// ResponseDeviceCode the device authorization response of RFC 8628,
+// the device code is sealed by the server and only valid with it.
+type ResponseDeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+type RequestDeviceToken struct {
+	DeviceCode string `form:"device_code" json:"device_code" binding:"required"`
+}
+
+type ResponseDeviceToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// ResponseDeviceError the error response of RFC 8628, e.g. authorization_pending.
+type ResponseDeviceError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// RequestAccessToken the middleware asks for the access token sealed in the cookie of a user.
+type RequestAccessToken struct {
+	SealedAccessToken string `json:"sealed_access_token" binding:"required"`
+}
+
+type ResponseAccessToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type,omitempty"`
+	// UserID the user the token belongs to.
+	UserID string `json:"user_id"`
+	// ExpiresAt unix seconds, 0 for a token that does not expire.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// SealedAccessToken the refreshed token, set only if the token was refreshed, it replaces the cookie.
+	SealedAccessToken string `json:"sealed_access_token,omitempty"`
+}
+
+// RequestRevoke revokes every session, the sessions of a user, or a single session by its jti.
+type RequestRevoke struct {
+	All bool `json:"all"`
+	// Provider the provider of the user, defaults to github.
+	Provider string `json:"provider"`
+	UserID   string `json:"user_id"`
+	JTI      string `json:"jti"`
+	// Before revokes the sessions issued before it, defaults to now.
+	Before *time.Time `json:"before"`
+}
+
+// AdminAuthRequest a pending auth request, as listed by the admin api.
+type AdminAuthRequest struct {
+	RID        string `json:"rid"`
+	AgeSeconds int64  `json:"age_seconds"`
+	Host       string `json:"host"`
+	Client     string `json:"client,omitempty"`
+	ClientIP   string `json:"client_ip,omitempty"`
+	// Completed the provider redirected back, the middleware did not claim the result yet.
+	Completed bool `json:"completed"`
+}
+
+type ResponseAdminAuthRequests struct {
+	AuthRequests []AdminAuthRequest `json:"auth_requests"`
 }
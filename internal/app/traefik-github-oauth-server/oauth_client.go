@@ -0,0 +1,138 @@
+package traefik_github_oauth_server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/scylladb/go-set/strset"
+)
+
+// DefaultOAuthClientName the name of the client configured by GITHUB_OAUTH_CLIENT_ID and GITHUB_OAUTH_CLIENT_SECRET,
+// used when the middleware does not choose a client.
+const DefaultOAuthClientName = constant.OAUTH_CLIENT_NAME_DEFAULT
+
+const (
+	// CLIENT_TYPE_OAUTH_APP a GitHub OAuth App.
+	CLIENT_TYPE_OAUTH_APP = "oauth_app"
+	// CLIENT_TYPE_GITHUB_APP a GitHub App, logging in with its user authorization flow.
+	CLIENT_TYPE_GITHUB_APP = "github_app"
+)
+
+// ErrScopeNotAllowed a middleware requested a scope the OAuth client does not allow.
+var ErrScopeNotAllowed = errors.New("scope not allowed")
+
+// OAuthClient a named OAuth application registered with an identity provider.
+type OAuthClient struct {
+	Name string
+	// ProviderType the type of Provider, e.g. github or gitlab.
+	ProviderType string
+	Provider     provider.Provider
+	// ApiBaseURL the base URL of the server the provider redirects back to.
+	ApiBaseURL string
+	// Scopes the scopes of every login, AllowedScopes the upper bound of the scopes a middleware may add.
+	Scopes        []string
+	AllowedScopes *strset.Set
+	AllowSignup   bool
+	// LoginPage shows the sign-in page before the redirect to the provider, with the logo and message if set.
+	LoginPage        bool
+	LoginPageLogoURL string
+	LoginPageMessage string
+}
+
+// LoginScopes returns the scopes of a login: the scopes of the client and the ones the middleware requested,
+// which must be allowed scopes.
+func (c *OAuthClient) LoginScopes(requested []string) ([]string, error) {
+	scopeSet := strset.New(c.Scopes...)
+	scopes := append([]string{}, c.Scopes...)
+	for _, scope := range requested {
+		if scopeSet.Has(scope) {
+			continue
+		}
+		if !c.AllowedScopes.Has(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrScopeNotAllowed, scope)
+		}
+		scopeSet.Add(scope)
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// NewOAuthClients creates every OAuth client of the config by name.
+func NewOAuthClients(config *Config) (map[string]*OAuthClient, error) {
+	clientConfigs := config.OAuthClientConfigs()
+	clients := make(map[string]*OAuthClient, len(clientConfigs))
+	for name, clientConfig := range clientConfigs {
+		client, err := newOAuthClient(name, clientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("OAuth client %q: %w", name, err)
+		}
+		clients[name] = client
+	}
+	return clients, nil
+}
+
+func newOAuthClient(name string, clientConfig OAuthClientConfig) (*OAuthClient, error) {
+	httpClient := http.DefaultClient
+	if 0 < len(clientConfig.CACertFile) {
+		var err error
+		httpClient, err = newHTTPClientWithCACertFile(clientConfig.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	p, err := provider.New(provider.Options{
+		Type:          clientConfig.Provider,
+		ClientID:      clientConfig.ClientID,
+		ClientSecret:  clientConfig.ClientSecret,
+		URL:           clientConfig.ProviderURL,
+		AuthURL:       clientConfig.AuthURL,
+		TokenURL:      clientConfig.TokenURL,
+		ApiURL:        clientConfig.GitHubApiURL,
+		GitHubApp:     clientConfig.ClientType == CLIENT_TYPE_GITHUB_APP,
+		Installations: clientConfig.Installations,
+		HTTPClient:    httpClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthClient{
+		Name:         name,
+		ProviderType: clientConfig.Provider,
+		Provider:     p,
+		ApiBaseURL:   clientConfig.ApiBaseURL,
+
+		Scopes:        clientConfig.Scopes,
+		AllowedScopes: strset.New(clientConfig.AllowedScopes...),
+		AllowSignup:   clientConfig.AllowSignup == nil || *clientConfig.AllowSignup,
+
+		LoginPage:        clientConfig.LoginPage != nil && *clientConfig.LoginPage,
+		LoginPageLogoURL: clientConfig.LoginPageLogoURL,
+		LoginPageMessage: clientConfig.LoginPageMessage,
+	}, nil
+}
+
+func newHTTPClientWithCACertFile(caCertFile string) (*http.Client, error) {
+	pem, err := os.ReadFile(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificate found in the CA cert file")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    rootCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+	return &http.Client{Transport: transport}, nil
+}
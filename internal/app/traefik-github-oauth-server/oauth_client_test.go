@@ -0,0 +1,94 @@
+package traefik_github_oauth_server
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+// newTestGitHubEnterpriseServer a GitHub Enterprise API over TLS, with a certificate of its own CA.
+func newTestGitHubEnterpriseServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/user" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"id":1,"login":"octocat"}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestEnterpriseConfig the config of a GitHub Enterprise server trusting the CA bundle.
+func newTestEnterpriseConfig(t *testing.T, serverURL, caCertFile string) *Config {
+	t.Helper()
+	env := map[string]string{
+		"GITHUB_AUTH_URL":     serverURL + "/login/oauth/authorize",
+		"GITHUB_TOKEN_URL":    serverURL + "/login/oauth/access_token",
+		"GITHUB_API_URL":      serverURL + "/api/v3",
+		"GITHUB_CA_CERT_FILE": caCertFile,
+	}
+	for key, value := range requiredEnv {
+		env[key] = value
+	}
+	config, err := newTestConfigLoader(nil, env).Load()
+	assert.NoError(t, err)
+	return config
+}
+
+func TestNewOAuthClients_CACertFile(t *testing.T) {
+	// setup
+	server := newTestGitHubEnterpriseServer(t)
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	_ = os.WriteFile(caCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600)
+	token := &oauth2.Token{AccessToken: "token"}
+
+	// execution
+	clients, err := NewOAuthClients(newTestEnterpriseConfig(t, server.URL, caCertFile))
+	untrustedClients, errUntrusted := NewOAuthClients(newTestEnterpriseConfig(t, server.URL, ""))
+
+	// assertion
+	assert.NoError(t, err)
+	user, err := clients[DefaultOAuthClientName].Provider.User(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, &provider.User{ID: "1", Login: "octocat"}, user)
+	assert.NoError(t, errUntrusted)
+	_, err = untrustedClients[DefaultOAuthClientName].Provider.User(context.Background(), token)
+	assert.ErrorContains(t, err, "certificate")
+}
+
+func TestNewOAuthClients_InvalidCACertFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "bad PEM", content: "-----BEGIN CERTIFICATE-----\nnot base64\n-----END CERTIFICATE-----\n"},
+		{name: "not PEM", content: "not a certificate"},
+		{name: "empty", content: ""},
+		{name: "no certificate", content: "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEAGb9ECWmEzf6FQbrBZ9w7lshQhqowtrbLDFw4rXAxZuE=\n-----END PUBLIC KEY-----\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+			_ = os.WriteFile(caCertFile, []byte(tt.content), 0o600)
+
+			// execution
+			_, err := NewOAuthClients(newTestEnterpriseConfig(t, "https://github.example.com", caCertFile))
+
+			// assertion
+			assert.ErrorContains(t, err, `OAuth client "default": no certificate found in the CA cert file`)
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// giteaPageLimit the page size of the Gitea list calls.
+const giteaPageLimit = 50
+
+// GiteaProvider logs in with a Gitea or Forgejo OAuth2 application.
+type GiteaProvider struct {
+	oauth2Provider
+	baseURL string
+}
+
+var _ Provider = (*GiteaProvider)(nil)
+
+// NewGiteaProvider creates a GiteaProvider.
+func NewGiteaProvider(opts Options) (*GiteaProvider, error) {
+	baseURL := strings.TrimSuffix(opts.URL, "/")
+	if len(baseURL) == 0 {
+		return nil, errors.New("the Gitea URL is required")
+	}
+	return &GiteaProvider{
+		oauth2Provider: oauth2Provider{
+			config: &oauth2.Config{
+				ClientID:     opts.ClientID,
+				ClientSecret: opts.ClientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  baseURL + "/login/oauth/authorize",
+					TokenURL: baseURL + "/login/oauth/access_token",
+				},
+			},
+			httpClient: opts.HTTPClient,
+		},
+		baseURL: baseURL,
+	}, nil
+}
+
+// User fetches the Gitea user.
+func (p *GiteaProvider) User(ctx context.Context, token *oauth2.Token) (*User, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := getJSON(ctx, p.client(ctx, token), p.baseURL+"/api/v1/user", &user); err != nil {
+		return nil, err
+	}
+	return &User{
+		ID:    strconv.FormatInt(user.ID, 10),
+		Login: user.Login,
+	}, nil
+}
+
+// Groups fetches the orgs of the user, and its teams as org/team.
+func (p *GiteaProvider) Groups(ctx context.Context, token *oauth2.Token) ([]string, error) {
+	client := p.client(ctx, token)
+	var groups []string
+	for page := 1; ; page++ {
+		var orgs []struct {
+			Username string `json:"username"`
+		}
+		if err := getJSON(ctx, client, p.pageURL("/api/v1/user/orgs", page), &orgs); err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			groups = append(groups, org.Username)
+		}
+		if len(orgs) < giteaPageLimit {
+			break
+		}
+	}
+	for page := 1; ; page++ {
+		var teams []struct {
+			Name         string `json:"name"`
+			Organization struct {
+				Username string `json:"username"`
+			} `json:"organization"`
+		}
+		if err := getJSON(ctx, client, p.pageURL("/api/v1/user/teams", page), &teams); err != nil {
+			return nil, err
+		}
+		for _, team := range teams {
+			groups = append(groups, team.Organization.Username+"/"+team.Name)
+		}
+		if len(teams) < giteaPageLimit {
+			break
+		}
+	}
+	return groups, nil
+}
+
+func (p *GiteaProvider) pageURL(path string, page int) string {
+	return fmt.Sprintf("%s%s?page=%d&limit=%d", p.baseURL, path, page, giteaPageLimit)
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestGiteaProvider(t *testing.T) {
+	// setup
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"id":7,"login":"alice"}`)
+	})
+	mux.HandleFunc("/api/v1/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{"username":"acme"}]`)
+	})
+	mux.HandleFunc("/api/v1/user/teams", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{"name":"Owners","organization":{"username":"acme"}}]`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	p, _ := NewGiteaProvider(Options{ClientID: "client-id", URL: server.URL + "/", HTTPClient: http.DefaultClient})
+	token := &oauth2.Token{AccessToken: "token"}
+
+	// execution
+	user, errUser := p.User(context.Background(), token)
+	groups, errGroups := p.Groups(context.Background(), token)
+
+	// assertion
+	assert.NoError(t, errUser)
+	assert.Equal(t, &User{ID: "7", Login: "alice"}, user)
+	assert.NoError(t, errGroups)
+	assert.Equal(t, []string{"acme", "acme/Owners"}, groups)
+}
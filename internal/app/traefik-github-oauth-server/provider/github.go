@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/google/go-github/v49/github"
+	"golang.org/x/oauth2"
+	oauth2github "golang.org/x/oauth2/github"
+)
+
+// INSTALLATION_ANY matches any installation of the GitHub App.
+const INSTALLATION_ANY = "*"
+
+const (
+	gitHubAuthPath       = "/login/oauth/authorize"
+	gitHubDeviceAuthPath = "/login/device/code"
+)
+
+// ErrInstallationAccessDenied the user cannot access any of the required installations of the GitHub App.
+var ErrInstallationAccessDenied = fmt.Errorf("%w: the user cannot access any required installation of the GitHub App", ErrAccessDenied)
+
+// GitHubProvider logs in with a GitHub OAuth App or GitHub App, on github.com or on a GitHub Enterprise instance.
+type GitHubProvider struct {
+	oauth2Provider
+	// apiURL the GitHub API base URL, nil for api.github.com.
+	apiURL        *url.URL
+	gitHubApp     bool
+	installations []string
+}
+
+var _ Provider = (*GitHubProvider)(nil)
+
+// NewGitHubProvider creates a GitHubProvider.
+func NewGitHubProvider(opts Options) (*GitHubProvider, error) {
+	endpoint := oauth2github.Endpoint
+	if 0 < len(opts.AuthURL) {
+		endpoint = oauth2.Endpoint{
+			AuthURL:  opts.AuthURL,
+			TokenURL: opts.TokenURL,
+		}
+	}
+	// the device flow endpoint is next to the authorize endpoint, on github.com as on GitHub Enterprise
+	var deviceAuthURL string
+	if strings.HasSuffix(endpoint.AuthURL, gitHubAuthPath) {
+		deviceAuthURL = strings.TrimSuffix(endpoint.AuthURL, gitHubAuthPath) + gitHubDeviceAuthPath
+	}
+	p := &GitHubProvider{
+		oauth2Provider: oauth2Provider{
+			config: &oauth2.Config{
+				ClientID:     opts.ClientID,
+				ClientSecret: opts.ClientSecret,
+				Endpoint:     endpoint,
+			},
+			deviceAuthURL: deviceAuthURL,
+			httpClient:    opts.HTTPClient,
+		},
+		gitHubApp:     opts.GitHubApp,
+		installations: opts.Installations,
+	}
+	if 0 < len(opts.ApiURL) {
+		apiURL, err := url.Parse(opts.ApiURL)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasSuffix(apiURL.Path, "/") {
+			apiURL.Path += "/"
+		}
+		p.apiURL = apiURL
+	}
+	return p, nil
+}
+
+// AuthCodeURL returns the URL of the GitHub login page, GitHub names the login hint login and supports allow_signup.
+// The scopes do not apply to GitHub Apps, their permissions are set on the app.
+func (p *GitHubProvider) AuthCodeURL(_ context.Context, state, redirectURI string, opts AuthCodeOptions) (string, error) {
+	params := p.authCodeParams(redirectURI, opts)
+	if 0 < len(opts.LoginHint) {
+		params = append(params, oauth2.SetAuthURLParam(constant.QUERY_KEY_LOGIN, opts.LoginHint))
+	}
+	if opts.DisallowSignup {
+		params = append(params, oauth2.SetAuthURLParam(constant.QUERY_KEY_ALLOW_SIGNUP, "false"))
+	}
+	return p.config.AuthCodeURL(state, params...), nil
+}
+
+// Client returns a GitHub API client authenticated with the token.
+func (p *GitHubProvider) Client(ctx context.Context, token *oauth2.Token) *github.Client {
+	gitHubClient := github.NewClient(p.client(ctx, token))
+	if p.apiURL != nil {
+		gitHubClient.BaseURL = p.apiURL
+	}
+	return gitHubClient
+}
+
+// User fetches the GitHub user, and checks the user can access one of the required installations of the GitHub App.
+func (p *GitHubProvider) User(ctx context.Context, token *oauth2.Token) (*User, error) {
+	gitHubClient := p.Client(ctx, token)
+	user, _, err := gitHubClient.Users.Get(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkInstallationAccess(ctx, gitHubClient); err != nil {
+		return nil, err
+	}
+	return &User{
+		ID:    strconv.FormatInt(user.GetID(), 10),
+		Login: user.GetLogin(),
+	}, nil
+}
+
+// Groups fetches the orgs of the user, and its teams as org/team-slug.
+// Private memberships and teams are only visible with the read:org scope.
+func (p *GitHubProvider) Groups(ctx context.Context, token *oauth2.Token) ([]string, error) {
+	gitHubClient := p.Client(ctx, token)
+	var groups []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		orgs, resp, err := gitHubClient.Organizations.List(ctx, "", opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, org := range orgs {
+			groups = append(groups, org.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	opts = &github.ListOptions{PerPage: 100}
+	for {
+		teams, resp, err := gitHubClient.Teams.ListUserTeams(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, team := range teams {
+			groups = append(groups, team.GetOrganization().GetLogin()+"/"+team.GetSlug())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return groups, nil
+}
+
+// checkInstallationAccess checks the user can access one of the required installations of the GitHub App,
+// there is nothing to check for an OAuth App or a GitHub App without required installations.
+func (p *GitHubProvider) checkInstallationAccess(ctx context.Context, gitHubClient *github.Client) error {
+	if !p.gitHubApp || len(p.installations) == 0 {
+		return nil
+	}
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		installations, resp, err := gitHubClient.Apps.ListUserInstallations(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, installation := range installations {
+			if p.isRequiredInstallation(installation) {
+				return nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return ErrInstallationAccessDenied
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (p *GitHubProvider) isRequiredInstallation(installation *github.Installation) bool {
+	for _, required := range p.installations {
+		switch {
+		case required == INSTALLATION_ANY:
+			return true
+		case required == strconv.FormatInt(installation.GetID(), 10):
+			return true
+		case strings.EqualFold(required, installation.GetAccount().GetLogin()):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func newTestGitHubServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("grant_type") == "authorization_code" && r.PostForm.Get("code") == "code" {
+			_, _ = fmt.Fprint(w, `{"access_token":"token","token_type":"bearer"}`)
+			return
+		}
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh-token" {
+			_, _ = fmt.Fprint(w, `{"error":"bad_refresh_token"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"access_token":"new-token","refresh_token":"new-refresh-token","expires_in":28800}`)
+	})
+	mux.HandleFunc("/login/device/code", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"device_code":"device-code","user_code":"ABCD-EFGH","verification_uri":"https://github.example.com/login/device","expires_in":900,"interval":5}`)
+	})
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"id":1,"login":"octocat"}`)
+	})
+	mux.HandleFunc("/api/v3/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `[{"login":"acme"}]`)
+	})
+	mux.HandleFunc("/api/v3/user/teams", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `[{"slug":"devs","organization":{"login":"acme"}}]`)
+	})
+	mux.HandleFunc("/api/v3/user/installations", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"total_count":1,"installations":[{"id":42,"account":{"login":"Acme"}}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestGitHubAppProvider(t *testing.T, serverURL string, installations ...string) *GitHubProvider {
+	p, err := NewGitHubProvider(Options{
+		ClientID:      "client-id",
+		ClientSecret:  "client-secret",
+		AuthURL:       serverURL + "/login/oauth/authorize",
+		TokenURL:      serverURL + "/login/oauth/access_token",
+		ApiURL:        serverURL + "/api/v3",
+		GitHubApp:     true,
+		Installations: installations,
+		HTTPClient:    http.DefaultClient,
+	})
+	assert.NoError(t, err)
+	return p
+}
+
+func TestGitHubProvider_User(t *testing.T) {
+	// setup
+	server := newTestGitHubServer(t)
+	ctx := context.Background()
+	token := &oauth2.Token{AccessToken: "token"}
+
+	// execution & assertion
+	for _, installations := range [][]string{nil, {"*"}, {"42"}, {"other", "acme"}} {
+		user, err := newTestGitHubAppProvider(t, server.URL, installations...).User(ctx, token)
+		assert.NoError(t, err, installations)
+		assert.Equal(t, &User{ID: "1", Login: "octocat"}, user)
+	}
+	_, err := newTestGitHubAppProvider(t, server.URL, "43", "other").User(ctx, token)
+	assert.ErrorIs(t, err, ErrAccessDenied)
+}
+
+func TestGitHubProvider_Groups(t *testing.T) {
+	// setup
+	server := newTestGitHubServer(t)
+
+	// execution
+	groups, err := newTestGitHubAppProvider(t, server.URL).Groups(context.Background(), &oauth2.Token{AccessToken: "token"})
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"acme", "acme/devs"}, groups)
+}
+
+func TestGitHubProvider_RefreshToken(t *testing.T) {
+	// setup
+	server := newTestGitHubServer(t)
+	client := newTestGitHubAppProvider(t, server.URL)
+
+	// execution
+	token, err := client.RefreshToken(context.Background(), "refresh-token")
+	_, errBad := client.RefreshToken(context.Background(), "bad-refresh-token")
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, "new-token", token.AccessToken)
+	assert.Equal(t, "new-refresh-token", token.RefreshToken)
+	assert.True(t, token.Valid())
+	assert.Error(t, errBad)
+}
+
+func TestGitHubProvider_AuthCodeURL(t *testing.T) {
+	// setup
+	p, _ := NewGitHubProvider(Options{ClientID: "client-id", HTTPClient: http.DefaultClient})
+
+	// execution
+	authCodeURL, err := p.AuthCodeURL(context.Background(), "state", "https://oauth.example.com/oauth/redirect", AuthCodeOptions{
+		Scopes:         []string{"read:org", "user:email"},
+		DisallowSignup: true,
+		LoginHint:      "octocat",
+	})
+
+	// assertion
+	assert.NoError(t, err)
+	parsedAuthCodeURL, _ := url.Parse(authCodeURL)
+	query := parsedAuthCodeURL.Query()
+	assert.Equal(t, "read:org user:email", query.Get("scope"))
+	assert.Equal(t, "false", query.Get("allow_signup"))
+	assert.Equal(t, "octocat", query.Get("login"))
+	assert.Equal(t, "state", query.Get("state"))
+}
+
+func TestNewGitHubProvider_Enterprise(t *testing.T) {
+	// setup
+	server := newTestGitHubServer(t)
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		apiURL string
+	}{
+		{name: "api url", apiURL: server.URL + "/api/v3"},
+		{name: "api url with trailing slash", apiURL: server.URL + "/api/v3/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			p, err := NewGitHubProvider(Options{
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+				AuthURL:      server.URL + "/login/oauth/authorize",
+				TokenURL:     server.URL + "/login/oauth/access_token",
+				ApiURL:       tt.apiURL,
+				HTTPClient:   http.DefaultClient,
+			})
+			assert.NoError(t, err)
+
+			// execution
+			authCodeURL, errAuthCodeURL := p.AuthCodeURL(ctx, "state", "https://oauth.example.com/oauth/redirect", AuthCodeOptions{})
+			token, errExchange := p.Exchange(ctx, "code", "https://oauth.example.com/oauth/redirect")
+			user, errUser := p.User(ctx, &oauth2.Token{AccessToken: "token"})
+			deviceAuth, errDeviceAuth := p.DeviceAuth(ctx)
+
+			// assertion
+			assert.NoError(t, errAuthCodeURL)
+			assert.True(t, strings.HasPrefix(authCodeURL, server.URL+"/login/oauth/authorize?"), authCodeURL)
+			assert.NoError(t, errExchange)
+			assert.Equal(t, "token", token.AccessToken)
+			assert.NoError(t, errUser)
+			assert.Equal(t, &User{ID: "1", Login: "octocat"}, user)
+			assert.Equal(t, server.URL+"/api/v3/", p.apiURL.String())
+			assert.NoError(t, errDeviceAuth)
+			assert.Equal(t, "ABCD-EFGH", deviceAuth.UserCode)
+		})
+	}
+}
+
+func TestNewGitHubProvider_DeviceAuthURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		authURL       string
+		deviceAuthURL string
+	}{
+		{name: "github.com", authURL: "", deviceAuthURL: "https://github.com/login/device/code"},
+		{name: "enterprise", authURL: "https://github.example.com/login/oauth/authorize", deviceAuthURL: "https://github.example.com/login/device/code"},
+		{name: "proxied", authURL: "https://sso.example.com/authorize", deviceAuthURL: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// execution
+			p, err := NewGitHubProvider(Options{ClientID: "client-id", AuthURL: tt.authURL, TokenURL: tt.authURL, HTTPClient: http.DefaultClient})
+
+			// assertion
+			assert.NoError(t, err)
+			assert.Equal(t, tt.deviceAuthURL, p.deviceAuthURL)
+			if len(tt.deviceAuthURL) == 0 {
+				_, err := p.DeviceAuth(context.Background())
+				assert.ErrorIs(t, err, ErrDeviceFlowUnsupported)
+			}
+		})
+	}
+}
+
+func TestNewGitHubProvider_InvalidApiURL(t *testing.T) {
+	// execution
+	_, err := NewGitHubProvider(Options{ClientID: "client-id", ApiURL: "://github.example.com/api/v3", HTTPClient: http.DefaultClient})
+
+	// assertion
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultGitLabURL the GitLab used when no URL is configured.
+const DefaultGitLabURL = "https://gitlab.com"
+
+// GitLabProvider logs in with a GitLab application, on gitlab.com or a self-managed instance.
+// It requests the read_user scope for the user and the openid scope for the groups.
+type GitLabProvider struct {
+	oauth2Provider
+	baseURL string
+}
+
+var _ Provider = (*GitLabProvider)(nil)
+
+// NewGitLabProvider creates a GitLabProvider.
+func NewGitLabProvider(opts Options) (*GitLabProvider, error) {
+	baseURL := strings.TrimSuffix(opts.URL, "/")
+	if len(baseURL) == 0 {
+		baseURL = DefaultGitLabURL
+	}
+	return &GitLabProvider{
+		oauth2Provider: oauth2Provider{
+			config: &oauth2.Config{
+				ClientID:     opts.ClientID,
+				ClientSecret: opts.ClientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  baseURL + "/oauth/authorize",
+					TokenURL: baseURL + "/oauth/token",
+				},
+				Scopes: []string{"read_user", "openid"},
+			},
+			deviceAuthURL: baseURL + "/oauth/authorize_device",
+			httpClient:    opts.HTTPClient,
+		},
+		baseURL: baseURL,
+	}, nil
+}
+
+// User fetches the GitLab user.
+func (p *GitLabProvider) User(ctx context.Context, token *oauth2.Token) (*User, error) {
+	var user struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	}
+	if err := getJSON(ctx, p.client(ctx, token), p.baseURL+"/api/v4/user", &user); err != nil {
+		return nil, err
+	}
+	return &User{
+		ID:    strconv.FormatInt(user.ID, 10),
+		Login: user.Username,
+	}, nil
+}
+
+// Groups fetches the full paths of the groups of the user from the OIDC userinfo.
+func (p *GitLabProvider) Groups(ctx context.Context, token *oauth2.Token) ([]string, error) {
+	var userinfo struct {
+		Groups []string `json:"groups"`
+	}
+	if err := getJSON(ctx, p.client(ctx, token), p.baseURL+"/oauth/userinfo", &userinfo); err != nil {
+		return nil, err
+	}
+	return userinfo.Groups, nil
+}
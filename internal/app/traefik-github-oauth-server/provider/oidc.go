@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwks"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+// OIDC_GROUPS_CLAIM the claim holding the groups of the user.
+const OIDC_GROUPS_CLAIM = "groups"
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// oidcSigningMethods the ID token signing algorithms accepted, symmetric algorithms are refused.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// OIDCProvider logs in with a generic OpenID Connect provider.
+// The endpoints are discovered from the issuer on first use, and the user is read from the validated ID token.
+type OIDCProvider struct {
+	clientID     string
+	clientSecret string
+	issuerURL    string
+	httpClient   *http.Client
+
+	mu         sync.Mutex
+	discovered *oidcDiscovered
+}
+
+// oidcDiscovered the provider metadata, from {issuer}/.well-known/openid-configuration.
+type oidcDiscovered struct {
+	oauth2Provider
+	issuer      string
+	userinfoURL string
+	keys        *jwks.Cache
+}
+
+var _ Provider = (*OIDCProvider)(nil)
+
+// NewOIDCProvider creates an OIDCProvider.
+func NewOIDCProvider(opts Options) (*OIDCProvider, error) {
+	if len(opts.URL) == 0 {
+		return nil, errors.New("the OIDC issuer URL is required")
+	}
+	return &OIDCProvider{
+		clientID:     opts.ClientID,
+		clientSecret: opts.ClientSecret,
+		issuerURL:    strings.TrimSuffix(opts.URL, "/"),
+		httpClient:   opts.HTTPClient,
+	}, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, redirectURI string, opts AuthCodeOptions) (string, error) {
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return discovered.AuthCodeURL(ctx, state, redirectURI, opts)
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code, redirectURI string) (*oauth2.Token, error) {
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return discovered.Exchange(ctx, code, redirectURI)
+}
+
+func (p *OIDCProvider) DeviceAuth(ctx context.Context) (*DeviceAuth, error) {
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return discovered.DeviceAuth(ctx)
+}
+
+func (p *OIDCProvider) DeviceToken(ctx context.Context, deviceCode string) (*oauth2.Token, error) {
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return discovered.DeviceToken(ctx, deviceCode)
+}
+
+func (p *OIDCProvider) RefreshToken(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return discovered.RefreshToken(ctx, refreshToken)
+}
+
+// User reads the user from the ID token, the login is the preferred_username, email or sub claim.
+func (p *OIDCProvider) User(ctx context.Context, token *oauth2.Token) (*User, error) {
+	claims, err := p.idTokenClaims(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	sub, _ := claims["sub"].(string)
+	if len(sub) == 0 {
+		return nil, fmt.Errorf("%w: no sub claim", ErrInvalidIDToken)
+	}
+	user := &User{ID: sub, Login: sub}
+	for _, claim := range []string{"preferred_username", "email"} {
+		if login, ok := claims[claim].(string); ok && 0 < len(login) {
+			user.Login = login
+			break
+		}
+	}
+	return user, nil
+}
+
+// Groups reads the groups claim of the ID token, or of the userinfo when the ID token has none.
+func (p *OIDCProvider) Groups(ctx context.Context, token *oauth2.Token) ([]string, error) {
+	claims, err := p.idTokenClaims(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := claims[OIDC_GROUPS_CLAIM]; !ok {
+		discovered, err := p.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(discovered.userinfoURL) == 0 {
+			return nil, nil
+		}
+		claims = jwt.MapClaims{}
+		if err := getJSON(ctx, discovered.client(ctx, token), discovered.userinfoURL, &claims); err != nil {
+			return nil, err
+		}
+	}
+	rawGroups, _ := claims[OIDC_GROUPS_CLAIM].([]interface{})
+	groups := make([]string, 0, len(rawGroups))
+	for _, rawGroup := range rawGroups {
+		if group, ok := rawGroup.(string); ok {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// idTokenClaims validates the ID token of the token response: signature, issuer, audience and expiry.
+func (p *OIDCProvider) idTokenClaims(ctx context.Context, token *oauth2.Token) (jwt.MapClaims, error) {
+	discovered, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	if len(idToken) == 0 {
+		return nil, fmt.Errorf("%w: no ID token in the token response", ErrInvalidIDToken)
+	}
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(oidcSigningMethods))
+	_, err = parser.ParseWithClaims(idToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return discovered.keys.Key(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIDToken, err)
+	}
+	if !claims.VerifyIssuer(discovered.issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidIDToken)
+	}
+	if !claims.VerifyAudience(p.clientID, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidIDToken)
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: no exp claim", ErrInvalidIDToken)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.clientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// discover fetches the provider metadata once, a failed discovery is tried again on the next call.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovered, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovered != nil {
+		return p.discovered, nil
+	}
+	var metadata struct {
+		Issuer                      string `json:"issuer"`
+		AuthorizationEndpoint       string `json:"authorization_endpoint"`
+		DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+		TokenEndpoint               string `json:"token_endpoint"`
+		UserinfoEndpoint            string `json:"userinfo_endpoint"`
+		JwksURI                     string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, p.httpClient, p.issuerURL+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("failed to discover the OIDC provider: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != p.issuerURL {
+		return nil, fmt.Errorf("the OIDC issuer %q does not match the issuer URL %q", metadata.Issuer, p.issuerURL)
+	}
+	if len(metadata.AuthorizationEndpoint) == 0 || len(metadata.TokenEndpoint) == 0 || len(metadata.JwksURI) == 0 {
+		return nil, errors.New("the OIDC provider metadata lacks an authorization, token or jwks endpoint")
+	}
+	p.discovered = &oidcDiscovered{
+		oauth2Provider: oauth2Provider{
+			config: &oauth2.Config{
+				ClientID:     p.clientID,
+				ClientSecret: p.clientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  metadata.AuthorizationEndpoint,
+					TokenURL: metadata.TokenEndpoint,
+				},
+				Scopes: []string{"openid", "profile", "email"},
+			},
+			deviceAuthURL: metadata.DeviceAuthorizationEndpoint,
+			httpClient:    p.httpClient,
+		},
+		issuer:      metadata.Issuer,
+		userinfoURL: metadata.UserinfoEndpoint,
+		keys:        jwks.NewCache(metadata.JwksURI, p.httpClient),
+	}
+	return p.discovered, nil
+}
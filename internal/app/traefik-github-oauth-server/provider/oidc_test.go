@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwks"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type testOIDCServer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newTestOIDCServer(t *testing.T) *testOIDCServer {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	s := &testOIDCServer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 s.URL,
+			"authorization_endpoint": s.URL + "/authorize",
+			"token_endpoint":         s.URL + "/token",
+			"userinfo_endpoint":      s.URL + "/userinfo",
+			"jwks_uri":               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks.Set{Keys: []jwks.Key{{
+			Kty: "RSA",
+			Kid: "key-1",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   "AQAB",
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, s.claims)
+		token.Header["kid"] = "key-1"
+		idToken, _ := token.SignedString(key)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"groups": []string{"from-userinfo"}})
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testOIDCServer) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                s.URL,
+		"aud":                "client-id",
+		"sub":                "sub-1",
+		"preferred_username": "alice",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"groups":             []string{"devs"},
+	}
+}
+
+func TestOIDCProvider(t *testing.T) {
+	// setup
+	server := newTestOIDCServer(t)
+	p, _ := NewOIDCProvider(Options{ClientID: "client-id", ClientSecret: "secret", URL: server.URL, HTTPClient: http.DefaultClient})
+	ctx := context.Background()
+	server.claims = server.validClaims()
+
+	// execution
+	authCodeURL, errAuthCodeURL := p.AuthCodeURL(ctx, "state", "https://oauth.example.com/oauth/redirect", AuthCodeOptions{})
+	token, errExchange := p.Exchange(ctx, "code", "https://oauth.example.com/oauth/redirect")
+	user, errUser := p.User(ctx, token)
+	groups, errGroups := p.Groups(ctx, token)
+
+	// assertion
+	assert.NoError(t, errAuthCodeURL)
+	parsedAuthCodeURL, _ := url.Parse(authCodeURL)
+	assert.Equal(t, "/authorize", parsedAuthCodeURL.Path)
+	assert.Equal(t, "openid profile email", parsedAuthCodeURL.Query().Get("scope"))
+	assert.NoError(t, errExchange)
+	assert.NoError(t, errUser)
+	assert.Equal(t, &User{ID: "sub-1", Login: "alice"}, user)
+	assert.NoError(t, errGroups)
+	assert.Equal(t, []string{"devs"}, groups)
+
+	// execution
+	delete(server.claims, "groups")
+	token, _ = p.Exchange(ctx, "code", "https://oauth.example.com/oauth/redirect")
+	groups, errGroups = p.Groups(ctx, token)
+
+	// assertion
+	assert.NoError(t, errGroups)
+	assert.Equal(t, []string{"from-userinfo"}, groups)
+}
+
+func TestOIDCProvider_InvalidIDToken(t *testing.T) {
+	// setup
+	server := newTestOIDCServer(t)
+	p, _ := NewOIDCProvider(Options{ClientID: "client-id", ClientSecret: "secret", URL: server.URL, HTTPClient: http.DefaultClient})
+	ctx := context.Background()
+	invalidClaims := map[string]func(claims jwt.MapClaims){
+		"issuer":   func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example.com" },
+		"audience": func(claims jwt.MapClaims) { claims["aud"] = "other-client-id" },
+		"expired":  func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"no exp":   func(claims jwt.MapClaims) { delete(claims, "exp") },
+	}
+
+	for name, invalidate := range invalidClaims {
+		// setup
+		server.claims = server.validClaims()
+		invalidate(server.claims)
+
+		// execution
+		token, _ := p.Exchange(ctx, "code", "https://oauth.example.com/oauth/redirect")
+		_, err := p.User(ctx, token)
+
+		// assertion
+		assert.ErrorIs(t, err, ErrInvalidIDToken, name)
+	}
+}
@@ -0,0 +1,338 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"golang.org/x/oauth2"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	TYPE_GITHUB = "github"
+	TYPE_GITLAB = "gitlab"
+	TYPE_GITEA  = "gitea"
+	TYPE_OIDC   = "oidc"
+)
+
+// maxResponseSize the maximum size of a provider API response.
+const maxResponseSize = 1 << 20
+
+var (
+	// ErrAccessDenied the user denied the authorization, or does not meet a requirement of the client.
+	ErrAccessDenied = errors.New("access denied")
+	// ErrDeviceFlowUnsupported the provider has no device authorization endpoint.
+	ErrDeviceFlowUnsupported = errors.New("the provider does not support the device flow")
+	// ErrAuthorizationPending the user has not approved the device authorization yet.
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	// ErrSlowDown the device flow is polled too often, the interval must be increased by 5 seconds.
+	ErrSlowDown = errors.New("slow_down")
+	// ErrExpiredToken the device code expired, a new device flow must be started.
+	ErrExpiredToken = errors.New("expired_token")
+	// ErrScopesNotGranted the user narrowed the scopes of the login.
+	ErrScopesNotGranted = fmt.Errorf("%w: the requested scopes were not granted", ErrAccessDenied)
+)
+
+// User an identity authenticated by a provider.
+type User struct {
+	ID    string
+	Login string
+}
+
+// Provider an OAuth 2.0 identity provider.
+type Provider interface {
+	// AuthCodeURL returns the URL of the provider login page, redirecting back to redirectURI with the state.
+	AuthCodeURL(ctx context.Context, state, redirectURI string, opts AuthCodeOptions) (string, error)
+	// Exchange exchanges the authorization code for a token.
+	Exchange(ctx context.Context, code, redirectURI string) (*oauth2.Token, error)
+	// RefreshToken exchanges the refresh token of an expiring token for a new token.
+	RefreshToken(ctx context.Context, refreshToken string) (*oauth2.Token, error)
+	// User fetches the user the token belongs to.
+	User(ctx context.Context, token *oauth2.Token) (*User, error)
+	// Groups fetches the groups of the user the token belongs to, e.g. the orgs and teams on GitHub.
+	Groups(ctx context.Context, token *oauth2.Token) ([]string, error)
+	// DeviceAuth starts a device authorization grant (RFC 8628).
+	DeviceAuth(ctx context.Context) (*DeviceAuth, error)
+	// DeviceToken polls the token of a device authorization grant,
+	// it fails with ErrAuthorizationPending until the user approves it.
+	DeviceToken(ctx context.Context, deviceCode string) (*oauth2.Token, error)
+}
+
+// AuthCodeOptions the parameters of a login page.
+type AuthCodeOptions struct {
+	// Scopes the scopes requested in addition to the ones the provider always requests.
+	Scopes []string
+	// DisallowSignup hides the sign up option of the login page, only GitHub supports it.
+	DisallowSignup bool
+	// LoginHint the account the user is expected to log in with, e.g. a login or an email.
+	LoginHint string
+}
+
+// DeviceAuth the device authorization response of a provider.
+type DeviceAuth struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// Options the configuration of a Provider.
+type Options struct {
+	Type         string
+	ClientID     string
+	ClientSecret string
+	// URL the base URL of GitLab or Gitea, or the issuer URL of an OIDC provider.
+	URL string
+	// AuthURL, TokenURL and ApiURL the GitHub endpoints, for GitHub Enterprise.
+	AuthURL  string
+	TokenURL string
+	ApiURL   string
+	// GitHubApp logs in with the user authorization flow of a GitHub App,
+	// the user must access one of its Installations, by id or account login, if any.
+	GitHubApp     bool
+	Installations []string
+	// HTTPClient the client used for every provider call.
+	HTTPClient *http.Client
+}
+
+// New creates the Provider of opts.Type.
+func New(opts Options) (Provider, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	switch opts.Type {
+	case TYPE_GITHUB, "":
+		return NewGitHubProvider(opts)
+	case TYPE_GITLAB:
+		return NewGitLabProvider(opts)
+	case TYPE_GITEA:
+		return NewGiteaProvider(opts)
+	case TYPE_OIDC:
+		return NewOIDCProvider(opts)
+	default:
+		return nil, fmt.Errorf("unknown provider type: %s", opts.Type)
+	}
+}
+
+// oauth2Provider the OAuth 2.0 authorization code and device flows shared by the providers.
+type oauth2Provider struct {
+	config *oauth2.Config
+	// deviceAuthURL the device authorization endpoint, empty if the provider has none.
+	deviceAuthURL string
+	httpClient    *http.Client
+}
+
+func (p *oauth2Provider) AuthCodeURL(_ context.Context, state, redirectURI string, opts AuthCodeOptions) (string, error) {
+	params := p.authCodeParams(redirectURI, opts)
+	if 0 < len(opts.LoginHint) {
+		params = append(params, oauth2.SetAuthURLParam(constant.QUERY_KEY_LOGIN_HINT, opts.LoginHint))
+	}
+	return p.config.AuthCodeURL(state, params...), nil
+}
+
+// authCodeParams the parameters of the login page every provider supports: the redirect uri and the scopes.
+func (p *oauth2Provider) authCodeParams(redirectURI string, opts AuthCodeOptions) []oauth2.AuthCodeOption {
+	params := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam(constant.QUERY_KEY_REDIRECT_URI, redirectURI)}
+	if 0 < len(opts.Scopes) {
+		scopes := append([]string{}, p.config.Scopes...)
+		for _, scope := range opts.Scopes {
+			if !containsString(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		params = append(params, oauth2.SetAuthURLParam(constant.QUERY_KEY_SCOPE, strings.Join(scopes, " ")))
+	}
+	return params
+}
+
+func (p *oauth2Provider) Exchange(ctx context.Context, code, redirectURI string) (*oauth2.Token, error) {
+	return p.config.Exchange(p.withHTTPClient(ctx), code, oauth2.SetAuthURLParam(constant.QUERY_KEY_REDIRECT_URI, redirectURI))
+}
+
+func (p *oauth2Provider) RefreshToken(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	expired := &oauth2.Token{
+		RefreshToken: refreshToken,
+		Expiry:       time.Unix(1, 0),
+	}
+	return p.config.TokenSource(p.withHTTPClient(ctx), expired).Token()
+}
+
+func (p *oauth2Provider) DeviceAuth(ctx context.Context) (*DeviceAuth, error) {
+	if len(p.deviceAuthURL) == 0 {
+		return nil, ErrDeviceFlowUnsupported
+	}
+	form := url.Values{"client_id": {p.config.ClientID}}
+	if 0 < len(p.config.Scopes) {
+		form.Set("scope", strings.Join(p.config.Scopes, " "))
+	}
+	deviceAuth := &DeviceAuth{}
+	if err := p.postForm(ctx, p.deviceAuthURL, form, deviceAuth); err != nil {
+		return nil, err
+	}
+	if len(deviceAuth.DeviceCode) == 0 || len(deviceAuth.UserCode) == 0 {
+		return nil, errors.New("invalid device authorization response")
+	}
+	return deviceAuth, nil
+}
+
+func (p *oauth2Provider) DeviceToken(ctx context.Context, deviceCode string) (*oauth2.Token, error) {
+	if len(p.deviceAuthURL) == 0 {
+		return nil, ErrDeviceFlowUnsupported
+	}
+	form := url.Values{
+		"client_id":   {p.config.ClientID},
+		"device_code": {deviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+	if 0 < len(p.config.ClientSecret) {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	raw := make(map[string]interface{})
+	if err := p.postForm(ctx, p.config.Endpoint.TokenURL, form, &raw); err != nil {
+		return nil, err
+	}
+	if errCode, ok := raw["error"].(string); ok && 0 < len(errCode) {
+		switch errCode {
+		case ErrAuthorizationPending.Error():
+			return nil, ErrAuthorizationPending
+		case ErrSlowDown.Error():
+			return nil, ErrSlowDown
+		case ErrExpiredToken.Error():
+			return nil, ErrExpiredToken
+		case "access_denied":
+			return nil, ErrAccessDenied
+		default:
+			description, _ := raw["error_description"].(string)
+			return nil, fmt.Errorf("device token error: %s %s", errCode, description)
+		}
+	}
+	accessToken, _ := raw["access_token"].(string)
+	if len(accessToken) == 0 {
+		return nil, errors.New("device token response missing access_token")
+	}
+	token := &oauth2.Token{AccessToken: accessToken}
+	token.TokenType, _ = raw["token_type"].(string)
+	token.RefreshToken, _ = raw["refresh_token"].(string)
+	if expiresIn, ok := raw["expires_in"].(float64); ok && 0 < expiresIn {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token.WithExtra(raw), nil
+}
+
+// client returns an HTTP client authenticated with the token, refreshing it when it expires.
+func (p *oauth2Provider) client(ctx context.Context, token *oauth2.Token) *http.Client {
+	return p.config.Client(p.withHTTPClient(ctx), token)
+}
+
+// withHTTPClient makes the oauth2 package use the provider HTTP client.
+func (p *oauth2Provider) withHTTPClient(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+}
+
+// postForm posts the form to rawURL and decodes the JSON response, error responses included.
+func (p *oauth2Provider) postForm(ctx context.Context, rawURL string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if 500 <= resp.StatusCode {
+		return fmt.Errorf("POST %s: %s", rawURL, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("POST %s: %s: %w", rawURL, resp.Status, err)
+	}
+	return nil
+}
+
+// GrantedScopes returns the scopes granted to the token, false if the token response does not report them.
+// GitHub separates them with commas, the other providers with spaces.
+func GrantedScopes(token *oauth2.Token) ([]string, bool) {
+	scope, ok := token.Extra(constant.QUERY_KEY_SCOPE).(string)
+	if !ok {
+		return nil, false
+	}
+	return strings.FieldsFunc(scope, func(r rune) bool {
+		return r == ' ' || r == ','
+	}), true
+}
+
+// MissingScopes returns the requested scopes none of the granted scopes covers.
+// A scope is covered by itself and by the broader scopes of GitHub: user:email by user, read:org by write:org and admin:org.
+func MissingScopes(requested, granted []string) []string {
+	var missing []string
+	for _, scope := range requested {
+		covered := false
+		for _, grantedScope := range granted {
+			if scopeCovers(grantedScope, scope) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// scopeLevels the access levels of the GitHub scopes, an admin:org grant covers write:org and read:org.
+var scopeLevels = map[string]int{"read": 1, "write": 2, "admin": 3}
+
+func scopeCovers(granted, requested string) bool {
+	if granted == requested {
+		return true
+	}
+	prefix, resource, ok := strings.Cut(requested, ":")
+	if !ok {
+		return false
+	}
+	if granted == prefix {
+		return true
+	}
+	grantedPrefix, grantedResource, ok := strings.Cut(granted, ":")
+	return ok && grantedResource == resource && 0 < scopeLevels[prefix] && scopeLevels[prefix] < scopeLevels[grantedPrefix]
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// getJSON decodes the JSON response of a GET request to rawURL.
+func getJSON(ctx context.Context, client *http.Client, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || 299 < resp.StatusCode {
+		return fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestOAuth2Provider_DeviceFlow(t *testing.T) {
+	// setup
+	approved := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/authorize_device", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"device_code":"dc","user_code":"ABCD-EFGH","verification_uri":"https://example.com/device","expires_in":900,"interval":5}`)
+	})
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "dc", r.FormValue("device_code"))
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", r.FormValue("grant_type"))
+		if !approved {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"authorization_pending"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"access_token":"token","token_type":"bearer","expires_in":3600}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	p, _ := NewGitLabProvider(Options{ClientID: "client-id", URL: server.URL, HTTPClient: http.DefaultClient})
+
+	// execution
+	deviceAuth, errAuth := p.DeviceAuth(context.Background())
+	_, errPending := p.DeviceToken(context.Background(), "dc")
+	approved = true
+	token, errToken := p.DeviceToken(context.Background(), "dc")
+
+	// assertion
+	assert.NoError(t, errAuth)
+	assert.Equal(t, "ABCD-EFGH", deviceAuth.UserCode)
+	assert.Equal(t, 5, deviceAuth.Interval)
+	assert.ErrorIs(t, errPending, ErrAuthorizationPending)
+	assert.NoError(t, errToken)
+	assert.Equal(t, "token", token.AccessToken)
+	assert.False(t, token.Expiry.IsZero())
+}
+
+func TestOAuth2Provider_DeviceFlow_Unsupported(t *testing.T) {
+	// setup
+	p, _ := NewGitHubProvider(Options{
+		ClientID:   "client-id",
+		AuthURL:    "https://example.com/authorize",
+		TokenURL:   "https://example.com/token",
+		HTTPClient: http.DefaultClient,
+	})
+
+	// execution
+	_, err := p.DeviceAuth(context.Background())
+
+	// assertion
+	assert.ErrorIs(t, err, ErrDeviceFlowUnsupported)
+}
+
+func TestGrantedScopes(t *testing.T) {
+	// execution & assertion
+	granted, ok := GrantedScopes((&oauth2.Token{}).WithExtra(map[string]interface{}{"scope": "read:org,user:email"}))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"read:org", "user:email"}, granted)
+
+	granted, ok = GrantedScopes((&oauth2.Token{}).WithExtra(map[string]interface{}{"scope": "openid profile"}))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"openid", "profile"}, granted)
+
+	_, ok = GrantedScopes((&oauth2.Token{}).WithExtra(map[string]interface{}{}))
+	assert.False(t, ok)
+}
+
+func TestMissingScopes(t *testing.T) {
+	// execution & assertion
+	assert.Empty(t, MissingScopes([]string{"read:org", "user:email"}, []string{"admin:org", "user"}))
+	assert.Empty(t, MissingScopes([]string{"write:org"}, []string{"admin:org"}))
+	assert.Equal(t, []string{"admin:org"}, MissingScopes([]string{"admin:org"}, []string{"read:org"}))
+	assert.Equal(t, []string{"read:org", "repo"}, MissingScopes([]string{"read:org", "repo"}, []string{"repo:status"}))
+}
@@ -0,0 +1,192 @@
+package traefik_github_oauth_server
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/revocation"
+)
+
+// RevocationStore keeps the revocation list of the sessions, optionally persisted to a JSON file.
+// The file is read again when it changes, so replicas sharing it through a volume publish the same list.
+type RevocationStore struct {
+	// path the file of the list, empty to keep it in memory only.
+	path string
+
+	mu      sync.Mutex
+	list    *revocation.List
+	etag    string
+	modTime time.Time
+	now     func() time.Time
+}
+
+// NewRevocationStore opens the revocation list at path, an empty path keeps the list in memory.
+func NewRevocationStore(path string) (*RevocationStore, error) {
+	s := &RevocationStore{
+		path: path,
+		list: &revocation.List{},
+		now:  time.Now,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, s.updateETag()
+}
+
+// List returns a copy of the revocation list and its entity tag.
+func (s *RevocationStore) List() (*revocation.List, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, "", err
+	}
+	return copyRevocationList(s.list), s.etag, nil
+}
+
+// RevokeAll revokes every session issued before the time.
+func (s *RevocationStore) RevokeAll(before time.Time) error {
+	return s.update(func(l *revocation.List) {
+		if l.NotBefore < before.Unix() {
+			l.NotBefore = before.Unix()
+		}
+	})
+}
+
+// RevokeUser revokes the sessions of the user issued before the time.
+func (s *RevocationStore) RevokeUser(provider, userID string, before time.Time) error {
+	return s.update(func(l *revocation.List) {
+		if l.Users == nil {
+			l.Users = make(map[string]int64)
+		}
+		key := revocation.UserKey(provider, userID)
+		if l.Users[key] < before.Unix() {
+			l.Users[key] = before.Unix()
+		}
+	})
+}
+
+// RevokeToken revokes the session of the jti, the entry is kept until the session expires.
+func (s *RevocationStore) RevokeToken(jti string, expiresAt time.Time) error {
+	return s.update(func(l *revocation.List) {
+		if l.Tokens == nil {
+			l.Tokens = make(map[string]int64)
+		}
+		l.Tokens[jti] = expiresAt.Unix()
+	})
+}
+
+// Clear empties the revocation list, the revoked sessions that did not expire are accepted again.
+func (s *RevocationStore) Clear() error {
+	return s.update(func(l *revocation.List) {
+		*l = revocation.List{}
+	})
+}
+
+func (s *RevocationStore) update(fn func(l *revocation.List)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	list := copyRevocationList(s.list)
+	fn(list)
+	list.Prune(s.now())
+	if err := s.flush(list); err != nil {
+		return err
+	}
+	s.list = list
+	return s.updateETag()
+}
+
+// reload reads the file again if it changed since it was last read or written.
+func (s *RevocationStore) reload() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+	info, err := os.Stat(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case info.ModTime().Equal(s.modTime):
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	list := &revocation.List{}
+	if 0 < len(data) {
+		if err := json.Unmarshal(data, list); err != nil {
+			return err
+		}
+	}
+	s.list = list
+	s.modTime = info.ModTime()
+	return s.updateETag()
+}
+
+// flush writes the list to a temporary file and renames it over the list file.
+func (s *RevocationStore) flush(list *revocation.List) error {
+	if len(s.path) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.modTime = info.ModTime()
+	return nil
+}
+
+func (s *RevocationStore) updateETag() error {
+	etag, err := s.list.ETag()
+	if err != nil {
+		return err
+	}
+	s.etag = etag
+	return nil
+}
+
+func copyRevocationList(l *revocation.List) *revocation.List {
+	c := &revocation.List{NotBefore: l.NotBefore}
+	if 0 < len(l.Users) {
+		c.Users = make(map[string]int64, len(l.Users))
+		for k, v := range l.Users {
+			c.Users[k] = v
+		}
+	}
+	if 0 < len(l.Tokens) {
+		c.Tokens = make(map[string]int64, len(l.Tokens))
+		for k, v := range l.Tokens {
+			c.Tokens[k] = v
+		}
+	}
+	return c
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/store"
+	"github.com/gin-gonic/gin"
+)
+
+// listAuthRequests lists the pending auth requests, the oldest first.
+// In stateless mode the auth requests travel in the OAuth state, there are none to list.
+func listAuthRequests(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		setNoCacheHeaders(c)
+		aqs, err := app.AuthRequestManager.List(c.Request.Context())
+		if err != nil {
+			logger.Error().Caller().Err(err).Msg("failed to list auth requests")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to list auth requests: %s", err.Error()),
+			})
+			return
+		}
+		now := time.Now().Unix()
+		authRequests := make([]model.AdminAuthRequest, 0, len(aqs))
+		for rid, aq := range aqs {
+			authRequest := model.AdminAuthRequest{
+				RID:       rid,
+				Host:      hostOf(aq.RedirectURI),
+				Client:    aq.Client,
+				ClientIP:  aq.ClientIP,
+				Completed: 0 < len(aq.GitHubUserID),
+			}
+			if 0 < aq.CreatedAt {
+				authRequest.AgeSeconds = now - aq.CreatedAt
+			}
+			authRequests = append(authRequests, authRequest)
+		}
+		sort.Slice(authRequests, func(i, j int) bool {
+			return authRequests[i].AgeSeconds > authRequests[j].AgeSeconds
+		})
+		c.JSON(http.StatusOK, model.ResponseAdminAuthRequests{AuthRequests: authRequests})
+	}
+}
+
+func deleteAuthRequest(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		rid := c.Param("rid")
+		_, err := app.AuthRequestManager.Pop(c.Request.Context(), rid)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(http.StatusNotFound, model.ResponseError{Message: ErrInvalidRID.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", rid).Msg("failed to delete auth request")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to delete auth request: %s", err.Error()),
+			})
+			return
+		}
+		logger.Info().Str("rid", rid).Msg("auth request deleted")
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// getConfig returns the effective config, with its secrets redacted.
+func getConfig(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setNoCacheHeaders(c)
+		c.JSON(http.StatusOK, app.Config().Redacted())
+	}
+}
+
+func getBuildInfo(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, app.BuildInfo)
+	}
+}
+
+// listLogins lists the most recent successful logins of this replica, the most recent first.
+func listLogins(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setNoCacheHeaders(c)
+		c.JSON(http.StatusOK, app.LoginHistory.Recent())
+	}
+}
+
+// servePprof serves the net/http/pprof handlers, the index lists the profiles relative to it.
+// The command line is not served, the secrets may be passed as flags.
+func servePprof(c *gin.Context) {
+	switch name := strings.TrimPrefix(c.Param("name"), "/"); name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		c.Status(http.StatusNotFound)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
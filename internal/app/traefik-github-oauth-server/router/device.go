@@ -0,0 +1,245 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/seal"
+	"github.com/gin-gonic/gin"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	DEVICE_ERROR_INVALID_REQUEST        = "invalid_request"
+	DEVICE_ERROR_INVALID_GRANT          = "invalid_grant"
+	DEVICE_ERROR_UNSUPPORTED_GRANT_TYPE = "unsupported_grant_type"
+	DEVICE_ERROR_AUTHORIZATION_PENDING  = "authorization_pending"
+	DEVICE_ERROR_SLOW_DOWN              = "slow_down"
+	DEVICE_ERROR_EXPIRED_TOKEN          = "expired_token"
+	DEVICE_ERROR_ACCESS_DENIED          = "access_denied"
+
+	TOKEN_TYPE_BEARER = "Bearer"
+)
+
+// startDeviceFlow starts a device authorization grant with the provider of the client,
+// the device code of the provider is sealed so polling works on any replica.
+func startDeviceFlow(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		setNoCacheHeaders(c)
+		if !app.SessionIssuer.DeviceFlowEnabled() {
+			c.JSON(http.StatusNotFound, model.ResponseError{
+				Message: server.ErrDeviceFlowDisabled.Error(),
+			})
+			return
+		}
+		body := model.RequestDeviceCode{}
+		err := c.ShouldBind(&body)
+		if err != nil {
+			logger.Debug().Err(err).Msg("invalid request")
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{
+				Error:            DEVICE_ERROR_INVALID_REQUEST,
+				ErrorDescription: err.Error(),
+			})
+			return
+		}
+
+		oAuthClient, found := app.OAuthClient(body.Client)
+		if !found {
+			logger.Debug().Str("client", body.Client).Msg("unknown client")
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{
+				Error:            DEVICE_ERROR_INVALID_REQUEST,
+				ErrorDescription: fmt.Sprintf("%s: %s", ErrUnknownClient.Error(), body.Client),
+			})
+			return
+		}
+
+		deviceAuth, err := oAuthClient.Provider.DeviceAuth(c.Request.Context())
+		if errors.Is(err, provider.ErrDeviceFlowUnsupported) {
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{
+				Error:            DEVICE_ERROR_UNSUPPORTED_GRANT_TYPE,
+				ErrorDescription: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("client", oAuthClient.Name).Msg("failed to start device flow")
+			c.JSON(http.StatusBadGateway, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to start device flow: %s", err.Error()),
+			})
+			return
+		}
+
+		deviceCode, err := app.SessionIssuer.SealDeviceCode(
+			oAuthClient.Name,
+			deviceAuth.DeviceCode,
+			time.Duration(deviceAuth.ExpiresIn)*time.Second,
+		)
+		if err != nil {
+			logger.Error().Caller().Err(err).Msg("failed to seal device code")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to seal device code: %s", err.Error()),
+			})
+			return
+		}
+
+		app.Audit(audit.Event{
+			Event:    audit.EVENT_DEVICE_STARTED,
+			Provider: oAuthClient.ProviderType,
+			ClientIP: c.ClientIP(),
+		})
+
+		c.JSON(http.StatusOK, model.ResponseDeviceCode{
+			DeviceCode:              deviceCode,
+			UserCode:                deviceAuth.UserCode,
+			VerificationURI:         deviceAuth.VerificationURI,
+			VerificationURIComplete: deviceAuth.VerificationURIComplete,
+			ExpiresIn:               deviceAuth.ExpiresIn,
+			Interval:                deviceAuth.Interval,
+		})
+	}
+}
+
+// pollDeviceToken polls the provider for the device authorization grant,
+// once the user approved it the user is fetched and a bearer session token is issued.
+func pollDeviceToken(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		setNoCacheHeaders(c)
+		if !app.SessionIssuer.DeviceFlowEnabled() {
+			c.JSON(http.StatusNotFound, model.ResponseError{
+				Message: server.ErrDeviceFlowDisabled.Error(),
+			})
+			return
+		}
+		body := model.RequestDeviceToken{}
+		err := c.ShouldBind(&body)
+		if err != nil {
+			logger.Debug().Err(err).Msg("invalid request")
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{
+				Error:            DEVICE_ERROR_INVALID_REQUEST,
+				ErrorDescription: err.Error(),
+			})
+			return
+		}
+
+		deviceCode, err := app.SessionIssuer.OpenDeviceCode(body.DeviceCode)
+		if errors.Is(err, server.ErrDeviceCodeExpired) {
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{Error: DEVICE_ERROR_EXPIRED_TOKEN})
+			return
+		}
+		if err != nil {
+			logger.Debug().Err(err).Msg("invalid device code")
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{
+				Error:            DEVICE_ERROR_INVALID_GRANT,
+				ErrorDescription: seal.ErrInvalidSealed.Error(),
+			})
+			return
+		}
+
+		oAuthClient, found := app.OAuthClient(deviceCode.Client)
+		if !found {
+			logger.Warn().Str("client", deviceCode.Client).Msg("unknown client")
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{
+				Error:            DEVICE_ERROR_INVALID_GRANT,
+				ErrorDescription: fmt.Sprintf("%s: %s", ErrUnknownClient.Error(), deviceCode.Client),
+			})
+			return
+		}
+
+		ctx := c.Request.Context()
+		token, err := oAuthClient.Provider.DeviceToken(ctx, deviceCode.DeviceCode)
+		if deviceError := deviceErrorOf(err); 0 < len(deviceError) {
+			c.JSON(http.StatusBadRequest, model.ResponseDeviceError{Error: deviceError})
+			return
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("client", oAuthClient.Name).Msg("failed to poll device token")
+			c.JSON(http.StatusBadGateway, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to poll device token: %s", err.Error()),
+			})
+			return
+		}
+
+		user, err := oAuthClient.Provider.User(ctx, token)
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("client", oAuthClient.Name).Msg("failed to get user")
+			app.Audit(audit.Event{
+				Event:    audit.EVENT_SESSION_ISSUED,
+				Decision: audit.DECISION_DENIED,
+				Reason:   err.Error(),
+				Provider: oAuthClient.ProviderType,
+				ClientIP: c.ClientIP(),
+			})
+			if errors.Is(err, provider.ErrAccessDenied) {
+				c.JSON(http.StatusBadRequest, model.ResponseDeviceError{
+					Error:            DEVICE_ERROR_ACCESS_DENIED,
+					ErrorDescription: err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusBadGateway, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to get user: %s", err.Error()),
+			})
+			return
+		}
+		groups, err := oAuthClient.Provider.Groups(ctx, token)
+		if err != nil {
+			logger.Warn().Err(err).Str("client", oAuthClient.Name).Str("login", user.Login).Msg("failed to get groups")
+		}
+
+		sessionToken, err := app.SessionIssuer.Issue(oAuthClient.Name, &jwt.PayloadUser{
+			Id:       user.ID,
+			Login:    user.Login,
+			Provider: oAuthClient.ProviderType,
+			Groups:   groups,
+		})
+		if err != nil {
+			logger.Error().Caller().Err(err).Msg("failed to issue session token")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to issue session token: %s", err.Error()),
+			})
+			return
+		}
+
+		app.Audit(audit.Event{
+			Event:     audit.EVENT_SESSION_ISSUED,
+			Decision:  audit.DECISION_ALLOWED,
+			UserID:    user.ID,
+			UserLogin: user.Login,
+			Provider:  oAuthClient.ProviderType,
+			ClientIP:  c.ClientIP(),
+		})
+
+		c.JSON(http.StatusOK, model.ResponseDeviceToken{
+			AccessToken: sessionToken,
+			TokenType:   TOKEN_TYPE_BEARER,
+			ExpiresIn:   int(app.SessionIssuer.TTL().Seconds()),
+		})
+	}
+}
+
+// deviceErrorOf maps the provider errors of a device token poll to the RFC 8628 error codes,
+// empty for the errors that are not part of the protocol.
+func deviceErrorOf(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, provider.ErrAuthorizationPending):
+		return DEVICE_ERROR_AUTHORIZATION_PENDING
+	case errors.Is(err, provider.ErrSlowDown):
+		return DEVICE_ERROR_SLOW_DOWN
+	case errors.Is(err, provider.ErrExpiredToken):
+		return DEVICE_ERROR_EXPIRED_TOKEN
+	case errors.Is(err, provider.ErrAccessDenied):
+		return DEVICE_ERROR_ACCESS_DENIED
+	}
+	return ""
+}
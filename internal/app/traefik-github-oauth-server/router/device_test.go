@@ -0,0 +1,202 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDeviceProvider a fake GitHub Enterprise, its token endpoint answers the device code named after the answer,
+// e.g. authorization_pending, or approved with a token of the user octocat.
+func newTestDeviceProvider(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/device/code", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"device_code":"approved","user_code":"ABCD-EFGH","verification_uri":"https://github.example.com/login/device","expires_in":900,"interval":5}`)
+	})
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch deviceCode := r.PostForm.Get("device_code"); deviceCode {
+		case "approved":
+			_, _ = fmt.Fprint(w, `{"access_token":"token","token_type":"bearer"}`)
+		default:
+			_, _ = fmt.Fprintf(w, `{"error":%q}`, deviceCode)
+		}
+	})
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"id":1,"login":"octocat"}`)
+	})
+	mux.HandleFunc("/api/v3/user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `[{"login":"acme"}]`)
+	})
+	mux.HandleFunc("/api/v3/user/teams", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `[]`)
+	})
+	provider := httptest.NewServer(mux)
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+// newTestDeviceApp an app logging in with the fake provider, the device flow is enabled unless disabled.
+func newTestDeviceApp(t *testing.T, disabled bool) *server.App {
+	t.Helper()
+	provider := newTestDeviceProvider(t)
+	env := map[string]string{
+		"GITHUB_AUTH_URL":    provider.URL + "/login/oauth/authorize",
+		"GITHUB_TOKEN_URL":   provider.URL + "/login/oauth/access_token",
+		"GITHUB_API_URL":     provider.URL + "/api/v3",
+		"SESSION_SECRET_KEY": "session-secret-session-secret-32",
+		// a client without a device authorization endpoint
+		"OAUTH_CLIENTS": fmt.Sprintf(`{"sso":{"client_id":"sso","client_secret":"sso-secret","auth_url":%q,"token_url":%q}}`,
+			provider.URL+"/authorize", provider.URL+"/token"),
+	}
+	if disabled {
+		env["SESSION_SECRET_KEY"] = ""
+	}
+	return newTestApp(t, env)
+}
+
+// postForm posts the form to the path of the oauth group.
+func postForm(app *server.App, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/"+path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return serve(app, req, "")
+}
+
+func TestStartDeviceFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		client string
+		status int
+		error  string
+	}{
+		{name: "default client", client: "", status: http.StatusOK},
+		{name: "unknown client", client: "unknown", status: http.StatusBadRequest, error: DEVICE_ERROR_INVALID_REQUEST},
+		{name: "device flow unsupported", client: "sso", status: http.StatusBadRequest, error: DEVICE_ERROR_UNSUPPORTED_GRANT_TYPE},
+	}
+	app := newTestDeviceApp(t, false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// execution
+			rw := postForm(app, "device/code", url.Values{"client": {tt.client}})
+
+			// assertion
+			assert.Equal(t, tt.status, rw.Code)
+			assert.Contains(t, rw.Header().Get("Cache-Control"), "no-store")
+			if 0 < len(tt.error) {
+				var body model.ResponseDeviceError
+				assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+				assert.Equal(t, tt.error, body.Error)
+				return
+			}
+			var body model.ResponseDeviceCode
+			assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+			assert.Equal(t, "ABCD-EFGH", body.UserCode)
+			assert.Equal(t, 900, body.ExpiresIn)
+			assert.Equal(t, 5, body.Interval)
+			// the device code of the provider is sealed
+			assert.NotEqual(t, "approved", body.DeviceCode)
+			deviceCode, err := app.SessionIssuer.OpenDeviceCode(body.DeviceCode)
+			assert.NoError(t, err)
+			assert.Equal(t, "approved", deviceCode.DeviceCode)
+			assert.Equal(t, server.DefaultOAuthClientName, deviceCode.Client)
+		})
+	}
+}
+
+func TestPollDeviceToken(t *testing.T) {
+	// setup
+	app := newTestDeviceApp(t, false)
+	seal := func(client, deviceCode string, expiresIn time.Duration) string {
+		sealed, err := app.SessionIssuer.SealDeviceCode(client, deviceCode, expiresIn)
+		assert.NoError(t, err)
+		return sealed
+	}
+	approved := seal(server.DefaultOAuthClientName, "approved", time.Minute)
+	tampered := []byte(approved)
+	tampered[len(tampered)/2] ^= 1
+	tests := []struct {
+		name       string
+		deviceCode string
+		status     int
+		error      string
+	}{
+		{name: "authorization pending", deviceCode: seal(server.DefaultOAuthClientName, "authorization_pending", time.Minute), status: http.StatusBadRequest, error: DEVICE_ERROR_AUTHORIZATION_PENDING},
+		{name: "slow down", deviceCode: seal(server.DefaultOAuthClientName, "slow_down", time.Minute), status: http.StatusBadRequest, error: DEVICE_ERROR_SLOW_DOWN},
+		{name: "expired token", deviceCode: seal(server.DefaultOAuthClientName, "expired_token", time.Minute), status: http.StatusBadRequest, error: DEVICE_ERROR_EXPIRED_TOKEN},
+		{name: "access denied", deviceCode: seal(server.DefaultOAuthClientName, "access_denied", time.Minute), status: http.StatusBadRequest, error: DEVICE_ERROR_ACCESS_DENIED},
+		{name: "unknown provider error", deviceCode: seal(server.DefaultOAuthClientName, "incorrect_client_credentials", time.Minute), status: http.StatusBadGateway},
+		{name: "expired device code", deviceCode: seal(server.DefaultOAuthClientName, "approved", -time.Minute), status: http.StatusBadRequest, error: DEVICE_ERROR_EXPIRED_TOKEN},
+		{name: "tampered device code", deviceCode: string(tampered), status: http.StatusBadRequest, error: DEVICE_ERROR_INVALID_GRANT},
+		{name: "unsealed device code", deviceCode: "approved", status: http.StatusBadRequest, error: DEVICE_ERROR_INVALID_GRANT},
+		{name: "unknown client", deviceCode: seal("unknown", "approved", time.Minute), status: http.StatusBadRequest, error: DEVICE_ERROR_INVALID_GRANT},
+		{name: "missing device code", deviceCode: "", status: http.StatusBadRequest, error: DEVICE_ERROR_INVALID_REQUEST},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// execution
+			rw := postForm(app, "device/token", url.Values{"device_code": {tt.deviceCode}})
+
+			// assertion
+			assert.Equal(t, tt.status, rw.Code)
+			if 0 < len(tt.error) {
+				var body model.ResponseDeviceError
+				assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+				assert.Equal(t, tt.error, body.Error)
+			}
+			assert.NotContains(t, rw.Body.String(), "access_token")
+		})
+	}
+}
+
+func TestPollDeviceToken_Approved(t *testing.T) {
+	// setup
+	app := newTestDeviceApp(t, false)
+	deviceCode, _ := app.SessionIssuer.SealDeviceCode(server.DefaultOAuthClientName, "approved", time.Minute)
+
+	// execution
+	rw := postForm(app, "device/token", url.Values{"device_code": {deviceCode}})
+
+	// assertion
+	assert.Equal(t, http.StatusOK, rw.Code)
+	var body model.ResponseDeviceToken
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+	assert.Equal(t, TOKEN_TYPE_BEARER, body.TokenType)
+	assert.Equal(t, int(app.SessionIssuer.TTL().Seconds()), body.ExpiresIn)
+	claims := gojwt.MapClaims{}
+	_, _, err := gojwt.NewParser().ParseUnverified(body.AccessToken, claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims["id"])
+	assert.Equal(t, "octocat", claims["login"])
+	assert.Equal(t, server.DefaultOAuthClientName, claims["aud"])
+}
+
+func TestDeviceFlow_Disabled(t *testing.T) {
+	// setup
+	app := newTestDeviceApp(t, true)
+
+	// execution
+	rwCode := postForm(app, "device/code", url.Values{})
+	rwToken := postForm(app, "device/token", url.Values{"device_code": {"approved"}})
+
+	// assertion
+	for _, rw := range []*httptest.ResponseRecorder{rwCode, rwToken} {
+		assert.Equal(t, http.StatusNotFound, rw.Code)
+		assert.Contains(t, rw.Body.String(), server.ErrDeviceFlowDisabled.Error())
+	}
+}
@@ -1,14 +1,38 @@
 package router
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
 	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
 	"github.com/gin-gonic/gin"
 )
 
+// readinessTimeout bounds the store check, a probe must not hang on an unreachable store.
+const readinessTimeout = 2 * time.Second
+
+// healthCheck the liveness check, the process is up and serving.
 func healthCheck(_ *server.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	}
 }
+
+// readinessCheck answers 503 while the server is draining or its auth request store is unreachable.
+func readinessCheck(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setNoCacheHeaders(c)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		if err := app.Ready(ctx); err != nil {
+			c.JSON(http.StatusServiceUnavailable, model.ResponseError{
+				Message: fmt.Sprintf("[server]not ready: %s", err),
+			})
+			return
+		}
+		c.Status(http.StatusOK)
+	}
+}
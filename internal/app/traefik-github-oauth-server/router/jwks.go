@@ -0,0 +1,17 @@
+package router
+
+import (
+	"net/http"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/gin-gonic/gin"
+)
+
+// getJWKS publishes the public key verifying the session tokens, the middleware fetches it again on an unknown kid.
+func getJWKS(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(constant.HTTP_HEADER_CACHE_CONTROL, "public, max-age=300")
+		c.JSON(http.StatusOK, app.SessionIssuer.JWKS())
+	}
+}
@@ -0,0 +1,192 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/gin-gonic/gin"
+)
+
+// loginPageContentSecurityPolicy the page has no script, loads its logo from anywhere, and cannot be framed.
+const loginPageContentSecurityPolicy = "default-src 'none'; img-src http: https:; style-src 'unsafe-inline'; frame-ancestors 'none'"
+
+// providerDisplayNames the names of the providers on the sign-in button, the OIDC provider has none.
+var providerDisplayNames = map[string]string{
+	provider.TYPE_GITHUB: "GitHub",
+	provider.TYPE_GITLAB: "GitLab",
+	provider.TYPE_GITEA:  "Gitea",
+}
+
+var loginPageTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to {{.AppName}}</title>
+<style>
+body{margin:0;min-height:100vh;display:flex;align-items:center;justify-content:center;background:#f6f8fa;color:#24292f;font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Helvetica,Arial,sans-serif}
+main{width:100%;max-width:360px;padding:32px;background:#fff;border:1px solid #d0d7de;border-radius:6px;text-align:center}
+img{max-width:96px;max-height:96px;margin-bottom:16px}
+h1{margin:0 0 4px;font-size:20px;font-weight:600}
+.host{margin:0 0 16px;color:#57606a;font-size:14px}
+.message{margin:0 0 16px;font-size:14px;white-space:pre-line}
+a.button{display:block;padding:8px 16px;background:#1f883d;color:#fff;border-radius:6px;font-weight:600;text-decoration:none}
+footer{margin-top:16px;color:#8c959f;font-size:12px}
+</style>
+</head>
+<body>
+<main>
+{{if .LogoURL}}<img src="{{.LogoURL}}" alt="">{{end}}
+<h1>Sign in to {{.AppName}}</h1>
+<p class="host">{{.Host}}</p>
+{{if .Message}}<p class="message">{{.Message}}</p>{{end}}
+<a class="button" href="{{.OAuthPageURL}}">Sign in{{if .ProviderName}} with {{.ProviderName}}{{end}}</a>
+<footer>request id: {{.RequestID}}</footer>
+</main>
+</body>
+</html>
+`))
+
+type loginPageData struct {
+	AppName      string
+	Host         string
+	LogoURL      string
+	Message      string
+	ProviderName string
+	OAuthPageURL string
+	RequestID    string
+}
+
+// loginPage shows the sign-in page of an auth request, naming the application before the redirect to the provider.
+func loginPage(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		setNoCacheHeaders(c)
+		query := model.RequestLoginPage{}
+		err := c.ShouldBindQuery(&query)
+		if err != nil {
+			logger.Debug().Err(err).Msg("invalid request")
+			errorPage(c, http.StatusBadRequest, "invalid request: %s", err.Error())
+			return
+		}
+
+		authRequest, err := peekAuthRequest(c.Request.Context(), app, &query)
+		if isInvalidAuthRequest(err) || (err == nil && (authRequest.LoginPage == nil || 0 < len(authRequest.GitHubUserID))) {
+			logger.Debug().Err(err).Str("rid", query.RID).Msg("invalid rid")
+			errorPage(c, http.StatusBadRequest, "%s", ErrInvalidRID.Error())
+			return
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Msg("failed to get auth request")
+			errorPage(c, http.StatusInternalServerError, "%s", err.Error())
+			return
+		}
+
+		oAuthClient, found := app.OAuthClient(authRequest.Client)
+		if !found {
+			logger.Warn().Str("rid", query.RID).Str("client", authRequest.Client).Msg("unknown client")
+			errorPage(c, http.StatusBadRequest, "%s: %s", ErrUnknownClient.Error(), authRequest.Client)
+			return
+		}
+
+		redirectURI, err := buildRedirectURI(oAuthClient.ApiBaseURL, query.RID)
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Str("api_base_url", oAuthClient.ApiBaseURL).Msg("failed to build redirect uri")
+			errorPage(c, http.StatusInternalServerError, "%s: %s", err.Error(), oAuthClient.ApiBaseURL)
+			return
+		}
+		oAuthPageURL, err := oAuthClient.Provider.AuthCodeURL(c.Request.Context(), query.State, redirectURI, authCodeOptions(authRequest))
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Str("client", oAuthClient.Name).Msg("failed to build OAuth page url")
+			errorPage(c, http.StatusInternalServerError, "failed to build OAuth page url: %s", err.Error())
+			return
+		}
+
+		var page bytes.Buffer
+		err = loginPageTemplate.Execute(&page, loginPageData{
+			AppName:      authRequest.LoginPage.AppName,
+			Host:         hostOf(authRequest.RedirectURI),
+			LogoURL:      authRequest.LoginPage.LogoURL,
+			Message:      authRequest.LoginPage.Message,
+			ProviderName: providerDisplayNames[oAuthClient.ProviderType],
+			OAuthPageURL: oAuthPageURL,
+			RequestID:    c.GetString(server.CONTEXT_KEY_REQUEST_ID),
+		})
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Msg("failed to render login page")
+			errorPage(c, http.StatusInternalServerError, "%s", err.Error())
+			return
+		}
+		c.Header(constant.HTTP_HEADER_CONTENT_SECURITY_POLICY, loginPageContentSecurityPolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+	}
+}
+
+// newLoginPage returns the sign-in page of a login, the one of the middleware overriding the one of the client,
+// or nil if neither enables it.
+func newLoginPage(oAuthClient *server.OAuthClient, body *model.RequestGenerateOAuthPageURL) *model.LoginPage {
+	requested := body.LoginPage
+	if !oAuthClient.LoginPage && (requested == nil || !requested.Enabled) {
+		return nil
+	}
+	page := &model.LoginPage{
+		Enabled: true,
+		LogoURL: oAuthClient.LoginPageLogoURL,
+		Message: oAuthClient.LoginPageMessage,
+	}
+	if requested != nil {
+		page.AppName = requested.AppName
+		if 0 < len(requested.LogoURL) {
+			page.LogoURL = requested.LogoURL
+		}
+		if 0 < len(requested.Message) {
+			page.Message = requested.Message
+		}
+	}
+	if len(page.AppName) == 0 {
+		page.AppName = hostOf(body.RedirectURI)
+	}
+	return page
+}
+
+// authCodeOptions the options of the OAuth page of an auth request.
+func authCodeOptions(aq *model.AuthRequest) provider.AuthCodeOptions {
+	return provider.AuthCodeOptions{
+		Scopes:         aq.Scopes,
+		DisallowSignup: aq.DisallowSignup,
+		LoginHint:      aq.LoginHint,
+	}
+}
+
+// peekAuthRequest loads the auth request of the sign-in page, without using the state of stateless mode.
+func peekAuthRequest(ctx context.Context, app *server.App, query *model.RequestLoginPage) (*model.AuthRequest, error) {
+	if app.AuthRequestSealer != nil && len(query.RID) == 0 {
+		return app.AuthRequestSealer.PeekState(query.State)
+	}
+	return app.AuthRequestManager.Get(ctx, query.RID)
+}
+
+// buildLoginPageURL the URL of the sign-in page, naming the auth request by rid, or by state in stateless mode.
+func buildLoginPageURL(apiBaseUrl, rid, state string) (string, error) {
+	loginPageURL, err := url.Parse(apiBaseUrl)
+	if err != nil {
+		return "", ErrInvalidApiBaseURL
+	}
+	loginPageURL = loginPageURL.JoinPath(constant.ROUTER_GROUP_PATH_OAUTH, constant.ROUTER_PATH_OAUTH_LOGIN)
+	loginPageQuery := loginPageURL.Query()
+	if 0 < len(rid) {
+		loginPageQuery.Set(constant.QUERY_KEY_REQUEST_ID, rid)
+	}
+	if 0 < len(state) {
+		loginPageQuery.Set(constant.QUERY_KEY_STATE, state)
+	}
+	loginPageURL.RawQuery = loginPageQuery.Encode()
+	return loginPageURL.String(), nil
+}
@@ -0,0 +1,152 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+var loginPageHrefPattern = regexp.MustCompile(`<a class="button" href="([^"]*)"`)
+
+// requestLoginPageURL asks the server for the OAuth page URL of the body, the sign-in page when it is enabled.
+func requestLoginPageURL(t *testing.T, app *server.App, body model.RequestGenerateOAuthPageURL) string {
+	t.Helper()
+	payload, err := json.Marshal(body)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/page-url", bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	rw := serve(app, req, testApiSecretKey)
+	if !assert.Equal(t, http.StatusCreated, rw.Code, rw.Body.String()) {
+		t.FailNow()
+	}
+	result := model.ResponseGenerateOAuthPageURL{}
+	if !assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &result)) {
+		t.FailNow()
+	}
+	return result.OAuthPageURL
+}
+
+func TestLoginPage(t *testing.T) {
+	// setup
+	app := newTestApp(t, nil)
+	loginPageURL := requestLoginPageURL(t, app, model.RequestGenerateOAuthPageURL{
+		RedirectURI: "https://whoami.example.com/_oauth",
+		AuthURL:     "https://whoami.example.com/",
+		LoginPage: &model.LoginPage{
+			Enabled: true,
+			AppName: `<script>alert("app")</script>`,
+			LogoURL: "javascript:alert(1)",
+			Message: `<img src=x onerror="alert(1)">`,
+		},
+	})
+	pageURL, err := url.Parse(loginPageURL)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "oauth.example.com", pageURL.Host)
+	assert.Equal(t, "/oauth/login", pageURL.Path)
+	rid := pageURL.Query().Get(constant.QUERY_KEY_REQUEST_ID)
+
+	// execution
+	rw := serve(app, httptest.NewRequest(http.MethodGet, pageURL.RequestURI(), nil), "")
+
+	// assertion
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, loginPageContentSecurityPolicy, rw.Header().Get(constant.HTTP_HEADER_CONTENT_SECURITY_POLICY))
+	assert.Equal(t, "text/html; charset=utf-8", rw.Header().Get("Content-Type"))
+	page := rw.Body.String()
+	assert.Contains(t, page, "Sign in to &lt;script&gt;alert(&#34;app&#34;)&lt;/script&gt;")
+	assert.NotContains(t, page, "<script>")
+	assert.Contains(t, page, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;")
+	assert.NotContains(t, page, "onerror=\"")
+	assert.NotContains(t, page, "javascript:")
+	assert.Contains(t, page, `<p class="host">whoami.example.com</p>`)
+	assert.Contains(t, page, "Sign in with GitHub")
+
+	// the sign-in button leads to the provider, which redirects back to the server, never to the middleware
+	matches := loginPageHrefPattern.FindStringSubmatch(page)
+	if !assert.Len(t, matches, 2) {
+		t.FailNow()
+	}
+	authCodeURL, err := url.Parse(html.UnescapeString(matches[1]))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "https://github.com/login/oauth/authorize", authCodeURL.Scheme+"://"+authCodeURL.Host+authCodeURL.Path)
+	redirectURI, err := url.Parse(authCodeURL.Query().Get("redirect_uri"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "oauth.example.com", redirectURI.Host)
+	assert.Equal(t, "/oauth/redirect", redirectURI.Path)
+	assert.Equal(t, rid, redirectURI.Query().Get(constant.QUERY_KEY_REQUEST_ID))
+}
+
+func TestLoginPage_InvalidRID(t *testing.T) {
+	// setup
+	app := newTestApp(t, nil)
+	insert := func(aq *model.AuthRequest) string {
+		rid, err := app.AuthRequestManager.Insert(context.Background(), aq)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return rid
+	}
+	withoutLoginPage := insert(&model.AuthRequest{
+		RedirectURI: "https://whoami.example.com/_oauth",
+	})
+	completed := insert(&model.AuthRequest{
+		RedirectURI:     "https://whoami.example.com/_oauth",
+		LoginPage:       &model.LoginPage{Enabled: true, AppName: "whoami"},
+		GitHubUserID:    "1",
+		GitHubUserLogin: "octocat",
+	})
+
+	tests := []struct {
+		name string
+		rid  string
+	}{
+		{
+			name: "missing",
+			rid:  "",
+		},
+		{
+			name: "unknown",
+			rid:  "unknown",
+		},
+		{
+			name: "without login page",
+			rid:  withoutLoginPage,
+		},
+		{
+			name: "completed",
+			rid:  completed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// execution
+			rw := serve(app, httptest.NewRequest(http.MethodGet, "/oauth/login?rid="+url.QueryEscape(tt.rid), nil), "")
+
+			// assertion
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Contains(t, rw.Body.String(), ErrInvalidRID.Error())
+			assert.Empty(t, rw.Header().Get(constant.HTTP_HEADER_CONTENT_SECURITY_POLICY))
+			assert.NotContains(t, rw.Body.String(), "github.com/login/oauth/authorize")
+		})
+	}
+}
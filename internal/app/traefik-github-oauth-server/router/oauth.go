@@ -2,62 +2,140 @@ package router
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/store"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit"
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/seal"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/tracing"
 	"github.com/gin-gonic/gin"
-	"github.com/google/go-github/v49/github"
-	"github.com/spf13/cast"
 	"golang.org/x/oauth2"
 )
 
+// retryAfterTooManyAuthRequests the Retry-After sent when the auth request cap is reached.
+const retryAfterTooManyAuthRequests = time.Minute
+
 var (
 	ErrInvalidApiBaseURL = fmt.Errorf("invalid api base url")
 	ErrInvalidRID        = fmt.Errorf("invalid rid")
 	ErrInvalidAuthURL    = fmt.Errorf("invalid auth url")
+	ErrUnknownClient     = fmt.Errorf("unknown client")
 )
 
 func generateOAuthPageURL(app *server.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
 		setNoCacheHeaders(c)
 		body := model.RequestGenerateOAuthPageURL{}
 		err := c.ShouldBindJSON(&body)
 		if err != nil {
-			app.Logger.Debug().Err(err).Msg("invalid request")
+			logger.Debug().Err(err).Msg("invalid request")
 			c.JSON(http.StatusBadRequest, model.ResponseError{
 				Message: fmt.Sprintf("invalid request: %s", err.Error()),
 			})
 			return
 		}
 
-		rid := app.AuthRequestManager.Insert(&model.AuthRequest{
+		oAuthClient, found := app.OAuthClient(body.Client)
+		if !found {
+			logger.Debug().Str("client", body.Client).Msg("unknown client")
+			c.JSON(http.StatusBadRequest, model.ResponseError{
+				Message: fmt.Sprintf("%s: %s", ErrUnknownClient.Error(), body.Client),
+			})
+			return
+		}
+
+		scopes, err := oAuthClient.LoginScopes(body.Scopes)
+		if err != nil {
+			logger.Debug().Err(err).Str("client", oAuthClient.Name).Msg("scope not allowed")
+			c.JSON(http.StatusBadRequest, model.ResponseError{
+				Message: err.Error(),
+			})
+			return
+		}
+
+		clientIP := body.ClientIP
+		if len(clientIP) == 0 {
+			clientIP = c.ClientIP()
+		}
+		authRequest := &model.AuthRequest{
 			RedirectURI: body.RedirectURI,
 			AuthURL:     body.AuthURL,
-		})
+			ClientIP:    clientIP,
+			Client:      oAuthClient.Name,
+
+			ForwardAccessToken: body.ForwardAccessToken,
+			Scopes:             scopes,
+			DisallowSignup:     body.DisallowSignup || !oAuthClient.AllowSignup,
+			LoginHint:          body.LoginHint,
+			LoginPage:          newLoginPage(oAuthClient, &body),
+		}
+		rid, state, err := startAuthRequest(c.Request.Context(), app, authRequest)
+		if errors.Is(err, server.ErrTooManyAuthRequests) {
+			logger.Warn().Err(err).Str("client_ip", clientIP).Msg("auth request rejected")
+			server.AbortWithTooManyRequests(c, retryAfterTooManyAuthRequests)
+			return
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Msg("failed to start auth request")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to start auth request: %s", err.Error()),
+			})
+			return
+		}
+
+		c.Set(server.CONTEXT_KEY_RID, rid)
 
-		redirectURI, err := buildRedirectURI(app.Config.ApiBaseURL, rid)
+		// in stateless mode the auth request travels in the state, the redirect uri carries no rid
+		redirectRID := rid
+		if app.AuthRequestSealer != nil {
+			redirectRID = ""
+		}
+		redirectURI, err := buildRedirectURI(oAuthClient.ApiBaseURL, redirectRID)
 		if err != nil {
-			app.Logger.Error().
+			logger.Error().
 				Caller().
 				Stack().
 				Err(err).
 				Str("rid", rid).
-				Str("api_base_url", app.Config.ApiBaseURL).
+				Str("api_base_url", oAuthClient.ApiBaseURL).
 				Msg("failed to build redirect uri")
 			c.JSON(http.StatusInternalServerError, model.ResponseError{
-				Message: fmt.Sprintf("[server]%s: %s", err.Error(), app.Config.ApiBaseURL),
+				Message: fmt.Sprintf("[server]%s: %s", err.Error(), oAuthClient.ApiBaseURL),
 			})
 			return
 		}
 
-		oAuthPageURL := app.GitHubOAuthConfig.AuthCodeURL(
-			"",
-			oauth2.SetAuthURLParam(constant.QUERY_KEY_REDIRECT_URI, redirectURI),
-		)
+		var oAuthPageURL string
+		if authRequest.LoginPage != nil {
+			oAuthPageURL, err = buildLoginPageURL(oAuthClient.ApiBaseURL, redirectRID, state)
+		} else {
+			oAuthPageURL, err = oAuthClient.Provider.AuthCodeURL(c.Request.Context(), state, redirectURI, authCodeOptions(authRequest))
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", rid).Str("client", oAuthClient.Name).Msg("failed to build OAuth page url")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to build OAuth page url: %s", err.Error()),
+			})
+			return
+		}
+
+		app.Audit(audit.Event{
+			Event:    audit.EVENT_LOGIN_STARTED,
+			Host:     hostOf(body.RedirectURI),
+			ClientIP: clientIP,
+			RID:      rid,
+		})
 
 		c.JSON(
 			http.StatusCreated,
@@ -70,50 +148,107 @@ func generateOAuthPageURL(app *server.App) gin.HandlerFunc {
 
 func redirect(app *server.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
 		setNoCacheHeaders(c)
 		query := model.RequestRedirect{}
 		err := c.BindQuery(&query)
 		if err != nil {
-			app.Logger.Debug().Err(err).Msg("invalid request")
+			logger.Debug().Err(err).Msg("invalid request")
 			return
 		}
 
-		authRequest, found := app.AuthRequestManager.Get(query.RID)
+		authRequest, err := loadAuthRequest(c.Request.Context(), app, &query)
+		if isInvalidAuthRequest(err) {
+			logger.Debug().Err(err).Str("rid", query.RID).Msg("invalid rid")
+			errorPage(c, http.StatusBadRequest, "%s", ErrInvalidRID.Error())
+			return
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Msg("failed to get auth request")
+			errorPage(c, http.StatusInternalServerError, "%s", err.Error())
+			return
+		}
+
+		oAuthClient, found := app.OAuthClient(authRequest.Client)
 		if !found {
-			app.Logger.Debug().Str("rid", query.RID).Msg("invalid rid")
-			c.String(http.StatusBadRequest, ErrInvalidRID.Error())
+			logger.Warn().Str("rid", query.RID).Str("client", authRequest.Client).Msg("unknown client")
+			errorPage(c, http.StatusBadRequest, "%s: %s", ErrUnknownClient.Error(), authRequest.Client)
 			return
 		}
 
-		user, err := oAuthCodeToUser(c.Request.Context(), app.GitHubOAuthConfig, query.Code)
+		// the provider checks the redirect uri of the exchange against the one of the OAuth page url
+		redirectURI, err := buildRedirectURI(oAuthClient.ApiBaseURL, query.RID)
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Str("api_base_url", oAuthClient.ApiBaseURL).Msg("failed to build redirect uri")
+			errorPage(c, http.StatusInternalServerError, "%s: %s", err.Error(), oAuthClient.ApiBaseURL)
+			return
+		}
+		user, groups, token, err := oAuthCodeToUser(c.Request.Context(), app, oAuthClient, query.Code, redirectURI, authRequest.Scopes)
 		if err != nil {
-			app.Logger.Error().
+			logger.Error().
 				Caller().
 				Stack().
 				Str("rid", query.RID).
 				Str("redirect_uri", authRequest.RedirectURI).
 				Str("auth_url", authRequest.AuthURL).
 				Err(err).
-				Msg("failed to get GitHub user")
-			c.String(http.StatusInternalServerError, err.Error())
+				Msg("failed to get user")
+			app.Audit(audit.Event{
+				Event:    audit.EVENT_CODE_EXCHANGED,
+				Decision: audit.DECISION_DENIED,
+				Reason:   err.Error(),
+				Provider: oAuthClient.ProviderType,
+				Host:     hostOf(authRequest.RedirectURI),
+				ClientIP: c.ClientIP(),
+				RID:      authRequestID(query.RID, authRequest),
+			})
+			if errors.Is(err, provider.ErrAccessDenied) {
+				errorPage(c, http.StatusForbidden, "%s", err.Error())
+				return
+			}
+			errorPage(c, http.StatusInternalServerError, "%s", err.Error())
 			return
 		}
 
-		authRequest.GitHubUserID = cast.ToString(user.GetID())
-		authRequest.GitHubUserLogin = user.GetLogin()
+		authRequest.GitHubUserID = user.ID
+		authRequest.GitHubUserLogin = user.Login
+		authRequest.Provider = oAuthClient.ProviderType
+		authRequest.Groups = groups
+		if authRequest.ForwardAccessToken {
+			authRequest.SealedAccessToken, err = sealAccessToken(app, oAuthClient, user, token)
+			if err != nil {
+				// the login goes on, the upstream gets no access token
+				logger.Warn().Err(err).Str("rid", query.RID).Str("client", oAuthClient.Name).Msg("failed to seal access token")
+			}
+		}
+		resultRID, err := completeAuthRequest(c.Request.Context(), app, query.RID, authRequest)
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Msg("failed to complete auth request")
+			errorPage(c, http.StatusInternalServerError, "%s", err.Error())
+			return
+		}
+		app.Audit(audit.Event{
+			Event:     audit.EVENT_CODE_EXCHANGED,
+			UserID:    authRequest.GitHubUserID,
+			UserLogin: authRequest.GitHubUserLogin,
+			Provider:  authRequest.Provider,
+			Host:      hostOf(authRequest.RedirectURI),
+			ClientIP:  c.ClientIP(),
+			RID:       authRequestID(query.RID, authRequest),
+		})
 
 		authURL, err := url.Parse(authRequest.AuthURL)
 		if err != nil {
-			app.Logger.Warn().
+			logger.Warn().
 				Err(err).
 				Str("rid", query.RID).
 				Str("auth_url", authRequest.AuthURL).
 				Msg("invalid auth url")
-			c.String(http.StatusInternalServerError, "%s: %s", ErrInvalidAuthURL.Error(), authRequest.AuthURL)
+			errorPage(c, http.StatusInternalServerError, "%s: %s", ErrInvalidAuthURL.Error(), authRequest.AuthURL)
 			return
 		}
 		authURLQuery := authURL.Query()
-		authURLQuery.Set(constant.QUERY_KEY_REQUEST_ID, query.RID)
+		authURLQuery.Set(constant.QUERY_KEY_REQUEST_ID, resultRID)
 		authURL.RawQuery = authURLQuery.Encode()
 
 		c.Redirect(http.StatusFound, authURL.String())
@@ -122,25 +257,57 @@ func redirect(app *server.App) gin.HandlerFunc {
 
 func getAuthResult(app *server.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
 		setNoCacheHeaders(c)
 		query := model.RequestGetAuthResult{}
 		err := c.ShouldBindQuery(&query)
 		if err != nil {
-			app.Logger.Debug().Err(err).Msg("invalid request")
+			logger.Debug().Err(err).Msg("invalid request")
 			c.JSON(http.StatusBadRequest, model.ResponseError{
 				Message: fmt.Sprintf("invalid request: %s", err.Error()),
 			})
 			return
 		}
 
-		authRequest, found := app.AuthRequestManager.Pop(query.RID)
-		if !found {
-			app.Logger.Debug().Str("rid", query.RID).Msg("invalid rid")
+		authRequest, err := claimAuthResult(c.Request.Context(), app, query.RID)
+		if isInvalidAuthRequest(err) {
+			logger.Debug().Err(err).Str("rid", query.RID).Msg("invalid rid")
 			c.JSON(http.StatusBadRequest, model.ResponseError{
 				Message: ErrInvalidRID.Error(),
 			})
 			return
 		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Msg("failed to pop auth request")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to pop auth request: %s", err.Error()),
+			})
+			return
+		}
+
+		sessionToken, err := app.SessionIssuer.Issue(authRequest.Client, &jwt.PayloadUser{
+			Id:       authRequest.GitHubUserID,
+			Login:    authRequest.GitHubUserLogin,
+			Provider: authRequest.Provider,
+			Groups:   authRequest.Groups,
+		})
+		if err != nil {
+			logger.Error().Caller().Err(err).Str("rid", query.RID).Msg("failed to issue session token")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to issue session token: %s", err.Error()),
+			})
+			return
+		}
+
+		app.Audit(audit.Event{
+			Event:     audit.EVENT_RESULT_CLAIMED,
+			UserID:    authRequest.GitHubUserID,
+			UserLogin: authRequest.GitHubUserLogin,
+			Provider:  authRequest.Provider,
+			Host:      hostOf(authRequest.RedirectURI),
+			ClientIP:  authRequest.ClientIP,
+			RID:       authRequestID(query.RID, authRequest),
+		})
 
 		c.JSON(
 			http.StatusOK,
@@ -148,29 +315,136 @@ func getAuthResult(app *server.App) gin.HandlerFunc {
 				RedirectURI:     authRequest.RedirectURI,
 				GitHubUserID:    authRequest.GitHubUserID,
 				GitHubUserLogin: authRequest.GitHubUserLogin,
+				Provider:        authRequest.Provider,
+				Groups:          authRequest.Groups,
+				SessionToken:    sessionToken,
+
+				SealedAccessToken: authRequest.SealedAccessToken,
 			},
 		)
 	}
 }
 
-func oAuthCodeToUser(ctx context.Context, oAuthConfig *oauth2.Config, code string) (*github.User, error) {
+// oAuthCodeToUser exchanges the code, checks the user granted the scopes, and fetches the user,
+// its groups and the token of the exchange. Failing to fetch the groups is not fatal, the user then has none.
+func oAuthCodeToUser(
+	ctx context.Context,
+	app *server.App,
+	oAuthClient *server.OAuthClient,
+	code, redirectURI string,
+	scopes []string,
+) (*provider.User, []string, *oauth2.Token, error) {
 	ctxExchange, cancelExchange := context.WithCancel(ctx)
 	defer cancelExchange()
-	token, err := oAuthConfig.Exchange(ctxExchange, code)
+	ctxExchange, exchangeSpan := startProviderSpan(ctxExchange, app, oAuthClient, "oauth.exchange")
+	token, err := oAuthClient.Provider.Exchange(ctxExchange, code, redirectURI)
+	if err == nil {
+		err = checkGrantedScopes(token, scopes)
+	}
+	exchangeSpan.SetError(err)
+	exchangeSpan.End()
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
-	ctxClient, cancelClient := context.WithCancel(ctx)
-	defer cancelClient()
-	gitHubApiHttpClient := oAuthConfig.Client(ctxClient, token)
-	gitHubApiClient := github.NewClient(gitHubApiHttpClient)
 	ctxGetUser, cancelGetUser := context.WithCancel(ctx)
 	defer cancelGetUser()
-	user, _, err := gitHubApiClient.Users.Get(ctxGetUser, "")
+	ctxGetUser, userSpan := startProviderSpan(ctxGetUser, app, oAuthClient, "oauth.user")
+	user, err := oAuthClient.Provider.User(ctxGetUser, token)
+	userSpan.SetError(err)
+	userSpan.End()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ctxGetGroups, cancelGetGroups := context.WithCancel(ctx)
+	defer cancelGetGroups()
+	ctxGetGroups, groupsSpan := startProviderSpan(ctxGetGroups, app, oAuthClient, "oauth.groups")
+	groups, err := oAuthClient.Provider.Groups(ctxGetGroups, token)
+	groupsSpan.SetAttribute("oauth.groups", len(groups))
+	groupsSpan.SetError(err)
+	groupsSpan.End()
 	if err != nil {
-		return nil, err
+		app.RequestLogger(ctx).Warn().Err(err).Str("client", oAuthClient.Name).Str("login", user.Login).Msg("failed to get groups")
+	}
+	return user, groups, token, nil
+}
+
+// checkGrantedScopes fails if the user narrowed the scopes of the login, when the provider reports the granted scopes.
+func checkGrantedScopes(token *oauth2.Token, scopes []string) error {
+	granted, ok := provider.GrantedScopes(token)
+	if !ok {
+		return nil
+	}
+	if missing := provider.MissingScopes(scopes, granted); 0 < len(missing) {
+		return fmt.Errorf("%w: %s", provider.ErrScopesNotGranted, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// errorPage answers the browser with a plain text error, ending with the request id that finds the logs of the failure.
+func errorPage(c *gin.Context, code int, format string, values ...interface{}) {
+	c.String(code, format+"\n\nrequest id: %s", append(values, c.GetString(server.CONTEXT_KEY_REQUEST_ID))...)
+}
+
+// startProviderSpan starts the client span of a call to the identity provider.
+func startProviderSpan(ctx context.Context, app *server.App, oAuthClient *server.OAuthClient, name string) (context.Context, *tracing.Span) {
+	ctx, span := app.Tracer.Start(ctx, name, tracing.SPAN_KIND_CLIENT)
+	span.SetAttribute("oauth.client", oAuthClient.Name)
+	span.SetAttribute("oauth.provider", oAuthClient.ProviderType)
+	return ctx, span
+}
+
+// startAuthRequest stores a new auth request, or seals it into the returned OAuth state in stateless mode.
+func startAuthRequest(ctx context.Context, app *server.App, aq *model.AuthRequest) (rid, state string, err error) {
+	if app.AuthRequestSealer != nil {
+		state, err = app.AuthRequestSealer.SealState(aq)
+		return aq.Nonce, state, err
 	}
-	return user, nil
+	rid, err = app.AuthRequestManager.Insert(ctx, aq)
+	return rid, "", err
+}
+
+// loadAuthRequest loads the auth request GitHub redirected back with.
+func loadAuthRequest(ctx context.Context, app *server.App, query *model.RequestRedirect) (*model.AuthRequest, error) {
+	if app.AuthRequestSealer != nil && len(query.RID) == 0 {
+		return app.AuthRequestSealer.OpenState(query.State)
+	}
+	return app.AuthRequestManager.Get(ctx, query.RID)
+}
+
+// completeAuthRequest stores the result of the login and returns the rid the middleware claims it with,
+// in stateless mode the rid is the sealed result itself.
+func completeAuthRequest(ctx context.Context, app *server.App, rid string, aq *model.AuthRequest) (string, error) {
+	if app.AuthRequestSealer != nil && len(rid) == 0 {
+		return app.AuthRequestSealer.SealResult(aq)
+	}
+	return rid, app.AuthRequestManager.Update(ctx, rid, aq)
+}
+
+// claimAuthResult returns the result of the login, it can only be claimed once.
+func claimAuthResult(ctx context.Context, app *server.App, rid string) (*model.AuthRequest, error) {
+	if app.AuthRequestSealer != nil {
+		aq, err := app.AuthRequestSealer.OpenResult(rid)
+		if !errors.Is(err, seal.ErrInvalidSealed) {
+			return aq, err
+		}
+		// not a sealed result, it may have been started before switching to stateless mode
+	}
+	return app.AuthRequestManager.Pop(ctx, rid)
+}
+
+func isInvalidAuthRequest(err error) bool {
+	return errors.Is(err, store.ErrNotFound) ||
+		errors.Is(err, seal.ErrInvalidSealed) ||
+		errors.Is(err, server.ErrAuthRequestExpired) ||
+		errors.Is(err, server.ErrAuthRequestReplayed)
+}
+
+// authRequestID identifies the auth request in logs, the sealed rid of stateless mode is replaced by the nonce.
+func authRequestID(rid string, aq *model.AuthRequest) string {
+	if 0 < len(aq.Nonce) {
+		return aq.Nonce
+	}
+	return rid
 }
 
 func buildRedirectURI(apiBaseUrl, rid string) (string, error) {
@@ -179,12 +453,22 @@ func buildRedirectURI(apiBaseUrl, rid string) (string, error) {
 		return "", ErrInvalidApiBaseURL
 	}
 	redirectURI = redirectURI.JoinPath(constant.ROUTER_GROUP_PATH_OAUTH, constant.ROUTER_PATH_OAUTH_REDIRECT)
-	redirectURLQuery := redirectURI.Query()
-	redirectURLQuery.Set(constant.QUERY_KEY_REQUEST_ID, rid)
-	redirectURI.RawQuery = redirectURLQuery.Encode()
+	if 0 < len(rid) {
+		redirectURLQuery := redirectURI.Query()
+		redirectURLQuery.Set(constant.QUERY_KEY_REQUEST_ID, rid)
+		redirectURI.RawQuery = redirectURLQuery.Encode()
+	}
 	return redirectURI.String(), nil
 }
 
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
 func setNoCacheHeaders(c *gin.Context) {
 	c.Header(constant.HTTP_HEADER_CACHE_CONTROL, "no-cache, no-store, must-revalidate, private")
 	c.Header(constant.HTTP_HEADER_PRAGMA, "no-cache")
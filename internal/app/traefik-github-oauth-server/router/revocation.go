@@ -0,0 +1,101 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/gin-gonic/gin"
+)
+
+// getRevocations returns the revocation list, or 304 when the If-None-Match of the middleware is still current.
+func getRevocations(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		setNoCacheHeaders(c)
+		list, etag, err := app.RevocationStore.List()
+		if err != nil {
+			logger.Error().Caller().Err(err).Msg("failed to read revocation list")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to read revocation list: %s", err.Error()),
+			})
+			return
+		}
+		c.Header(constant.HTTP_HEADER_ETAG, etag)
+		if c.GetHeader(constant.HTTP_HEADER_IF_NONE_MATCH) == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+func revoke(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		body := model.RequestRevoke{}
+		err := c.ShouldBindJSON(&body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ResponseError{
+				Message: fmt.Sprintf("invalid request: %s", err.Error()),
+			})
+			return
+		}
+		before := time.Now()
+		if body.Before != nil {
+			before = *body.Before
+		}
+		switch {
+		case body.All:
+			err = app.RevocationStore.RevokeAll(before)
+		case 0 < len(body.UserID):
+			providerType := body.Provider
+			if len(providerType) == 0 {
+				providerType = provider.TYPE_GITHUB
+			}
+			err = app.RevocationStore.RevokeUser(providerType, body.UserID, before)
+		case 0 < len(body.JTI):
+			// no session outlives the session ttl, the entry can be dropped after it
+			err = app.RevocationStore.RevokeToken(body.JTI, time.Now().Add(app.SessionIssuer.TTL()))
+		default:
+			c.JSON(http.StatusBadRequest, model.ResponseError{
+				Message: "invalid request: one of all, user_id and jti is required",
+			})
+			return
+		}
+		if err != nil {
+			logger.Error().Caller().Err(err).Msg("failed to update revocation list")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to update revocation list: %s", err.Error()),
+			})
+			return
+		}
+		logger.Info().
+			Bool("all", body.All).
+			Str("provider", body.Provider).
+			Str("user_id", body.UserID).
+			Str("jti", body.JTI).
+			Time("before", before).
+			Msg("sessions revoked")
+		getRevocations(app)(c)
+	}
+}
+
+func clearRevocations(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		if err := app.RevocationStore.Clear(); err != nil {
+			logger.Error().Caller().Err(err).Msg("failed to clear revocation list")
+			c.JSON(http.StatusInternalServerError, model.ResponseError{
+				Message: fmt.Sprintf("[server]failed to clear revocation list: %s", err.Error()),
+			})
+			return
+		}
+		logger.Info().Msg("revocation list cleared")
+		c.Status(http.StatusNoContent)
+	}
+}
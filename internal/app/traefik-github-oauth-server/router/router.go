@@ -9,24 +9,82 @@ import (
 )
 
 func RegisterRoutes(app *server.App) {
-	apiSecretKeyMiddleware := server.NewApiSecretKeyMiddleware(app.Config.ApiSecretKey)
+	app.Engine.Use(server.NewTracingMiddleware(app.Tracer))
+
+	apiSecretKeyMiddleware := server.NewApiSecretKeyMiddleware(func() string {
+		return app.Config().ApiSecretKey
+	})
+	adminSecretKeyMiddleware := server.NewAdminSecretKeyMiddleware(func() string {
+		return app.Config().AdminSecretKey
+	})
+	// the routes of the browsers and command-line clients are limited per client IP,
+	// the server-to-server routes of the middleware per api key
+	ipRateLimitMiddleware := server.NewRateLimitMiddleware(app.IPRateLimiter)
+	apiKeyRateLimitMiddleware := server.NewApiKeyRateLimitMiddleware(app.IPRateLimiter, app.ApiKeyRateLimiter)
+	// the routes of the middleware require its client certificate with TLS_CLIENT_CA_FILE
+	clientCertificateMiddleware := server.NewClientCertificateMiddleware(
+		app.Server.TLSConfig != nil && app.Server.TLSConfig.ClientCAs != nil,
+	)
 
 	app.Engine.GET("/", func(c *gin.Context) {
 		c.String(http.StatusOK, "Traefik GitHub OAuth Server")
 	})
 
+	// health is the liveness check of the probes configured before it was split
 	app.Engine.GET(constant.ROUTER_PATH_OAUTH_HEALTH, healthCheck(app))
+	app.Engine.GET(constant.ROUTER_PATH_HEALTH_LIVE, healthCheck(app))
+	app.Engine.GET(constant.ROUTER_PATH_HEALTH_READY, readinessCheck(app))
+	app.Engine.GET(constant.ROUTER_PATH_JWKS, getJWKS(app))
 
 	oauthGroup := app.Engine.Group(constant.ROUTER_GROUP_PATH_OAUTH)
 	oauthGroup.POST(
 		constant.ROUTER_PATH_OAUTH_PAGE_URL,
+		clientCertificateMiddleware,
 		apiSecretKeyMiddleware,
+		apiKeyRateLimitMiddleware,
 		generateOAuthPageURL(app),
 	)
-	oauthGroup.GET(constant.ROUTER_PATH_OAUTH_REDIRECT, redirect(app))
+	oauthGroup.GET(constant.ROUTER_PATH_OAUTH_LOGIN, ipRateLimitMiddleware, loginPage(app))
+	oauthGroup.GET(constant.ROUTER_PATH_OAUTH_REDIRECT, ipRateLimitMiddleware, redirect(app))
 	oauthGroup.GET(
 		constant.ROUTER_PATH_OAUTH_RESULT,
+		clientCertificateMiddleware,
 		apiSecretKeyMiddleware,
+		apiKeyRateLimitMiddleware,
 		getAuthResult(app),
 	)
+	oauthGroup.POST(
+		constant.ROUTER_PATH_OAUTH_TOKEN,
+		clientCertificateMiddleware,
+		apiSecretKeyMiddleware,
+		apiKeyRateLimitMiddleware,
+		getAccessToken(app),
+	)
+	// the device flow is for command-line clients, they have no api secret key
+	oauthGroup.POST(constant.ROUTER_PATH_OAUTH_DEVICE_CODE, ipRateLimitMiddleware, startDeviceFlow(app))
+	oauthGroup.POST(constant.ROUTER_PATH_OAUTH_DEVICE_TOKEN, ipRateLimitMiddleware, pollDeviceToken(app))
+	// the middleware polls the revocation list with the api secret key
+	oauthGroup.GET(
+		constant.ROUTER_PATH_REVOCATIONS,
+		clientCertificateMiddleware,
+		apiSecretKeyMiddleware,
+		apiKeyRateLimitMiddleware,
+		getRevocations(app),
+	)
+
+	adminGroup := app.Engine.Group(
+		constant.ROUTER_GROUP_PATH_ADMIN,
+		adminSecretKeyMiddleware,
+		apiKeyRateLimitMiddleware,
+	)
+	adminGroup.GET(constant.ROUTER_PATH_REVOCATIONS, getRevocations(app))
+	adminGroup.POST(constant.ROUTER_PATH_REVOCATIONS, revoke(app))
+	adminGroup.DELETE(constant.ROUTER_PATH_REVOCATIONS, clearRevocations(app))
+	adminGroup.GET(constant.ROUTER_PATH_ADMIN_AUTH_REQUESTS, listAuthRequests(app))
+	adminGroup.DELETE(constant.ROUTER_PATH_ADMIN_AUTH_REQUESTS+"/:rid", deleteAuthRequest(app))
+	adminGroup.GET(constant.ROUTER_PATH_ADMIN_CONFIG, getConfig(app))
+	adminGroup.GET(constant.ROUTER_PATH_ADMIN_BUILD_INFO, getBuildInfo(app))
+	adminGroup.GET(constant.ROUTER_PATH_ADMIN_LOGINS, listLogins(app))
+	adminGroup.GET(constant.ROUTER_PATH_ADMIN_PPROF+"/*name", servePprof)
+	adminGroup.POST(constant.ROUTER_PATH_ADMIN_PPROF+"/*name", servePprof)
 }
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testApiSecretKey   = "api-secret"
+	testAdminSecretKey = "admin-secret"
+)
+
+// newTestApp an app with the routes registered, configured by the required env vars and env.
+func newTestApp(t *testing.T, env map[string]string) *server.App {
+	t.Helper()
+	for key, value := range map[string]string{
+		"API_BASE_URL":               "http://oauth.example.com",
+		"API_SECRET_KEY":             testApiSecretKey,
+		"GITHUB_OAUTH_CLIENT_ID":     "client-id",
+		"GITHUB_OAUTH_CLIENT_SECRET": "client-secret",
+	} {
+		t.Setenv(key, value)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	app, err := server.NewDefaultApp(nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	RegisterRoutes(app)
+	return app
+}
+
+// serve serves the request with the secret key in the Authorization header if any.
+func serve(app *server.App, req *http.Request, secretKey string) *httptest.ResponseRecorder {
+	if 0 < len(secretKey) {
+		req.Header.Set(constant.HTTP_HEADER_AUTHORIZATION, constant.AUTHORIZATION_PREFIX_TOKEN+" "+secretKey)
+	}
+	rw := httptest.NewRecorder()
+	app.Engine.ServeHTTP(rw, req)
+	return rw
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	server "github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/provider"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+var ErrInvalidAccessToken = errors.New("invalid sealed access token")
+
+// getAccessToken opens the access token sealed in the cookie of a user for the middleware.
+// A token expiring soon is refreshed, and sealed again for the middleware to replace the cookie.
+func getAccessToken(app *server.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		logger := app.RequestLogger(c.Request.Context())
+		setNoCacheHeaders(c)
+		sealer := app.AccessTokenSealer
+		if sealer == nil {
+			c.JSON(http.StatusNotFound, model.ResponseError{
+				Message: server.ErrAccessTokenForwardDisabled.Error(),
+			})
+			return
+		}
+		body := model.RequestAccessToken{}
+		err := c.ShouldBindJSON(&body)
+		if err != nil {
+			logger.Debug().Err(err).Msg("invalid request")
+			c.JSON(http.StatusBadRequest, model.ResponseError{
+				Message: fmt.Sprintf("invalid request: %s", err.Error()),
+			})
+			return
+		}
+
+		at, err := sealer.Open(body.SealedAccessToken)
+		if err != nil {
+			logger.Debug().Err(err).Msg("invalid sealed access token")
+			c.JSON(http.StatusBadRequest, model.ResponseError{
+				Message: ErrInvalidAccessToken.Error(),
+			})
+			return
+		}
+
+		var sealedAccessToken string
+		if sealer.NeedsRefresh(at) && 0 < len(at.RefreshToken) {
+			oAuthClient, found := app.OAuthClient(at.Client)
+			if !found {
+				logger.Warn().Str("client", at.Client).Msg("unknown client")
+				c.JSON(http.StatusBadRequest, model.ResponseError{
+					Message: fmt.Sprintf("%s: %s", ErrUnknownClient.Error(), at.Client),
+				})
+				return
+			}
+			refreshed, err := refreshAccessToken(c.Request.Context(), app, oAuthClient, at)
+			if err == nil {
+				sealedAccessToken, err = sealer.Seal(refreshed)
+			}
+			if err != nil {
+				// a token that has not expired yet is still served
+				logger.Warn().Err(err).Str("client", at.Client).Str("user_id", at.UserID).Msg("failed to refresh access token")
+			} else {
+				logger.Debug().Str("client", at.Client).Str("user_id", at.UserID).Msg("access token refreshed")
+				at = refreshed
+			}
+		}
+		if sealer.Expired(at) {
+			c.JSON(http.StatusUnauthorized, model.ResponseError{
+				Message: server.ErrAccessTokenExpired.Error(),
+			})
+			return
+		}
+
+		c.JSON(
+			http.StatusOK,
+			model.ResponseAccessToken{
+				AccessToken:       at.AccessToken,
+				TokenType:         at.TokenType,
+				UserID:            at.UserID,
+				ExpiresAt:         at.ExpiresAt,
+				SealedAccessToken: sealedAccessToken,
+			},
+		)
+	}
+}
+
+// sealAccessToken seals the token of the exchange for the middleware to forward upstream.
+func sealAccessToken(app *server.App, oAuthClient *server.OAuthClient, user *provider.User, token *oauth2.Token) (string, error) {
+	if app.AccessTokenSealer == nil {
+		return "", server.ErrAccessTokenForwardDisabled
+	}
+	return app.AccessTokenSealer.Seal(server.NewAccessToken(oAuthClient.Name, user.ID, token))
+}
+
+// refreshAccessToken exchanges the refresh token of the access token for a new one with the provider of the client.
+func refreshAccessToken(ctx context.Context, app *server.App, oAuthClient *server.OAuthClient, at *server.AccessToken) (*server.AccessToken, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ctx, span := startProviderSpan(ctx, app, oAuthClient, "oauth.refresh")
+	token, err := oAuthClient.Provider.RefreshToken(ctx, at.RefreshToken)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	return server.NewAccessToken(at.Client, at.UserID, token), nil
+}
@@ -0,0 +1,168 @@
+package traefik_github_oauth_server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwks"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/seal"
+	"github.com/rs/zerolog"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const SEAL_PURPOSE_DEVICE_CODE = "device_code"
+
+var (
+	ErrDeviceCodeExpired  = errors.New("device code expired")
+	ErrDeviceFlowDisabled = errors.New("the device flow is disabled, SESSION_SECRET_KEY is not set")
+)
+
+// DeviceCode a device flow in progress, sealed into the device code handed to the command-line client,
+// so any replica sharing the session secret can poll it.
+type DeviceCode struct {
+	// Client the name of the OAuth client the flow was started with.
+	Client string `json:"client"`
+	// DeviceCode the device code of the provider.
+	DeviceCode string `json:"device_code"`
+	ExpiresAt  int64  `json:"expires_at"`
+}
+
+// SessionIssuer issues the session tokens of the users, signed with the private key of the server.
+// The middleware verifies them with the public key published in the JWKS of the server.
+type SessionIssuer struct {
+	signingKey crypto.Signer
+	jwk        jwks.Key
+	ttl        time.Duration
+	// sealer seals the device codes, nil when the device flow is disabled.
+	sealer *seal.Sealer
+	now    func() time.Time
+}
+
+// NewSessionIssuer creates a new SessionIssuer, an empty keyID is replaced by the thumbprint of the key,
+// and an empty deviceCodeSecretKey disables the device flow.
+func NewSessionIssuer(signingKey crypto.Signer, keyID string, ttl time.Duration, deviceCodeSecretKey string) (*SessionIssuer, error) {
+	jwk, err := jwks.NewKey(signingKey.Public(), keyID)
+	if err != nil {
+		return nil, err
+	}
+	issuer := &SessionIssuer{
+		signingKey: signingKey,
+		jwk:        jwk,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+	if 0 < len(deviceCodeSecretKey) {
+		issuer.sealer, err = seal.NewSealer(deviceCodeSecretKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return issuer, nil
+}
+
+// newSessionIssuer creates the SessionIssuer of the config,
+// signing with a key generated at startup when no signing key file is configured.
+func newSessionIssuer(config *Config, logger *zerolog.Logger) (*SessionIssuer, error) {
+	var signingKey crypto.Signer
+	var err error
+	if 0 < len(config.SessionSigningKeyFile) {
+		signingKey, err = LoadSigningKey(config.SessionSigningKeyFile)
+	} else {
+		logger.Warn().Msg("SESSION_SIGNING_KEY_FILE is not set, sessions are lost on restart and cannot be shared by replicas")
+		signingKey, err = GenerateSigningKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewSessionIssuer(signingKey, config.SessionSigningKeyID, config.SessionTokenTTL, config.SessionSecretKey)
+}
+
+// LoadSigningKey loads a PEM encoded RSA, EC or Ed25519 private key.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block in %s", path)
+	}
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the private key in %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key in %s", path)
+	}
+	return signer, nil
+}
+
+// GenerateSigningKey generates an ECDSA P-256 private key, for servers without a configured signing key.
+func GenerateSigningKey() (crypto.Signer, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// TTL returns how long the session tokens are valid.
+func (i *SessionIssuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// JWKS returns the key set publishing the public key of the session tokens.
+func (i *SessionIssuer) JWKS() *jwks.Set {
+	return &jwks.Set{Keys: []jwks.Key{i.jwk}}
+}
+
+// Issue issues a session token of the user for the middlewares of the OAuth client.
+func (i *SessionIssuer) Issue(client string, user *jwt.PayloadUser) (string, error) {
+	return jwt.SignSessionTokenString(user, i.signingKey, i.jwk.Kid, client, i.ttl)
+}
+
+// DeviceFlowEnabled reports whether a device code secret is configured.
+func (i *SessionIssuer) DeviceFlowEnabled() bool {
+	return i.sealer != nil
+}
+
+// SealDeviceCode seals a device flow into the device code handed to the command-line client.
+func (i *SessionIssuer) SealDeviceCode(client, deviceCode string, expiresIn time.Duration) (string, error) {
+	if i.sealer == nil {
+		return "", ErrDeviceFlowDisabled
+	}
+	return i.sealer.Seal(SEAL_PURPOSE_DEVICE_CODE, &DeviceCode{
+		Client:     client,
+		DeviceCode: deviceCode,
+		ExpiresAt:  i.now().Add(expiresIn).Unix(),
+	})
+}
+
+// OpenDeviceCode opens a device code sealed by SealDeviceCode.
+func (i *SessionIssuer) OpenDeviceCode(sealed string) (*DeviceCode, error) {
+	if i.sealer == nil {
+		return nil, ErrDeviceFlowDisabled
+	}
+	dc := &DeviceCode{}
+	if err := i.sealer.Open(SEAL_PURPOSE_DEVICE_CODE, sealed, dc); err != nil {
+		return nil, err
+	}
+	if !i.now().Before(time.Unix(dc.ExpiresAt, 0)) {
+		return nil, ErrDeviceCodeExpired
+	}
+	return dc, nil
+}
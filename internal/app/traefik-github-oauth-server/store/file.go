@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+)
+
+// FileStore an embedded Store persisted to a single JSON file, so pending logins survive a restart.
+// The file is rewritten atomically on every change, it must not be shared between processes.
+type FileStore struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]fileEntry
+	now     func() time.Time
+}
+
+type fileEntry struct {
+	AuthRequest *model.AuthRequest `json:"auth_request"`
+	ExpiresAt   time.Time          `json:"expires_at"`
+}
+
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore opens the file store at path, creating it if it does not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	if len(path) == 0 {
+		return nil, errors.New("file store path is required")
+	}
+	s := &FileStore{
+		path:    path,
+		entries: make(map[string]fileEntry),
+		now:     time.Now,
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, s.flush()
+	case err != nil:
+		return nil, err
+	}
+	if 0 < len(data) {
+		if err := json.Unmarshal(data, &s.entries); err != nil {
+			return nil, err
+		}
+	}
+	s.deleteExpired()
+	return s, nil
+}
+
+func (s *FileStore) Insert(_ context.Context, rid string, aq *model.AuthRequest, ttl time.Duration, maxCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the abandoned logins are dropped here, so the file does not grow between the counts and lists
+	s.deleteExpired()
+	if _, found := s.entries[rid]; found {
+		return ErrExists
+	}
+	if 0 < maxCount && maxCount <= len(s.entries) {
+		return ErrFull
+	}
+	s.entries[rid] = fileEntry{AuthRequest: copyAuthRequest(aq), ExpiresAt: s.now().Add(ttl)}
+	return s.flush()
+}
+
+func (s *FileStore) Get(_ context.Context, rid string) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, found := s.get(rid)
+	if !found {
+		return nil, ErrNotFound
+	}
+	return copyAuthRequest(entry.AuthRequest), nil
+}
+
+func (s *FileStore) Update(_ context.Context, rid string, aq *model.AuthRequest, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.get(rid); !found {
+		return ErrNotFound
+	}
+	s.entries[rid] = fileEntry{AuthRequest: copyAuthRequest(aq), ExpiresAt: s.now().Add(ttl)}
+	return s.flush()
+}
+
+func (s *FileStore) Pop(_ context.Context, rid string) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, found := s.get(rid)
+	if !found {
+		return nil, ErrNotFound
+	}
+	delete(s.entries, rid)
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	return entry.AuthRequest, nil
+}
+
+func (s *FileStore) Count(_ context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteExpired()
+	return len(s.entries), nil
+}
+
+func (s *FileStore) List(_ context.Context) (map[string]*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteExpired()
+	aqs := make(map[string]*model.AuthRequest, len(s.entries))
+	for rid, entry := range s.entries {
+		aqs[rid] = copyAuthRequest(entry.AuthRequest)
+	}
+	return aqs, nil
+}
+
+// Ping checks that the directory of the file is still there, e.g. a mounted volume.
+func (s *FileStore) Ping(_ context.Context) error {
+	_, err := os.Stat(filepath.Dir(s.path))
+	return err
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteExpired()
+	return s.flush()
+}
+
+func (s *FileStore) get(rid string) (fileEntry, bool) {
+	entry, found := s.entries[rid]
+	if !found || !s.now().Before(entry.ExpiresAt) {
+		return fileEntry{}, false
+	}
+	return entry, true
+}
+
+func (s *FileStore) deleteExpired() {
+	now := s.now()
+	for rid, entry := range s.entries {
+		if !now.Before(entry.ExpiresAt) {
+			delete(s.entries, rid)
+		}
+	}
+}
+
+// flush writes the entries to a temporary file and renames it over the store file.
+func (s *FileStore) flush() error {
+	data, err := json.Marshal(s.entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/patrickmn/go-cache"
+)
+
+// MemoryStore an in-process Store backed by go-cache, the default store.
+type MemoryStore struct {
+	// mu serializes the operations, so an Update or a Get never sees an auth request that Pop is removing.
+	mu    sync.Mutex
+	cache *cache.Cache
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		cache: cache.New(cache.NoExpiration, 10*time.Minute),
+	}
+}
+
+func (s *MemoryStore) Insert(_ context.Context, rid string, aq *model.AuthRequest, ttl time.Duration, maxCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if 0 < maxCount {
+		s.cache.DeleteExpired()
+		if maxCount <= s.cache.ItemCount() {
+			return ErrFull
+		}
+	}
+	if err := s.cache.Add(rid, copyAuthRequest(aq), ttl); err != nil {
+		return ErrExists
+	}
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, rid string) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	aq, found := s.cache.Get(rid)
+	if !found {
+		return nil, ErrNotFound
+	}
+	return copyAuthRequest(aq.(*model.AuthRequest)), nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, rid string, aq *model.AuthRequest, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.cache.Replace(rid, copyAuthRequest(aq), ttl); err != nil {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MemoryStore) Pop(_ context.Context, rid string) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	aq, found := s.cache.Get(rid)
+	if !found {
+		return nil, ErrNotFound
+	}
+	s.cache.Delete(rid)
+	return aq.(*model.AuthRequest), nil
+}
+
+func (s *MemoryStore) Count(_ context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache.DeleteExpired()
+	return s.cache.ItemCount(), nil
+}
+
+func (s *MemoryStore) List(_ context.Context) (map[string]*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := s.cache.Items()
+	aqs := make(map[string]*model.AuthRequest, len(items))
+	for rid, item := range items {
+		aqs[rid] = copyAuthRequest(item.Object.(*model.AuthRequest))
+	}
+	return aqs, nil
+}
+
+func (s *MemoryStore) Ping(_ context.Context) error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
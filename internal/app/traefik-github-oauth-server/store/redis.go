@@ -0,0 +1,249 @@
+package store
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+)
+
+// DefaultRedisKeyPrefix the default prefix of the keys written by RedisStore.
+const DefaultRedisKeyPrefix = "traefik-github-oauth:"
+
+// RedisStore a Store backed by any server speaking the redis protocol (redis 6.2+, valkey, ...),
+// so pending logins can be shared by several server replicas.
+//
+// Each auth request is a key with a ttl, and a sorted set indexes the request ids by expiry to count them.
+// Insert runs as a script, so the replicas sharing the store cannot exceed the cap together.
+type RedisStore struct {
+	client    *redisClient
+	keyPrefix string
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore creates a new RedisStore from a redis://[user:password@]host:port/db url,
+// rediss:// enables TLS and unix:///path/to/socket connects to a unix socket.
+func NewRedisStore(redisURL *url.URL, keyPrefix string) (*RedisStore, error) {
+	client := &redisClient{
+		network: "tcp",
+		address: redisURL.Host,
+		pool:    make(chan *redisConn, redisPoolSize),
+	}
+	switch redisURL.Scheme {
+	case "redis":
+	case "rediss":
+		client.tlsConfig = &tls.Config{
+			ServerName: redisURL.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		}
+	case "unix":
+		client.network = "unix"
+		client.address = redisURL.Path
+	default:
+		return nil, fmt.Errorf("unsupported redis url scheme: %s", redisURL.Scheme)
+	}
+	if redisURL.Scheme != "unix" {
+		if len(redisURL.Port()) == 0 {
+			client.address = redisURL.Host + ":6379"
+		}
+		if db := strings.TrimPrefix(redisURL.Path, "/"); 0 < len(db) {
+			n, err := strconv.Atoi(db)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redis db: %s", db)
+			}
+			client.db = n
+		}
+	}
+	if redisURL.User != nil {
+		client.username = redisURL.User.Username()
+		client.password, _ = redisURL.User.Password()
+	}
+	if len(keyPrefix) == 0 {
+		keyPrefix = DefaultRedisKeyPrefix
+	}
+	return &RedisStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}, nil
+}
+
+// redisInsertScript prunes the index, checks the count against the max and inserts the auth request atomically.
+// KEYS: the auth request, the index. ARGV: the auth request, its ttl, now and its expiry in milliseconds, the rid, the max count.
+// It returns 1 once inserted, 0 if the auth request exists and -1 if the index is full.
+const redisInsertScript = `
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', ARGV[3])
+local maxCount = tonumber(ARGV[6])
+if 0 < maxCount and maxCount <= redis.call('ZCARD', KEYS[2]) then
+	return -1
+end
+if not redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 0
+end
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[5])
+return 1
+`
+
+func (s *RedisStore) Insert(ctx context.Context, rid string, aq *model.AuthRequest, ttl time.Duration, maxCount int) error {
+	data, err := json.Marshal(aq)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	reply, err := s.client.do(ctx, "EVAL", redisInsertScript, "2", s.key(rid), s.indexKey(),
+		string(data),
+		strconv.FormatInt(ttlMilliseconds(ttl), 10),
+		strconv.FormatInt(now.UnixMilli(), 10),
+		strconv.FormatInt(now.Add(ttl).UnixMilli(), 10),
+		rid,
+		strconv.Itoa(maxCount),
+	)
+	if err != nil {
+		return err
+	}
+	switch reply {
+	case int64(1):
+		return nil
+	case int64(0):
+		return ErrExists
+	case int64(-1):
+		return ErrFull
+	default:
+		return fmt.Errorf("redis: unexpected EVAL reply: %v", reply)
+	}
+}
+
+func (s *RedisStore) Get(ctx context.Context, rid string) (*model.AuthRequest, error) {
+	reply, err := s.client.do(ctx, "GET", s.key(rid))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAuthRequest(reply)
+}
+
+func (s *RedisStore) Update(ctx context.Context, rid string, aq *model.AuthRequest, ttl time.Duration) error {
+	data, err := json.Marshal(aq)
+	if err != nil {
+		return err
+	}
+	reply, err := s.client.do(ctx, "SET", s.key(rid), string(data), "XX", "PX", strconv.FormatInt(ttlMilliseconds(ttl), 10))
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return ErrNotFound
+	}
+	expiresAt := strconv.FormatInt(time.Now().Add(ttl).UnixMilli(), 10)
+	_, err = s.client.do(ctx, "ZADD", s.indexKey(), expiresAt, rid)
+	return err
+}
+
+func (s *RedisStore) Pop(ctx context.Context, rid string) (*model.AuthRequest, error) {
+	reply, err := s.client.do(ctx, "GETDEL", s.key(rid))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.client.do(ctx, "ZREM", s.indexKey(), rid); err != nil {
+		return nil, err
+	}
+	return decodeAuthRequest(reply)
+}
+
+func (s *RedisStore) Count(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if _, err := s.client.do(ctx, "ZREMRANGEBYSCORE", s.indexKey(), "-inf", now); err != nil {
+		return 0, err
+	}
+	reply, err := s.client.do(ctx, "ZCARD", s.indexKey())
+	if err != nil {
+		return 0, err
+	}
+	count, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected ZCARD reply: %v", reply)
+	}
+	return int(count), nil
+}
+
+// List reads the request ids from the index, and each auth request with its own GET,
+// the requests popped in between are skipped.
+func (s *RedisStore) List(ctx context.Context) (map[string]*model.AuthRequest, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if _, err := s.client.do(ctx, "ZREMRANGEBYSCORE", s.indexKey(), "-inf", now); err != nil {
+		return nil, err
+	}
+	reply, err := s.client.do(ctx, "ZRANGE", s.indexKey(), "0", "-1")
+	if err != nil {
+		return nil, err
+	}
+	rids, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected ZRANGE reply: %v", reply)
+	}
+	aqs := make(map[string]*model.AuthRequest, len(rids))
+	for _, r := range rids {
+		rid, _ := r.(string)
+		aq, err := s.Get(ctx, rid)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		aqs[rid] = aq
+	}
+	return aqs, nil
+}
+
+func (s *RedisStore) Ping(ctx context.Context) error {
+	reply, err := s.client.do(ctx, "PING")
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("redis: unexpected PING reply: %v", reply)
+	}
+	return nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.close()
+}
+
+// ttlMilliseconds the ttl in milliseconds, at least 1 as redis rejects an expiry of 0.
+func ttlMilliseconds(ttl time.Duration) int64 {
+	if ms := ttl.Milliseconds(); 1 <= ms {
+		return ms
+	}
+	return 1
+}
+
+func (s *RedisStore) key(rid string) string {
+	return s.keyPrefix + "auth_request:" + rid
+}
+
+func (s *RedisStore) indexKey() string {
+	return s.keyPrefix + "auth_requests"
+}
+
+func decodeAuthRequest(reply interface{}) (*model.AuthRequest, error) {
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("redis: unexpected reply: %v", reply)
+	}
+	aq := &model.AuthRequest{}
+	if err := json.Unmarshal([]byte(data), aq); err != nil {
+		return nil, err
+	}
+	return aq, nil
+}
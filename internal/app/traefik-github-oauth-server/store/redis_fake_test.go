@@ -0,0 +1,220 @@
+package store
+
+import (
+	"bufio"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis an in-process server implementing the subset of the redis protocol used by RedisStore.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	strings map[string]fakeRedisString
+	zsets   map[string]map[string]float64
+}
+
+type fakeRedisString struct {
+	value     string
+	expiresAt time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{
+		listener: listener,
+		strings:  make(map[string]fakeRedisString),
+		zsets:    make(map[string]map[string]float64),
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		reply, err := readRESPReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		f.exec(w, args)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+//nolint:cyclop,funlen
+func (f *fakeRedis) exec(w *bufio.Writer, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "SET":
+		key, value := args[1], args[2]
+		_, exists := f.get(key, now)
+		entry := fakeRedisString{value: value}
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if exists {
+					w.WriteString("$-1\r\n")
+					return
+				}
+			case "XX":
+				if !exists {
+					w.WriteString("$-1\r\n")
+					return
+				}
+			case "PX":
+				i++
+				ms, _ := strconv.ParseInt(args[i], 10, 64)
+				entry.expiresAt = now.Add(time.Duration(ms) * time.Millisecond)
+			}
+		}
+		f.strings[key] = entry
+		w.WriteString("+OK\r\n")
+	case "GET", "GETDEL":
+		value, found := f.get(args[1], now)
+		if !found {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		if strings.ToUpper(args[0]) == "GETDEL" {
+			delete(f.strings, args[1])
+		}
+		w.WriteString("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+	case "ZADD":
+		zset := f.zset(args[1])
+		score, _ := strconv.ParseFloat(args[2], 64)
+		_, exists := zset[args[3]]
+		zset[args[3]] = score
+		f.writeBool(w, !exists)
+	case "ZREM":
+		zset := f.zset(args[1])
+		_, exists := zset[args[2]]
+		delete(zset, args[2])
+		f.writeBool(w, exists)
+	case "ZREMRANGEBYSCORE":
+		zset := f.zset(args[1])
+		max, _ := strconv.ParseFloat(args[3], 64)
+		removed := 0
+		for member, score := range zset {
+			if score <= max {
+				delete(zset, member)
+				removed++
+			}
+		}
+		w.WriteString(":" + strconv.Itoa(removed) + "\r\n")
+	case "ZRANGE":
+		// the whole set only, ordered by score
+		zset := f.zset(args[1])
+		members := make([]string, 0, len(zset))
+		for member := range zset {
+			members = append(members, member)
+		}
+		sort.Slice(members, func(i, j int) bool { return zset[members[i]] < zset[members[j]] })
+		w.WriteString("*" + strconv.Itoa(len(members)) + "\r\n")
+		for _, member := range members {
+			w.WriteString("$" + strconv.Itoa(len(member)) + "\r\n" + member + "\r\n")
+		}
+	case "EVAL":
+		// the scripts of RedisStore only, emulated
+		if args[1] != redisInsertScript {
+			w.WriteString("-ERR unknown script\r\n")
+			return
+		}
+		f.evalInsert(w, args[3:5], args[5:], now)
+	case "ZCARD":
+		w.WriteString(":" + strconv.Itoa(len(f.zset(args[1]))) + "\r\n")
+	default:
+		w.WriteString("-ERR unknown command '" + args[0] + "'\r\n")
+	}
+}
+
+// evalInsert emulates redisInsertScript.
+func (f *fakeRedis) evalInsert(w *bufio.Writer, keys, argv []string, now time.Time) {
+	index := f.zset(keys[1])
+	nowMillis, _ := strconv.ParseFloat(argv[2], 64)
+	for member, score := range index {
+		if score <= nowMillis {
+			delete(index, member)
+		}
+	}
+	if maxCount, _ := strconv.Atoi(argv[5]); 0 < maxCount && maxCount <= len(index) {
+		w.WriteString(":-1\r\n")
+		return
+	}
+	if _, exists := f.get(keys[0], now); exists {
+		w.WriteString(":0\r\n")
+		return
+	}
+	ttl, _ := strconv.ParseInt(argv[1], 10, 64)
+	f.strings[keys[0]] = fakeRedisString{value: argv[0], expiresAt: now.Add(time.Duration(ttl) * time.Millisecond)}
+	expiresAt, _ := strconv.ParseFloat(argv[3], 64)
+	index[argv[4]] = expiresAt
+	w.WriteString(":1\r\n")
+}
+
+func (f *fakeRedis) get(key string, now time.Time) (string, bool) {
+	entry, found := f.strings[key]
+	if !found {
+		return "", false
+	}
+	if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+		delete(f.strings, key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+func (f *fakeRedis) zset(key string) map[string]float64 {
+	zset, found := f.zsets[key]
+	if !found {
+		zset = make(map[string]float64)
+		f.zsets[key] = zset
+	}
+	return zset
+}
+
+func (f *fakeRedis) writeBool(w *bufio.Writer, b bool) {
+	if b {
+		w.WriteString(":1\r\n")
+	} else {
+		w.WriteString(":0\r\n")
+	}
+}
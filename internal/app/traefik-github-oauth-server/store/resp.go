@@ -0,0 +1,224 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// redisError an error reply sent by the redis server.
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisClient a minimal client of the redis serialization protocol (RESP2),
+// with a small pool of connections.
+type redisClient struct {
+	network   string
+	address   string
+	username  string
+	password  string
+	db        int
+	tlsConfig *tls.Config
+
+	pool chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+const (
+	redisPoolSize    = 8
+	redisDialTimeout = 5 * time.Second
+	redisIOTimeout   = 5 * time.Second
+)
+
+// do sends a command and reads its reply.
+// Replies are decoded as string, int64, nil, []interface{} or redisError.
+func (c *redisClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisIOTimeout)
+	}
+	_ = conn.conn.SetDeadline(deadline)
+	reply, err := conn.roundTrip(args)
+	if err != nil {
+		_ = conn.conn.Close()
+		return nil, err
+	}
+	c.put(conn)
+	if replyErr, ok := reply.(redisError); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+func (c *redisClient) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+	dialer := &net.Dialer{Timeout: redisDialTimeout}
+	var netConn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: c.tlsConfig}).DialContext(ctx, c.network, c.address)
+	} else {
+		netConn, err = dialer.DialContext(ctx, c.network, c.address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: netConn, r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)}
+	_ = netConn.SetDeadline(time.Now().Add(redisIOTimeout))
+	if err := conn.handshake(c.username, c.password, c.db); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (c *redisClient) put(conn *redisConn) {
+	select {
+	case c.pool <- conn:
+	default:
+		_ = conn.conn.Close()
+	}
+}
+
+func (c *redisClient) close() error {
+	for {
+		select {
+		case conn := <-c.pool:
+			_ = conn.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (conn *redisConn) handshake(username, password string, db int) error {
+	if 0 < len(password) {
+		args := []string{"AUTH", password}
+		if 0 < len(username) {
+			args = []string{"AUTH", username, password}
+		}
+		if err := conn.expectOK(args); err != nil {
+			return fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if db != 0 {
+		if err := conn.expectOK([]string{"SELECT", strconv.Itoa(db)}); err != nil {
+			return fmt.Errorf("redis select: %w", err)
+		}
+	}
+	return nil
+}
+
+func (conn *redisConn) expectOK(args []string) error {
+	reply, err := conn.roundTrip(args)
+	if err != nil {
+		return err
+	}
+	if replyErr, ok := reply.(redisError); ok {
+		return replyErr
+	}
+	return nil
+}
+
+func (conn *redisConn) roundTrip(args []string) (interface{}, error) {
+	if err := writeRESPCommand(conn.w, args); err != nil {
+		return nil, err
+	}
+	if err := conn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readRESPReply(conn.r)
+}
+
+func writeRESPCommand(w *bufio.Writer, args []string) error {
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRESPReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil //nolint:nilnil // null bulk string
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil //nolint:nilnil // null array
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESPReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply: %q", line)
+	}
+}
+
+func readRESPLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line: %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	TYPE_MEMORY = "memory"
+	TYPE_FILE   = "file"
+	TYPE_REDIS  = "redis"
+)
+
+var (
+	ErrNotFound = errors.New("auth request not found")
+	ErrExists   = errors.New("auth request already exists")
+	ErrFull     = errors.New("too many auth requests")
+)
+
+// Store persists pending auth requests, keyed by request id.
+// Every operation is atomic, so a request can be popped by exactly one caller.
+type Store interface {
+	// Insert stores aq under rid for ttl, it fails with ErrExists if rid is already stored,
+	// and with ErrFull if maxCount unexpired auth requests are stored already, 0 means unlimited.
+	// The count and the insert are atomic, so concurrent inserts cannot exceed maxCount.
+	Insert(ctx context.Context, rid string, aq *model.AuthRequest, ttl time.Duration, maxCount int) error
+	// Get returns the auth request stored under rid, or ErrNotFound.
+	Get(ctx context.Context, rid string) (*model.AuthRequest, error)
+	// Update replaces the auth request stored under rid and resets its ttl, or returns ErrNotFound.
+	Update(ctx context.Context, rid string, aq *model.AuthRequest, ttl time.Duration) error
+	// Pop removes and returns the auth request stored under rid, or ErrNotFound.
+	Pop(ctx context.Context, rid string) (*model.AuthRequest, error)
+	// Count returns the number of unexpired auth requests.
+	Count(ctx context.Context) (int, error)
+	// List returns the unexpired auth requests by request id.
+	List(ctx context.Context) (map[string]*model.AuthRequest, error)
+	// Ping checks that the store is reachable.
+	Ping(ctx context.Context) error
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Options the store options.
+type Options struct {
+	// Type the store type, one of memory, file and redis.
+	Type string
+	// FilePath the file used by the file store.
+	FilePath string
+	// RedisURL the url used by the redis store, e.g. redis://:password@localhost:6379/0.
+	RedisURL string
+	// RedisKeyPrefix the prefix of every key written by the redis store.
+	RedisKeyPrefix string
+}
+
+// New creates the store described by opts.
+func New(opts Options) (Store, error) {
+	switch opts.Type {
+	case "", TYPE_MEMORY:
+		return NewMemoryStore(), nil
+	case TYPE_FILE:
+		return NewFileStore(opts.FilePath)
+	case TYPE_REDIS:
+		redisURL, err := url.Parse(opts.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis url: %w", err)
+		}
+		return NewRedisStore(redisURL, opts.RedisKeyPrefix)
+	default:
+		return nil, fmt.Errorf("unknown store type: %s", opts.Type)
+	}
+}
+
+func copyAuthRequest(aq *model.AuthRequest) *model.AuthRequest {
+	c := *aq
+	c.Groups = append([]string(nil), aq.Groups...)
+	return &c
+}
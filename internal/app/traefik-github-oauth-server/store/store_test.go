@@ -0,0 +1,172 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	aq := &model.AuthRequest{
+		RedirectURI: "https://example.com/",
+		AuthURL:     "https://example.com/_auth",
+	}
+
+	// Ping
+	assert.NoError(t, s.Ping(ctx))
+
+	// Insert
+	assert.NoError(t, s.Insert(ctx, "rid1", aq, time.Minute, 0))
+	assert.ErrorIs(t, s.Insert(ctx, "rid1", aq, time.Minute, 0), ErrExists)
+
+	// Get
+	got, err := s.Get(ctx, "rid1")
+	assert.NoError(t, err)
+	assert.Equal(t, aq, got)
+	_, err = s.Get(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Update
+	got.GitHubUserLogin = "testuser"
+	assert.NoError(t, s.Update(ctx, "rid1", got, time.Minute))
+	assert.ErrorIs(t, s.Update(ctx, "unknown", got, time.Minute), ErrNotFound)
+
+	// Count
+	count, err := s.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// List
+	aqs, err := s.List(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*model.AuthRequest{"rid1": got}, aqs)
+
+	// Pop
+	popped, err := s.Pop(ctx, "rid1")
+	assert.NoError(t, err)
+	assert.Equal(t, "testuser", popped.GitHubUserLogin)
+	_, err = s.Pop(ctx, "rid1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Update(ctx, "rid1", got, time.Minute), ErrNotFound)
+
+	// Pop is atomic
+	assert.NoError(t, s.Insert(ctx, "rid2", aq, time.Minute, 0))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	popCount := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Pop(ctx, "rid2"); err == nil {
+				mu.Lock()
+				popCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, popCount)
+
+	// TTL
+	assert.NoError(t, s.Insert(ctx, "rid3", aq, 10*time.Millisecond, 0))
+	time.Sleep(20 * time.Millisecond)
+	_, err = s.Get(ctx, "rid3")
+	assert.ErrorIs(t, err, ErrNotFound)
+	count, err = s.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// Insert caps the count atomically, the expired auth requests are not counted
+	assert.NoError(t, s.Insert(ctx, "rid4", aq, 10*time.Millisecond, 0))
+	time.Sleep(20 * time.Millisecond)
+	insertCount := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := s.Insert(ctx, fmt.Sprintf("capped%d", i), aq, time.Minute, 3)
+			if err == nil {
+				mu.Lock()
+				insertCount++
+				mu.Unlock()
+			} else {
+				assert.ErrorIs(t, err, ErrFull)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 3, insertCount)
+	count, err = s.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	assert.NoError(t, s.Close())
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "auth_requests.json"))
+	assert.NoError(t, err)
+	testStore(t, s)
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	// setup
+	path := filepath.Join(t.TempDir(), "auth_requests.json")
+	s, _ := NewFileStore(path)
+	_ = s.Insert(context.Background(), "rid", &model.AuthRequest{RedirectURI: "https://example.com/"}, time.Minute, 0)
+	_ = s.Close()
+
+	// execution
+	reopened, err := NewFileStore(path)
+
+	// assertion
+	assert.NoError(t, err)
+	aq, err := reopened.Get(context.Background(), "rid")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/", aq.RedirectURI)
+}
+
+func TestFileStore_Insert_PrunesExpired(t *testing.T) {
+	// setup
+	path := filepath.Join(t.TempDir(), "auth_requests.json")
+	s, _ := NewFileStore(path)
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	aq := &model.AuthRequest{RedirectURI: "https://example.com/"}
+	_ = s.Insert(context.Background(), "abandoned", aq, time.Minute, 0)
+
+	// execution
+	now = now.Add(2 * time.Minute)
+	err := s.Insert(context.Background(), "rid", aq, time.Minute, 0)
+
+	// assertion
+	assert.NoError(t, err)
+	data, _ := os.ReadFile(path)
+	var entries map[string]fileEntry
+	assert.NoError(t, json.Unmarshal(data, &entries))
+	assert.Contains(t, entries, "rid")
+	assert.NotContains(t, entries, "abandoned")
+}
+
+func TestRedisStore(t *testing.T) {
+	fake := newFakeRedis(t)
+	s, err := NewRedisStore(&url.URL{Scheme: "redis", Host: fake.addr()}, "")
+	assert.NoError(t, err)
+	testStore(t, s)
+}
@@ -0,0 +1,57 @@
+package traefik_github_oauth_server
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/tracing"
+	"github.com/rs/zerolog"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	// TRACING_EXPORTER_NONE disables tracing.
+	TRACING_EXPORTER_NONE = "none"
+	// TRACING_EXPORTER_STDOUT writes the spans to stdout as JSON lines.
+	TRACING_EXPORTER_STDOUT = "stdout"
+	// TRACING_EXPORTER_OTLP sends the spans to an OTLP/HTTP collector.
+	TRACING_EXPORTER_OTLP = "otlp"
+)
+
+// NewTracer creates the tracer of the config, nil when tracing is disabled.
+func NewTracer(config *Config, logger *zerolog.Logger) (*tracing.Tracer, error) {
+	switch config.TracingExporter {
+	case "", TRACING_EXPORTER_NONE:
+		return nil, nil
+	case TRACING_EXPORTER_STDOUT:
+		return tracing.NewTracer(config.TracingServiceName, tracing.NewStdoutExporter(os.Stdout)), nil
+	case TRACING_EXPORTER_OTLP:
+		headers, err := parseTracingHeaders(config.TracingOTLPHeaders)
+		if err != nil {
+			return nil, err
+		}
+		return tracing.NewTracer(config.TracingServiceName, tracing.NewOTLPExporter(tracing.OTLPOptions{
+			Endpoint: config.TracingOTLPEndpoint,
+			Headers:  headers,
+			OnError: func(err error) {
+				logger.Warn().Err(err).Msg("failed to export spans")
+			},
+		})), nil
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", config.TracingExporter)
+	}
+}
+
+// parseTracingHeaders parses key=value headers, like OTEL_EXPORTER_OTLP_HEADERS.
+func parseTracingHeaders(headers []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(headers))
+	for _, header := range headers {
+		k, v, found := strings.Cut(header, "=")
+		if !found || len(strings.TrimSpace(k)) == 0 {
+			return nil, fmt.Errorf("invalid tracing header %q, expected key=value", header)
+		}
+		parsed[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return parsed, nil
+}
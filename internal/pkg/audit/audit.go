@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	SOURCE_SERVER     = "server"
+	SOURCE_MIDDLEWARE = "middleware"
+
+	EVENT_LOGIN_STARTED  = "login_started"
+	EVENT_CODE_EXCHANGED = "code_exchanged"
+	EVENT_RESULT_CLAIMED = "result_claimed"
+	EVENT_AUTHORIZATION  = "authorization"
+	EVENT_DEVICE_STARTED = "device_started"
+	EVENT_SESSION_ISSUED = "session_issued"
+
+	DECISION_ALLOWED  = "allowed"
+	DECISION_DENIED   = "denied"
+	DECISION_BYPASSED = "bypassed"
+)
+
+// Event an audit event, written as a single JSON line.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`
+	Event      string    `json:"event"`
+	Decision   string    `json:"decision,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	UserID     string    `json:"user_id,omitempty"`
+	UserLogin  string    `json:"user_login,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	ClientIP   string    `json:"client_ip,omitempty"`
+	RID        string    `json:"rid,omitempty"`
+	Middleware string    `json:"middleware,omitempty"`
+}
+
+// Logger writes audit events to one or more sinks.
+// A nil *Logger is valid and discards all events.
+type Logger struct {
+	mu    sync.Mutex
+	sinks []io.Writer
+	now   func() time.Time
+}
+
+// NewLogger creates a new Logger writing to the given sinks.
+func NewLogger(sinks ...io.Writer) *Logger {
+	return &Logger{
+		sinks: sinks,
+		now:   time.Now,
+	}
+}
+
+// Log writes the event to every sink, filling in the time if it is not set.
+// Write errors are returned but never stop the event from reaching the remaining sinks.
+func (l *Logger) Log(event Event) error {
+	if l == nil || len(l.sinks) == 0 {
+		return nil
+	}
+	if event.Time.IsZero() {
+		event.Time = l.now().UTC()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var firstErr error
+	for _, sink := range l.sinks {
+		if _, err := sink.Write(line); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close closes every sink that implements io.Closer.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var firstErr error
+	for _, sink := range l.sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Options the audit sink options shared by the server and the middleware.
+type Options struct {
+	// FilePath the audit log file, empty to disable the file sink.
+	FilePath string
+	// MaxSizeMB the size in megabytes after which the file is rotated, 0 to never rotate.
+	MaxSizeMB int
+	// MaxBackups the number of rotated files to keep.
+	MaxBackups int
+	// ShareFile opens the file with OpenSharedRotatingFile, for the middleware instances of a Traefik process.
+	ShareFile bool
+	// Sinks the other sinks, e.g. the syslog sink of the server.
+	Sinks []io.Writer
+}
+
+// NewLoggerFromOptions creates a Logger with the sinks enabled in opts.
+// The Logger discards all events when no sink is enabled.
+func NewLoggerFromOptions(opts Options) (*Logger, error) {
+	var sinks []io.Writer
+	if 0 < len(opts.FilePath) {
+		open := NewRotatingFileWriter
+		if opts.ShareFile {
+			open = OpenSharedRotatingFile
+		}
+		file, err := open(opts.FilePath, int64(opts.MaxSizeMB)*1024*1024, opts.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, file)
+	}
+	sinks = append(sinks, opts.Sinks...)
+	return NewLogger(sinks...), nil
+}
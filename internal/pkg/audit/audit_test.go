@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger_Log(t *testing.T) {
+	// setup
+	var buf bytes.Buffer
+	logger := NewLogger(&buf)
+
+	// execution
+	err := logger.Log(Event{
+		Source:    SOURCE_MIDDLEWARE,
+		Event:     EVENT_AUTHORIZATION,
+		Decision:  DECISION_DENIED,
+		Reason:    "not in whitelist",
+		UserID:    "12345",
+		UserLogin: "testuser",
+	})
+
+	// assertion
+	assert.NoError(t, err)
+	var event Event
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &event))
+	assert.Equal(t, DECISION_DENIED, event.Decision)
+	assert.Equal(t, "testuser", event.UserLogin)
+	assert.False(t, event.Time.IsZero())
+	assert.Equal(t, byte('\n'), buf.Bytes()[buf.Len()-1])
+}
+
+func TestLogger_Log_Nil(t *testing.T) {
+	// setup
+	var logger *Logger
+
+	// execution
+	err := logger.Log(Event{Event: EVENT_LOGIN_STARTED})
+
+	// assertion
+	assert.NoError(t, err)
+}
+
+func TestRotatingFile_Write(t *testing.T) {
+	// setup
+	path := filepath.Join(t.TempDir(), "audit.log")
+	file, err := NewRotatingFile(path, 10, 2)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	// execution
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err = file.Write([]byte(line))
+		assert.NoError(t, err)
+	}
+
+	// assertion
+	current, _ := os.ReadFile(path)
+	backup1, _ := os.ReadFile(path + ".1")
+	backup2, _ := os.ReadFile(path + ".2")
+	assert.Equal(t, "fourth\n", string(current))
+	assert.Equal(t, "third\n", string(backup1))
+	assert.Equal(t, "second\n", string(backup2))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestRotatingFile_Write_RotationFailure(t *testing.T) {
+	// setup
+	path := filepath.Join(t.TempDir(), "audit.log")
+	file, err := NewRotatingFile(path, 10, 1)
+	assert.NoError(t, err)
+	defer file.Close()
+	// the first backup cannot be replaced by the file
+	assert.NoError(t, os.MkdirAll(filepath.Join(path+".1", "dir"), 0o700))
+
+	// execution
+	_, errFirst := file.Write([]byte("first\n"))
+	_, errSecond := file.Write([]byte("second\n"))
+
+	// assertion
+	current, _ := os.ReadFile(path)
+	assert.NoError(t, errFirst)
+	assert.Error(t, errSecond)
+	assert.Equal(t, "first\nsecond\n", string(current))
+}
+
+func TestOpenSharedRotatingFile(t *testing.T) {
+	// setup
+	path := filepath.Join(t.TempDir(), "audit.log")
+	first, err := OpenSharedRotatingFile(path, 0, 0)
+	assert.NoError(t, err)
+	second, err := OpenSharedRotatingFile(path, 0, 0)
+	assert.NoError(t, err)
+
+	// execution
+	_, errFirst := first.Write([]byte("first\n"))
+	errClose := first.Close()
+	_, errSecond := second.Write([]byte("second\n"))
+	errCloseAgain := first.Close()
+	_, errReleased := second.Write([]byte("third\n"))
+	_ = second.Close()
+	_, errClosed := second.Write([]byte("fourth\n"))
+
+	// assertion
+	assert.NoError(t, errFirst)
+	assert.NoError(t, errClose)
+	assert.NoError(t, errSecond)
+	assert.NoError(t, errCloseAgain)
+	assert.NoError(t, errReleased)
+	assert.ErrorIs(t, errClosed, os.ErrClosed)
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "first\nsecond\nthird\n", string(content))
+	assert.Empty(t, sharedFiles.files)
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile an io.WriteCloser that rotates the file once it grows beyond MaxSize bytes.
+// Rotated files are renamed to <path>.1, <path>.2, ... and at most MaxBackups of them are kept.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens (or creates) the file at path for appending.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write implements io.Writer.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if 0 < f.MaxSize && 0 < f.size && f.MaxSize < f.size+int64(len(p)) {
+		// a failed rotation does not lose the event, it is written to the current file
+		rotateErr = f.rotate()
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate renames the file to the first backup and opens a new one, the current file is only closed once it is replaced.
+// On failure the writes go on to the current file, renamed or not, until the next MaxSize bytes.
+func (f *RotatingFile) rotate() error {
+	if f.MaxBackups <= 0 {
+		if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+			f.size = 0
+			return err
+		}
+	} else {
+		_ = os.Remove(f.backupPath(f.MaxBackups))
+		for i := f.MaxBackups - 1; 0 < i; i-- {
+			if err := os.Rename(f.backupPath(i), f.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+				f.size = 0
+				return err
+			}
+		}
+		if err := os.Rename(f.Path, f.backupPath(1)); err != nil && !os.IsNotExist(err) {
+			f.size = 0
+			return err
+		}
+	}
+	current := f.file
+	if err := f.open(); err != nil {
+		f.size = 0
+		return err
+	}
+	return current.Close()
+}
+
+func (f *RotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", f.Path, i)
+}
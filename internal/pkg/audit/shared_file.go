@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+// sharedFiles the rotating files opened by OpenSharedRotatingFile, by path.
+var sharedFiles = struct {
+	mu    sync.Mutex
+	files map[string]*sharedFile
+}{files: make(map[string]*sharedFile)}
+
+type sharedFile struct {
+	file *RotatingFile
+	refs int
+}
+
+// sharedFileHandle a reference to a shared file, closing it releases the reference.
+type sharedFileHandle struct {
+	path string
+	file *RotatingFile
+	once sync.Once
+}
+
+// NewRotatingFileWriter opens a RotatingFile as an io.WriteCloser, see NewRotatingFile.
+func NewRotatingFileWriter(path string, maxSize int64, maxBackups int) (io.WriteCloser, error) {
+	return NewRotatingFile(path, maxSize, maxBackups)
+}
+
+// OpenSharedRotatingFile returns the RotatingFile of the path shared by every caller of the process,
+// so a single writer rotates it. The first caller opens it with its size and backups.
+// The file is closed once every returned writer is closed.
+func OpenSharedRotatingFile(path string, maxSize int64, maxBackups int) (io.WriteCloser, error) {
+	key := filepath.Clean(path)
+	sharedFiles.mu.Lock()
+	defer sharedFiles.mu.Unlock()
+	shared, found := sharedFiles.files[key]
+	if !found {
+		file, err := NewRotatingFile(path, maxSize, maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedFile{file: file}
+		sharedFiles.files[key] = shared
+	}
+	shared.refs++
+	return &sharedFileHandle{path: key, file: shared.file}, nil
+}
+
+// Write implements io.Writer.
+func (h *sharedFileHandle) Write(p []byte) (int, error) {
+	return h.file.Write(p)
+}
+
+// Close implements io.Closer, it closes the file when it releases the last reference.
+func (h *sharedFileHandle) Close() error {
+	var err error
+	h.once.Do(func() {
+		sharedFiles.mu.Lock()
+		defer sharedFiles.mu.Unlock()
+		shared := sharedFiles.files[h.path]
+		shared.refs--
+		if shared.refs == 0 {
+			delete(sharedFiles.files, h.path)
+			err = shared.file.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !windows && !plan9
+
+// Package syslogsink the syslog sink of the server audit log.
+// It is kept out of the audit package, the middleware imports that one and yaegi has no log/syslog.
+package syslogsink
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// New connects to the syslog daemon.
+// An empty network and address connect to the local syslog daemon.
+func New(network, address, tag string) (io.WriteCloser, error) {
+	return syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package syslogsink
+
+import (
+	"errors"
+	"io"
+)
+
+// New is not supported on this platform.
+func New(_, _, _ string) (io.WriteCloser, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -2,22 +2,56 @@ package constant
 
 //goland:noinspection GoSnakeCaseUsage
 const (
-	COOKIE_NAME_JWT = "com.github.MuXiu1997.traefik-github-oauth-plugin.jwt"
+	COOKIE_NAME_JWT          = "com.github.MuXiu1997.traefik-github-oauth-plugin.jwt"
+	COOKIE_NAME_ACCESS_TOKEN = "com.github.MuXiu1997.traefik-github-oauth-plugin.access-token"
 
 	ROUTER_PATH_OAUTH_HEALTH = "health"
+	ROUTER_PATH_HEALTH_LIVE  = "health/live"
+	ROUTER_PATH_HEALTH_READY = "health/ready"
+	ROUTER_PATH_JWKS         = ".well-known/jwks.json"
 
 	ROUTER_GROUP_PATH_OAUTH    = "oauth"
 	ROUTER_PATH_OAUTH_PAGE_URL = "page-url"
 	ROUTER_PATH_OAUTH_REDIRECT = "redirect"
 	ROUTER_PATH_OAUTH_RESULT   = "result"
+	ROUTER_PATH_OAUTH_TOKEN    = "token"
+	ROUTER_PATH_OAUTH_LOGIN    = "login"
+
+	ROUTER_PATH_OAUTH_DEVICE_CODE  = "device/code"
+	ROUTER_PATH_OAUTH_DEVICE_TOKEN = "device/token"
+
+	ROUTER_GROUP_PATH_ADMIN         = "admin"
+	ROUTER_PATH_REVOCATIONS         = "revocations"
+	ROUTER_PATH_ADMIN_AUTH_REQUESTS = "auth-requests"
+	ROUTER_PATH_ADMIN_CONFIG        = "config"
+	ROUTER_PATH_ADMIN_BUILD_INFO    = "build-info"
+	ROUTER_PATH_ADMIN_LOGINS        = "logins"
+	ROUTER_PATH_ADMIN_PPROF         = "debug/pprof"
 
 	QUERY_KEY_REDIRECT_URI = "redirect_uri"
 	QUERY_KEY_REQUEST_ID   = "rid"
+	QUERY_KEY_STATE        = "state"
+	QUERY_KEY_SCOPE        = "scope"
+	QUERY_KEY_LOGIN_HINT   = "login_hint"
+	QUERY_KEY_LOGIN        = "login"
+	QUERY_KEY_ALLOW_SIGNUP = "allow_signup"
 
 	HTTP_HEADER_AUTHORIZATION = "Authorization"
 	HTTP_HEADER_CACHE_CONTROL = "Cache-Control"
 	HTTP_HEADER_PRAGMA        = "Pragma"
 	HTTP_HEADER_EXPIRES       = "Expires"
+	HTTP_HEADER_X_REAL_IP     = "X-Real-Ip"
+	HTTP_HEADER_RETRY_AFTER   = "Retry-After"
+	HTTP_HEADER_ETAG          = "ETag"
+	HTTP_HEADER_TRACEPARENT   = "traceparent"
+	HTTP_HEADER_X_REQUEST_ID  = "X-Request-Id"
+	HTTP_HEADER_IF_NONE_MATCH = "If-None-Match"
+
+	HTTP_HEADER_CONTENT_SECURITY_POLICY = "Content-Security-Policy"
+
+	// OAUTH_CLIENT_NAME_DEFAULT the OAuth client of the middlewares that do not choose one, the audience of their session tokens.
+	OAUTH_CLIENT_NAME_DEFAULT = "default"
 
-	AUTHORIZATION_PREFIX_TOKEN = "token"
+	AUTHORIZATION_PREFIX_TOKEN  = "token"
+	AUTHORIZATION_PREFIX_BEARER = "Bearer"
 )
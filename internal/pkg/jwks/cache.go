@@ -0,0 +1,151 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// DefaultMinRefreshInterval how often at most the set is fetched again for an unknown key id or after a failed fetch.
+const DefaultMinRefreshInterval = time.Minute
+
+// DefaultFetchTimeout the timeout of a fetch of the set, unless the HTTP client has its own.
+const DefaultFetchTimeout = 10 * time.Second
+
+var ErrKeyNotFound = errors.New("signing key not found in the JWKS")
+
+// Cache fetches a JWKS and keeps its keys, the set is fetched again when a token is signed by an unknown key.
+type Cache struct {
+	URL                string
+	HTTPClient         *http.Client
+	MinRefreshInterval time.Duration
+
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+	// fetchedAt the time of the last fetch, failed or not, and err its error.
+	fetchedAt time.Time
+	err       error
+	// fetching is closed when the fetch in flight ends, nil when there is none.
+	fetching chan struct{}
+	now      func() time.Time
+}
+
+// NewCache creates a Cache of the JWKS at url, the fetches time out after DefaultFetchTimeout
+// if the HTTP client has no timeout.
+func NewCache(url string, httpClient *http.Client) *Cache {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	if httpClient.Timeout == 0 {
+		withTimeout := *httpClient
+		withTimeout.Timeout = DefaultFetchTimeout
+		httpClient = &withTimeout
+	}
+	return &Cache{
+		URL:                url,
+		HTTPClient:         httpClient,
+		MinRefreshInterval: DefaultMinRefreshInterval,
+		now:                time.Now,
+	}
+}
+
+// Key returns the public key of kid, an empty kid matches the only key of the set.
+// The concurrent lookups of unknown keys share a single fetch, made without holding the lock,
+// and the set is fetched at most every MinRefreshInterval, the error of a failed fetch is returned until then.
+func (c *Cache) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	if key, ok := c.lookup(kid); ok {
+		c.mu.Unlock()
+		return key, nil
+	}
+	fetching := c.fetching
+	if fetching == nil {
+		if !c.fetchedAt.IsZero() && c.now().Sub(c.fetchedAt) < c.MinRefreshInterval {
+			err := c.err
+			c.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrKeyNotFound
+		}
+		fetching = make(chan struct{})
+		c.fetching = fetching
+		go c.refresh(fetching)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-fetching:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, ok := c.lookup(kid); ok {
+		return key, nil
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return nil, ErrKeyNotFound
+}
+
+// refresh fetches the set and closes fetching, the fetch outlives the lookup which started it,
+// so that a cancelled request does not fail the others waiting for it.
+func (c *Cache) refresh(fetching chan struct{}) {
+	keys, err := c.fetch(context.Background())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.keys = keys
+	}
+	c.err = err
+	c.fetchedAt = c.now()
+	c.fetching = nil
+	close(fetching)
+}
+
+// Keyfunc is a jwt.Keyfunc looking up the key of the token kid header.
+func (c *Cache) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	return c.Key(context.Background(), kid)
+}
+
+func (c *Cache) lookup(kid string) (crypto.PublicKey, bool) {
+	if len(kid) == 0 && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+func (c *Cache) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+	var set Set
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+	return set.PublicKeys(), nil
+}
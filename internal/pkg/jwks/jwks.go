@@ -0,0 +1,212 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported JSON web key")
+
+// Key a public JSON Web Key, RSA, EC (P-256, P-384, P-521) and Ed25519 keys are supported.
+type Key struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// N and E the RSA modulus and exponent.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv, X and Y the curve and coordinates of EC and OKP keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// Set a JSON Web Key Set.
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// PublicKey decodes the key to a *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || 1<<31 < e.Int64() {
+			return nil, fmt.Errorf("%w: invalid RSA exponent", ErrUnsupportedKey)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("%w: point not on curve", ErrUnsupportedKey)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key size", ErrUnsupportedKey)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: key type %q", ErrUnsupportedKey, k.Kty)
+	}
+}
+
+// NewKey encodes a *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey to a signature key,
+// an empty kid is replaced by the RFC 7638 thumbprint of the key.
+func NewKey(publicKey crypto.PublicKey, kid string) (Key, error) {
+	alg, err := SigningAlgorithm(publicKey)
+	if err != nil {
+		return Key{}, err
+	}
+	k := Key{Use: "sig", Alg: alg}
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		k.Kty = "RSA"
+		k.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		k.Kty = "EC"
+		k.Crv = publicKey.Curve.Params().Name
+		k.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+		k.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		k.Kty = "OKP"
+		k.Crv = "Ed25519"
+		k.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	}
+	if len(kid) == 0 {
+		if kid, err = k.Thumbprint(); err != nil {
+			return Key{}, err
+		}
+	}
+	k.Kid = kid
+	return k, nil
+}
+
+// SigningAlgorithm returns the JWS algorithm signing with the private key of publicKey:
+// RS256 for RSA, ES256, ES384 or ES512 for EC and EdDSA for Ed25519.
+func SigningAlgorithm(publicKey crypto.PublicKey) (string, error) {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("%w: curve %q", ErrUnsupportedKey, publicKey.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "EdDSA", nil
+	default:
+		return "", fmt.Errorf("%w: key type %T", ErrUnsupportedKey, publicKey)
+	}
+}
+
+// Thumbprint returns the RFC 7638 SHA-256 thumbprint of the key, base64url encoded.
+func (k Key) Thumbprint() (string, error) {
+	// the required members only, in lexicographic order
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	default:
+		return "", fmt.Errorf("%w: key type %q", ErrUnsupportedKey, k.Kty)
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// PublicKeys decodes the signature keys of the set by kid, keys of other uses or types are skipped.
+func (s *Set) PublicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey, len(s.Keys))
+	for _, k := range s.Keys {
+		if 0 < len(k.Use) && k.Use != "sig" {
+			continue
+		}
+		publicKey, err := k.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = publicKey
+	}
+	return keys
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: empty key parameter", ErrUnsupportedKey)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,198 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeBigInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func TestKey_PublicKey(t *testing.T) {
+	// setup
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	// execution
+	rsaPublicKey, errRSA := Key{Kty: "RSA", N: encodeBigInt(rsaKey.N), E: "AQAB"}.PublicKey()
+	ecPublicKey, errEC := Key{Kty: "EC", Crv: "P-256", X: encodeBigInt(ecKey.X), Y: encodeBigInt(ecKey.Y)}.PublicKey()
+	_, errInvalid := Key{Kty: "EC", Crv: "P-256", X: encodeBigInt(ecKey.X), Y: encodeBigInt(ecKey.X)}.PublicKey()
+	_, errUnsupported := Key{Kty: "oct"}.PublicKey()
+
+	// assertion
+	assert.NoError(t, errRSA)
+	assert.True(t, rsaKey.PublicKey.Equal(rsaPublicKey))
+	assert.NoError(t, errEC)
+	assert.True(t, ecKey.PublicKey.Equal(ecPublicKey))
+	assert.ErrorIs(t, errInvalid, ErrUnsupportedKey)
+	assert.ErrorIs(t, errUnsupported, ErrUnsupportedKey)
+}
+
+func TestNewKey(t *testing.T) {
+	// setup
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPublicKey, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	// execution
+	ecJWK, errEC := NewKey(&ecKey.PublicKey, "")
+	ecPublicKey, errECPublicKey := ecJWK.PublicKey()
+	edJWK, errEd := NewKey(edPublicKey, "key-1")
+	edPublicKeyDecoded, errEdPublicKey := edJWK.PublicKey()
+
+	// assertion
+	assert.NoError(t, errEC)
+	assert.NoError(t, errECPublicKey)
+	assert.Equal(t, "ES384", ecJWK.Alg)
+	assert.Equal(t, "sig", ecJWK.Use)
+	assert.Len(t, ecJWK.Kid, 43)
+	assert.True(t, ecKey.PublicKey.Equal(ecPublicKey))
+	assert.NoError(t, errEd)
+	assert.NoError(t, errEdPublicKey)
+	assert.Equal(t, "EdDSA", edJWK.Alg)
+	assert.Equal(t, "key-1", edJWK.Kid)
+	assert.True(t, edPublicKey.Equal(edPublicKeyDecoded))
+}
+
+func TestKey_Thumbprint(t *testing.T) {
+	// setup, the example of RFC 8037 appendix A.3
+	k := Key{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+
+	// execution
+	thumbprint, err := k.Thumbprint()
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", thumbprint)
+}
+
+func TestCache_Keyfunc(t *testing.T) {
+	// setup
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_ = json.NewEncoder(w).Encode(Set{Keys: []Key{
+			{Kty: "RSA", Kid: "key-1", Use: "sig", N: encodeBigInt(rsaKey.N), E: "AQAB"},
+			{Kty: "RSA", Kid: "key-enc", Use: "enc", N: encodeBigInt(rsaKey.N), E: "AQAB"},
+		}})
+	}))
+	defer server.Close()
+	cache := NewCache(server.URL, nil)
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "1"})
+		token.Header["kid"] = kid
+		tokenString, _ := token.SignedString(rsaKey)
+		return tokenString
+	}
+
+	// execution
+	_, err := jwt.Parse(sign("key-1"), cache.Keyfunc)
+	_, errAgain := jwt.Parse(sign("key-1"), cache.Keyfunc)
+	_, errUnknown := jwt.Parse(sign("key-2"), cache.Keyfunc)
+	_, errEnc := jwt.Parse(sign("key-enc"), cache.Keyfunc)
+
+	// assertion
+	assert.NoError(t, err)
+	assert.NoError(t, errAgain)
+	assert.ErrorIs(t, errUnknown, ErrKeyNotFound)
+	assert.ErrorIs(t, errEnc, ErrKeyNotFound)
+	assert.Equal(t, 1, fetches)
+}
+
+func TestCache_Key_SingleFetch(t *testing.T) {
+	// setup
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		_ = json.NewEncoder(w).Encode(Set{Keys: []Key{
+			{Kty: "RSA", Kid: "key-1", Use: "sig", N: encodeBigInt(rsaKey.N), E: "AQAB"},
+		}})
+	}))
+	defer server.Close()
+	cache := NewCache(server.URL, nil)
+
+	// execution
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cache.Key(context.Background(), "key-1")
+		}(i)
+	}
+	// a lookup waiting for the fetch in flight returns when its request is cancelled, the fetch goes on
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, errCancelled := cache.Key(cancelled, "key-1")
+	close(release)
+	wg.Wait()
+
+	// assertion
+	assert.ErrorIs(t, errCancelled, context.Canceled)
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestCache_Key_FailedFetch(t *testing.T) {
+	// setup
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	now := time.Unix(1700000000, 0)
+	cache := NewCache(server.URL, nil)
+	cache.now = func() time.Time { return now }
+
+	// execution
+	_, err := cache.Key(context.Background(), "key-1")
+	_, errUnknown := cache.Key(context.Background(), "key-2")
+	now = now.Add(DefaultMinRefreshInterval)
+	_, errAfter := cache.Key(context.Background(), "key-1")
+
+	// assertion
+	assert.ErrorContains(t, err, "503")
+	assert.ErrorContains(t, errUnknown, "503")
+	assert.Error(t, errAfter)
+	assert.Equal(t, 2, fetches)
+}
+
+func TestNewCache_Timeout(t *testing.T) {
+	// setup
+	withTimeout := &http.Client{Timeout: time.Second}
+
+	// execution
+	cache := NewCache("http://oauth.example.com", nil)
+	cacheDefaultClient := NewCache("http://oauth.example.com", http.DefaultClient)
+	cacheWithTimeout := NewCache("http://oauth.example.com", withTimeout)
+
+	// assertion
+	assert.Equal(t, DefaultFetchTimeout, cache.HTTPClient.Timeout)
+	assert.Equal(t, DefaultFetchTimeout, cacheDefaultClient.HTTPClient.Timeout)
+	assert.Zero(t, http.DefaultClient.Timeout)
+	assert.Same(t, withTimeout, cacheWithTimeout.HTTPClient)
+}
@@ -0,0 +1,189 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	jweAlgorithmDirect   = "dir"
+	jweEncryptionA256GCM = "A256GCM"
+	// jweContentTypeJWT the content type of a nested JWT, the encrypted token is a signed token.
+	jweContentTypeJWT = "JWT"
+)
+
+var (
+	ErrInvalidEncryptedToken = errors.New("invalid encrypted token")
+	ErrUnknownEncryptionKey  = errors.New("unknown encryption key")
+)
+
+// Encrypter encrypts tokens as compact JWE with direct AES-256-GCM encryption (alg dir, enc A256GCM),
+// so the claims of a cookie cannot be read by the browser.
+// The first key encrypts and every key decrypts, a new key is rotated in by putting it first,
+// and the old one is removed once the tokens it encrypted expired.
+type Encrypter struct {
+	keys []encryptionKey
+}
+
+type encryptionKey struct {
+	kid  string
+	aead cipher.AEAD
+}
+
+type jweHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc"`
+	Kid string `json:"kid,omitempty"`
+	Cty string `json:"cty,omitempty"`
+}
+
+// NewEncrypter creates an Encrypter, the AES keys are derived from the secrets with SHA-256.
+func NewEncrypter(secrets ...string) (*Encrypter, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("an encryption secret is required")
+	}
+	e := &Encrypter{}
+	for _, secret := range secrets {
+		if len(secret) == 0 {
+			return nil, errors.New("encryption secrets must not be empty")
+		}
+		key := sha256.Sum256([]byte(secret))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		// the kid is a hash of the key, it names the key without revealing it
+		kid := sha256.Sum256(key[:])
+		e.keys = append(e.keys, encryptionKey{
+			kid:  base64.RawURLEncoding.EncodeToString(kid[:8]),
+			aead: aead,
+		})
+	}
+	return e, nil
+}
+
+// Encrypt encrypts a signed token with the first key.
+func (e *Encrypter) Encrypt(tokenString string) (string, error) {
+	key := e.keys[0]
+	header, err := json.Marshal(jweHeader{
+		Alg: jweAlgorithmDirect,
+		Enc: jweEncryptionA256GCM,
+		Kid: key.kid,
+		Cty: jweContentTypeJWT,
+	})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	iv := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := key.aead.Seal(nil, iv, []byte(tokenString), []byte(protected))
+	tagStart := len(sealed) - key.aead.Overhead()
+	return strings.Join([]string{
+		protected,
+		// direct encryption has no encrypted key
+		"",
+		base64.RawURLEncoding.EncodeToString(iv),
+		base64.RawURLEncoding.EncodeToString(sealed[:tagStart]),
+		base64.RawURLEncoding.EncodeToString(sealed[tagStart:]),
+	}, "."), nil
+}
+
+// Decrypt decrypts a token encrypted by Encrypt with any of the keys.
+func (e *Encrypter) Decrypt(encrypted string) (string, error) {
+	parts := strings.Split(encrypted, ".")
+	if len(parts) != 5 || len(parts[1]) != 0 {
+		return "", ErrInvalidEncryptedToken
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidEncryptedToken
+	}
+	var header jweHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return "", ErrInvalidEncryptedToken
+	}
+	if header.Alg != jweAlgorithmDirect || header.Enc != jweEncryptionA256GCM {
+		return "", ErrInvalidEncryptedToken
+	}
+	iv, errIV := base64.RawURLEncoding.DecodeString(parts[2])
+	ciphertext, errCiphertext := base64.RawURLEncoding.DecodeString(parts[3])
+	tag, errTag := base64.RawURLEncoding.DecodeString(parts[4])
+	if errIV != nil || errCiphertext != nil || errTag != nil {
+		return "", ErrInvalidEncryptedToken
+	}
+	for _, key := range e.keys {
+		if 0 < len(header.Kid) && header.Kid != key.kid {
+			continue
+		}
+		if len(iv) != key.aead.NonceSize() || len(tag) != key.aead.Overhead() {
+			return "", ErrInvalidEncryptedToken
+		}
+		plaintext, err := key.aead.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+		if err != nil {
+			return "", ErrInvalidEncryptedToken
+		}
+		return string(plaintext), nil
+	}
+	return "", ErrUnknownEncryptionKey
+}
+
+// IsEncryptedToken reports whether the token is a compact JWE, a signed token has 3 parts instead of 5.
+func IsEncryptedToken(tokenString string) bool {
+	return strings.Count(tokenString, ".") == 4
+}
+
+// TokenOption an option of the generation and the parsing of tokens.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	encrypter *Encrypter
+	audience  string
+}
+
+// WithEncrypter encrypts the generated tokens and decrypts the parsed ones, a nil encrypter does neither.
+// The parsed tokens that are not encrypted are still accepted, e.g. the cookies set before the encryption was enabled.
+func WithEncrypter(encrypter *Encrypter) TokenOption {
+	return func(opts *tokenOptions) {
+		opts.encrypter = encrypter
+	}
+}
+
+func newTokenOptions(opts []TokenOption) *tokenOptions {
+	options := &tokenOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// encrypt encrypts the signed token if an encrypter is set.
+func (o *tokenOptions) encrypt(tokenString string) (string, error) {
+	if o.encrypter == nil {
+		return tokenString, nil
+	}
+	return o.encrypter.Encrypt(tokenString)
+}
+
+// decrypt decrypts the token if it is encrypted, an encrypted token requires an encrypter.
+func (o *tokenOptions) decrypt(tokenString string) (string, error) {
+	if !IsEncryptedToken(tokenString) {
+		return tokenString, nil
+	}
+	if o.encrypter == nil {
+		return "", ErrUnknownEncryptionKey
+	}
+	return o.encrypter.Decrypt(tokenString)
+}
@@ -0,0 +1,95 @@
+package jwt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTokenString_Encrypted(t *testing.T) {
+	// setup
+	encrypter, _ := NewEncrypter("encryptionKey")
+	tokenString, err := GenerateJwtTokenString(id, login, key, WithEncrypter(encrypter))
+	assert.NoError(t, err)
+
+	// execution
+	payload, err := ParseTokenString(tokenString, key, WithEncrypter(encrypter))
+
+	// assertion
+	assert.NoError(t, err)
+	assert.True(t, IsEncryptedToken(tokenString))
+	assert.NotContains(t, tokenString, login)
+	assert.Equal(t, id, payload.Id)
+	assert.Equal(t, login, payload.Login)
+}
+
+func TestParseTokenString_EncryptedWithoutEncrypter(t *testing.T) {
+	// setup
+	encrypter, _ := NewEncrypter("encryptionKey")
+	tokenString, _ := GenerateJwtTokenString(id, login, key, WithEncrypter(encrypter))
+
+	// execution
+	payload, err := ParseTokenString(tokenString, key)
+
+	// assertion
+	assert.ErrorIs(t, err, ErrUnknownEncryptionKey)
+	assert.Nil(t, payload)
+}
+
+func TestParseTokenString_NotEncrypted(t *testing.T) {
+	// setup
+	encrypter, _ := NewEncrypter("encryptionKey")
+	tokenString, _ := GenerateJwtTokenString(id, login, key)
+
+	// execution
+	payload, err := ParseTokenString(tokenString, key, WithEncrypter(encrypter))
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, id, payload.Id)
+}
+
+func TestEncrypter_KeyRotation(t *testing.T) {
+	// setup
+	oldEncrypter, _ := NewEncrypter("oldKey")
+	encrypted, _ := oldEncrypter.Encrypt("token")
+	rotatedEncrypter, _ := NewEncrypter("newKey", "oldKey")
+	newEncrypter, _ := NewEncrypter("newKey")
+
+	// execution
+	decrypted, err := rotatedEncrypter.Decrypt(encrypted)
+	_, errRemoved := newEncrypter.Decrypt(encrypted)
+	reencrypted, _ := rotatedEncrypter.Encrypt("token")
+	_, errOld := oldEncrypter.Decrypt(reencrypted)
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, "token", decrypted)
+	assert.ErrorIs(t, errRemoved, ErrUnknownEncryptionKey)
+	assert.ErrorIs(t, errOld, ErrUnknownEncryptionKey)
+}
+
+func TestEncrypter_Decrypt_Tampered(t *testing.T) {
+	// setup
+	encrypter, _ := NewEncrypter("encryptionKey")
+	encrypted, _ := encrypter.Encrypt("token")
+	parts := strings.Split(encrypted, ".")
+	parts[3] = "AAAA" + parts[3][4:]
+
+	// execution
+	_, err := encrypter.Decrypt(strings.Join(parts, "."))
+
+	// assertion
+	assert.ErrorIs(t, err, ErrInvalidEncryptedToken)
+}
+
+func TestNewEncrypter_EmptySecret(t *testing.T) {
+	// execution
+	_, errNone := NewEncrypter()
+	_, errEmpty := NewEncrypter("")
+
+	// assertion
+	assert.Error(t, errNone)
+	assert.Error(t, errEmpty)
+}
@@ -1,40 +1,178 @@
 package jwt
 
 import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"time"
 
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwks"
 	"github.com/golang-jwt/jwt/v4"
 )
 
+// SessionTokenIssuer the iss of the session tokens signed by the server.
+const SessionTokenIssuer = "traefik-github-oauth-server"
+
+// sessionSigningMethods the algorithms of the session tokens, HS256 for the tokens signed by the middleware itself.
+var sessionSigningMethods = []string{"HS256", "RS256", "ES256", "ES384", "ES512", "EdDSA"}
+
+// KeySource looks up the public keys verifying the session tokens signed by the server, e.g. a *jwks.Cache.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
 type PayloadUser struct {
 	Id    string `json:"id"`
 	Login string `json:"login"`
+	// Provider the identity provider of the user, empty for tokens issued before providers were introduced.
+	Provider string   `json:"provider,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+	// Roles the application roles of the user, computed by the middleware from its own role mapping, never a claim.
+	Roles []string `json:"-"`
+	// TokenID and IssuedAt the jti and iat of the token, read from parsed tokens for revocation.
+	TokenID  string    `json:"-"`
+	IssuedAt time.Time `json:"-"`
+	// ExpiresAt the exp of a parsed token, zero for the tokens signed by the middleware, which do not expire.
+	ExpiresAt time.Time `json:"-"`
 }
 
-func GenerateJwtTokenString(id, login, key string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":    id,
-		"login": login,
-	})
-	return token.SignedString([]byte(key))
+func GenerateJwtTokenString(id, login, key string, opts ...TokenOption) (string, error) {
+	return GenerateUserJwtTokenString(&PayloadUser{Id: id, Login: login}, key, opts...)
+}
+
+// GenerateUserJwtTokenString generates a token of the user, including its provider and groups.
+func GenerateUserJwtTokenString(user *PayloadUser, key string, opts ...TokenOption) (string, error) {
+	claims, err := userClaims(user, time.Now())
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(key))
+	if err != nil {
+		return "", err
+	}
+	return newTokenOptions(opts).encrypt(tokenString)
 }
 
-func ParseTokenString(tokenString, key string) (*PayloadUser, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+// SignSessionTokenString signs a token of the user expiring after ttl with the private key of the server,
+// kid names its public key in the JWKS of the server, and audience the OAuth client the user logged in with.
+func SignSessionTokenString(user *PayloadUser, signingKey crypto.Signer, kid, audience string, ttl time.Duration) (string, error) {
+	alg, err := jwks.SigningAlgorithm(signingKey.Public())
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims, err := userClaims(user, now)
+	if err != nil {
+		return "", err
+	}
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["iss"] = SessionTokenIssuer
+	claims["aud"] = audience
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	token.Header["kid"] = kid
+	return token.SignedString(signingKey)
+}
+
+// userClaims returns the claims of the user, with a random jti to revoke the token by.
+func userClaims(user *PayloadUser, now time.Time) (jwt.MapClaims, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{
+		"id":    user.Id,
+		"login": user.Login,
+		"jti":   hex.EncodeToString(jti),
+		"iat":   now.Unix(),
+	}
+	if 0 < len(user.Provider) {
+		claims["provider"] = user.Provider
+	}
+	if 0 < len(user.Groups) {
+		claims["groups"] = user.Groups
+	}
+	return claims, nil
+}
+
+// WithAudience requires the audience in the tokens signed by the server, the name of the OAuth client of the middleware.
+// The tokens signed with the secret key have no audience.
+func WithAudience(audience string) TokenOption {
+	return func(opts *tokenOptions) {
+		opts.audience = audience
+	}
+}
+
+func ParseTokenString(tokenString, key string, opts ...TokenOption) (*PayloadUser, error) {
+	return ParseTokenStringWithKeys(context.Background(), tokenString, key, nil, opts...)
+}
+
+// ParseTokenStringWithKeys parses a token signed with the secret key, or by the server with a key of keys,
+// a nil keys only accepts the tokens signed with the secret key. An encrypted token requires the WithEncrypter option.
+// A token signed by the server must have its iss, and the audience of the WithAudience option.
+func ParseTokenStringWithKeys(ctx context.Context, tokenString, key string, keys KeySource, opts ...TokenOption) (*PayloadUser, error) {
+	options := newTokenOptions(opts)
+	tokenString, err := options.decrypt(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	parser := jwt.NewParser(jwt.WithValidMethods(sessionSigningMethods))
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			if len(key) == 0 {
+				return nil, fmt.Errorf("no secret key verifying the %v tokens", token.Header["alg"])
+			}
+			return []byte(key), nil
+		}
+		if keys == nil {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(key), nil
+		kid, _ := token.Header["kid"].(string)
+		return keys.Key(ctx, kid)
 	})
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return &PayloadUser{
-			Id:    claims["id"].(string),
-			Login: claims["login"].(string),
-		}, nil
-	} else {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token")
 	}
+	if _, signedBySecret := token.Method.(*jwt.SigningMethodHMAC); !signedBySecret {
+		if !claims.VerifyIssuer(SessionTokenIssuer, true) {
+			return nil, fmt.Errorf("invalid token issuer")
+		}
+		if 0 < len(options.audience) && !claims.VerifyAudience(options.audience, true) {
+			return nil, fmt.Errorf("invalid token audience")
+		}
+	}
+	user := &PayloadUser{}
+	user.Id, _ = claims["id"].(string)
+	user.Login, _ = claims["login"].(string)
+	if len(user.Id) == 0 {
+		return nil, fmt.Errorf("invalid token: no user id")
+	}
+	user.Provider, _ = claims["provider"].(string)
+	user.TokenID, _ = claims["jti"].(string)
+	if iat, ok := claims["iat"].(float64); ok {
+		user.IssuedAt = time.Unix(int64(iat), 0)
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		user.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	user.Groups = stringsClaim(claims, "groups")
+	return user, nil
+}
+
+// stringsClaim returns the strings of a list claim, nil if it is missing.
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	values, _ := claims[name].([]interface{})
+	var strs []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
 }
@@ -1,8 +1,16 @@
 package jwt
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,3 +66,100 @@ func TestParseTokenString_InvalidKey(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, payload)
 }
+
+func TestParseTokenString_ProviderAndGroups(t *testing.T) {
+	// setup
+	tokenString, _ := GenerateUserJwtTokenString(&PayloadUser{
+		Id:       id,
+		Login:    login,
+		Provider: "gitea",
+		Groups:   []string{"acme", "acme/owners"},
+	}, key)
+
+	// execution
+	payload, err := ParseTokenString(tokenString, key)
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, "gitea", payload.Provider)
+	assert.Equal(t, []string{"acme", "acme/owners"}, payload.Groups)
+}
+
+type staticKeySource map[string]crypto.PublicKey
+
+func (s staticKeySource) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if publicKey, ok := s[kid]; ok {
+		return publicKey, nil
+	}
+	return nil, errors.New("key not found")
+}
+
+func TestSignSessionTokenString(t *testing.T) {
+	// setup
+	signingKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys := staticKeySource{"key-1": signingKey.Public()}
+	user := &PayloadUser{Id: id, Login: login}
+	tokenString, _ := SignSessionTokenString(user, signingKey, "key-1", "default", time.Hour)
+	expiredTokenString, _ := SignSessionTokenString(user, signingKey, "key-1", "default", -time.Minute)
+	unknownKeyTokenString, _ := SignSessionTokenString(user, signingKey, "key-2", "default", time.Hour)
+
+	// execution
+	payload, err := ParseTokenStringWithKeys(context.Background(), tokenString, key, keys, WithAudience("default"))
+	_, errExpired := ParseTokenStringWithKeys(context.Background(), expiredTokenString, key, keys)
+	_, errUnknownKey := ParseTokenStringWithKeys(context.Background(), unknownKeyTokenString, key, keys)
+	_, errNoKeys := ParseTokenString(tokenString, key)
+	_, errAudience := ParseTokenStringWithKeys(context.Background(), tokenString, key, keys, WithAudience("sales"))
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, login, payload.Login)
+	assert.Len(t, payload.TokenID, 32)
+	assert.WithinDuration(t, time.Now(), payload.IssuedAt, time.Minute)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), payload.ExpiresAt, time.Minute)
+	assert.Error(t, errExpired)
+	assert.Error(t, errUnknownKey)
+	assert.Error(t, errNoKeys)
+	assert.Error(t, errAudience)
+}
+
+func TestParseTokenStringWithKeys_Issuer(t *testing.T) {
+	// setup
+	signingKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	keys := staticKeySource{"key-1": signingKey.Public()}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"id": id, "login": login, "aud": "default"})
+	token.Header["kid"] = "key-1"
+	tokenString, _ := token.SignedString(signingKey)
+
+	// execution
+	payload, err := ParseTokenStringWithKeys(context.Background(), tokenString, key, keys, WithAudience("default"))
+
+	// assertion
+	assert.Error(t, err)
+	assert.Nil(t, payload)
+}
+
+func TestParseTokenString_NoId(t *testing.T) {
+	// setup
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"login": login})
+	tokenString, _ := token.SignedString([]byte(key))
+
+	// execution
+	payload, err := ParseTokenString(tokenString, key)
+
+	// assertion
+	assert.Error(t, err)
+	assert.Nil(t, payload)
+}
+
+func TestParseTokenString_EmptyKey(t *testing.T) {
+	// setup
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": id, "login": login})
+	tokenString, _ := token.SignedString([]byte(""))
+
+	// execution
+	payload, err := ParseTokenString(tokenString, "")
+
+	// assertion
+	assert.ErrorContains(t, err, "no secret key")
+	assert.Nil(t, payload)
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	// setup
+	now := time.Unix(0, 0)
+	limiter := NewLimiter(1, 2)
+	limiter.now = func() time.Time { return now }
+
+	// execution & assertion
+	allowed, _ := limiter.Allow("a")
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow("a")
+	assert.True(t, allowed)
+	allowed, retryAfter := limiter.Allow("a")
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, retryAfter)
+
+	allowed, _ = limiter.Allow("b")
+	assert.True(t, allowed)
+
+	now = now.Add(time.Second)
+	allowed, _ = limiter.Allow("a")
+	assert.True(t, allowed)
+}
+
+func TestLimiter_Allow_Disabled(t *testing.T) {
+	// setup
+	limiter := NewLimiter(0, 0)
+
+	// execution & assertion
+	for i := 0; i < 100; i++ {
+		allowed, _ := limiter.Allow("a")
+		assert.True(t, allowed)
+	}
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	// setup
+	now := time.Unix(0, 0)
+	limiter := NewLimiter(1, 1)
+	limiter.now = func() time.Time { return now }
+	limiter.Allow("a")
+
+	// execution
+	now = now.Add(2 * sweepInterval)
+	limiter.Allow("b")
+
+	// assertion
+	assert.NotContains(t, limiter.buckets, "a")
+	assert.Contains(t, limiter.buckets, "b")
+}
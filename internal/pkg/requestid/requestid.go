@@ -0,0 +1,38 @@
+package requestid
+
+import (
+	"github.com/rs/xid"
+)
+
+// MaxLength the longest request id accepted from a client.
+const MaxLength = 128
+
+// New generates a request id.
+func New() string {
+	return xid.New().String()
+}
+
+// Valid reports whether a request id received from a client is safe to log and echo:
+// not empty, at most MaxLength long, and made of letters, digits and . _ : - only.
+func Valid(id string) bool {
+	if len(id) == 0 || MaxLength < len(id) {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '.', r == '_', r == ':', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// FromHeader returns the request id of the header value if it is valid, or a new one.
+func FromHeader(value string) string {
+	if Valid(value) {
+		return value
+	}
+	return New()
+}
@@ -0,0 +1,27 @@
+package requestid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	// execution & assertion
+	assert.True(t, Valid(New()))
+	assert.True(t, Valid("f8e3b8c2-6f2a-4c1e-9d3b-2b6c5f1e7a90"))
+	assert.True(t, Valid("trace:1.2_3"))
+	assert.False(t, Valid(""))
+	assert.False(t, Valid(strings.Repeat("a", MaxLength+1)))
+	assert.False(t, Valid("id\nforged log line"))
+	assert.False(t, Valid("<script>"))
+}
+
+func TestFromHeader(t *testing.T) {
+	// execution & assertion
+	assert.Equal(t, "abc-123", FromHeader("abc-123"))
+	generated := FromHeader("bad id")
+	assert.NotEqual(t, "bad id", generated)
+	assert.True(t, Valid(generated))
+}
@@ -0,0 +1,67 @@
+package revocation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// List the revoked sessions, published by the server and polled by the middleware.
+// Times are unix seconds, a session issued at or after a revocation time is not revoked by it.
+type List struct {
+	// NotBefore revokes every session issued before it.
+	NotBefore int64 `json:"not_before,omitempty"`
+	// Users revokes the sessions of a user issued before the time, keyed by UserKey.
+	Users map[string]int64 `json:"users,omitempty"`
+	// Tokens revokes single sessions by jti, the time is the expiry of the session, after which the entry is dropped.
+	Tokens map[string]int64 `json:"tokens,omitempty"`
+}
+
+// UserKey the key of a user in List.Users.
+func UserKey(provider, userID string) string {
+	return provider + ":" + userID
+}
+
+// IsRevoked reports whether the session is revoked, a zero issuedAt is older than any revocation.
+func (l *List) IsRevoked(provider, userID, jti string, issuedAt time.Time) bool {
+	if l == nil {
+		return false
+	}
+	iat := int64(0)
+	if !issuedAt.IsZero() {
+		iat = issuedAt.Unix()
+	}
+	if iat < l.NotBefore {
+		return true
+	}
+	if before, ok := l.Users[UserKey(provider, userID)]; ok && iat < before {
+		return true
+	}
+	if 0 < len(jti) {
+		if _, ok := l.Tokens[jti]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune drops the revoked tokens expired at now, they are refused anyway.
+func (l *List) Prune(now time.Time) {
+	for jti, expiresAt := range l.Tokens {
+		if expiresAt <= now.Unix() {
+			delete(l.Tokens, jti)
+		}
+	}
+}
+
+// ETag returns a strong entity tag of the list, it changes whenever the list does.
+func (l *List) ETag() (string, error) {
+	// maps are encoded with sorted keys, so equal lists have equal tags
+	b, err := json.Marshal(l)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
@@ -0,0 +1,60 @@
+package revocation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList_IsRevoked(t *testing.T) {
+	// setup
+	now := time.Unix(1700000000, 0)
+	l := &List{
+		NotBefore: now.Add(-time.Hour).Unix(),
+		Users:     map[string]int64{UserKey("github", "1"): now.Unix()},
+		Tokens:    map[string]int64{"jti-1": now.Add(time.Hour).Unix()},
+	}
+
+	// execution & assertion
+	assert.True(t, l.IsRevoked("github", "2", "", now.Add(-2*time.Hour)))
+	assert.True(t, l.IsRevoked("github", "2", "", time.Time{}))
+	assert.False(t, l.IsRevoked("github", "2", "", now.Add(-time.Minute)))
+	assert.True(t, l.IsRevoked("github", "1", "", now.Add(-time.Minute)))
+	assert.False(t, l.IsRevoked("gitea", "1", "", now.Add(-time.Minute)))
+	assert.False(t, l.IsRevoked("github", "1", "", now))
+	assert.True(t, l.IsRevoked("github", "2", "jti-1", now))
+	assert.False(t, (*List)(nil).IsRevoked("github", "1", "jti-1", time.Time{}))
+}
+
+func TestList_Prune(t *testing.T) {
+	// setup
+	now := time.Unix(1700000000, 0)
+	l := &List{Tokens: map[string]int64{
+		"expired": now.Unix(),
+		"valid":   now.Add(time.Minute).Unix(),
+	}}
+
+	// execution
+	l.Prune(now)
+
+	// assertion
+	assert.Equal(t, map[string]int64{"valid": now.Add(time.Minute).Unix()}, l.Tokens)
+}
+
+func TestList_ETag(t *testing.T) {
+	// setup
+	l := &List{Users: map[string]int64{"github:1": 1, "github:2": 2}}
+	same := &List{Users: map[string]int64{"github:2": 2, "github:1": 1}}
+	other := &List{Users: map[string]int64{"github:1": 1}}
+
+	// execution
+	etag, err := l.ETag()
+	sameETag, _ := same.ETag()
+	otherETag, _ := other.ETag()
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, etag, sameETag)
+	assert.NotEqual(t, etag, otherETag)
+}
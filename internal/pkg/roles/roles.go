@@ -0,0 +1,80 @@
+package roles
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultProvider the provider of the entries without a provider prefix.
+const DefaultProvider = "github"
+
+// Members the users, teams and orgs granted a role.
+// An entry prefixed with a provider, e.g. gitea:alice, only matches the users of that provider,
+// an entry without a prefix only the ones of DefaultProvider, so a login of another provider cannot take a GitHub role.
+type Members struct {
+	// Users the user ids or logins.
+	Users []string `json:"users,omitempty"`
+	// Teams the org/team slugs.
+	Teams []string `json:"teams,omitempty"`
+	// Orgs the org logins, every member of the org has the role.
+	Orgs []string `json:"orgs,omitempty"`
+}
+
+// Mapping the members of each role, by role name, e.g. admin, editor or viewer.
+type Mapping map[string]Members
+
+// Roles returns the sorted roles of the user of the provider, granted by its id or login, or by one of its groups,
+// the orgs and org/team slugs of the provider. An empty provider is DefaultProvider.
+func (m Mapping) Roles(provider, id, login string, groups []string) []string {
+	if len(provider) == 0 {
+		provider = DefaultProvider
+	}
+	var roles []string
+	for role, members := range m {
+		if members.grants(provider, id, login, groups) {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func (m Members) grants(provider, id, login string, groups []string) bool {
+	for _, user := range m.Users {
+		if matches(user, provider, id) || matches(user, provider, login) {
+			return true
+		}
+	}
+	for _, group := range groups {
+		entries := m.Orgs
+		if strings.Contains(group, "/") {
+			entries = m.Teams
+		}
+		for _, entry := range entries {
+			if matches(entry, provider, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matches reports whether the entry names the value of the provider.
+func matches(entry, provider, value string) bool {
+	if entry == provider+":"+value {
+		return true
+	}
+	return provider == DefaultProvider && entry == value
+}
+
+// HasAny reports whether the roles include one of the required roles.
+func HasAny(roles, required []string) bool {
+	for _, role := range roles {
+		for _, r := range required {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package roles
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapping_Roles(t *testing.T) {
+	// setup
+	mapping := Mapping{
+		"admin":  {Users: []string{"12345"}, Teams: []string{"acme/sre"}},
+		"editor": {Teams: []string{"acme/dev"}},
+		"viewer": {Orgs: []string{"acme"}},
+	}
+
+	// execution & assertion
+	assert.Equal(t, []string{"admin", "viewer"}, mapping.Roles("github", "12345", "alice", []string{"acme"}))
+	assert.Equal(t, []string{"editor", "viewer"}, mapping.Roles("", "2", "bob", []string{"acme", "acme/dev"}))
+	assert.Equal(t, []string{"admin"}, mapping.Roles("github", "3", "carol", []string{"acme/sre"}))
+	// an org/team slug does not match an org, nor an org a team
+	assert.Empty(t, mapping.Roles("github", "4", "dave", []string{"acme/viewers", "sre"}))
+	assert.Empty(t, Mapping(nil).Roles("github", "12345", "alice", []string{"acme"}))
+}
+
+func TestMapping_Roles_Provider(t *testing.T) {
+	// setup
+	mapping := Mapping{
+		"admin":  {Users: []string{"alice", "gitea:bob"}},
+		"editor": {Teams: []string{"gitea:acme/dev"}},
+		"viewer": {Orgs: []string{"acme"}},
+	}
+
+	// execution & assertion
+	assert.Equal(t, []string{"admin", "viewer"}, mapping.Roles("github", "1", "alice", []string{"acme"}))
+	// the entries without a prefix are the ones of GitHub
+	assert.Empty(t, mapping.Roles("gitea", "1", "alice", []string{"acme"}))
+	assert.Equal(t, []string{"admin", "editor"}, mapping.Roles("gitea", "2", "bob", []string{"acme/dev"}))
+	assert.Empty(t, mapping.Roles("github", "2", "bob", []string{"acme/dev"}))
+}
+
+func TestHasAny(t *testing.T) {
+	// execution & assertion
+	assert.True(t, HasAny([]string{"editor", "viewer"}, []string{"admin", "editor"}))
+	assert.False(t, HasAny([]string{"viewer"}, []string{"admin", "editor"}))
+	assert.False(t, HasAny(nil, []string{"admin"}))
+}
@@ -0,0 +1,65 @@
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidSealed = errors.New("invalid sealed value")
+
+// Sealer seals values into URL-safe strings with AES-256-GCM, so they can only be read and
+// produced by holders of the secret.
+type Sealer struct {
+	aead cipher.AEAD
+}
+
+// NewSealer creates a new Sealer, the AES key is derived from secret with SHA-256.
+func NewSealer(secret string) (*Sealer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("seal secret is required")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{aead: aead}, nil
+}
+
+// Seal encodes v as JSON and encrypts it.
+// purpose is authenticated but not encrypted, a value sealed for one purpose cannot be opened for another.
+func (s *Sealer) Seal(purpose string, v interface{}) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, s.aead.NonceSize(), s.aead.NonceSize()+len(plaintext)+s.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, plaintext, []byte(purpose))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed for purpose and decodes it into v.
+func (s *Sealer) Open(purpose, sealed string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < s.aead.NonceSize() {
+		return ErrInvalidSealed
+	}
+	nonce, ciphertext := data[:s.aead.NonceSize()], data[s.aead.NonceSize():]
+	plaintext, err := s.aead.Open(nil, nonce, ciphertext, []byte(purpose))
+	if err != nil {
+		return ErrInvalidSealed
+	}
+	return json.Unmarshal(plaintext, v)
+}
@@ -0,0 +1,63 @@
+package seal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type payload struct {
+	Value string `json:"value"`
+}
+
+func TestSealer_Open(t *testing.T) {
+	// setup
+	sealer, _ := NewSealer("secretKey")
+	sealed, err := sealer.Seal("state", payload{Value: "hello"})
+	assert.NoError(t, err)
+
+	// execution
+	var opened payload
+	err = sealer.Open("state", sealed, &opened)
+
+	// assertion
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", opened.Value)
+	assert.NotContains(t, sealed, "hello")
+}
+
+func TestSealer_Open_InvalidPurpose(t *testing.T) {
+	// setup
+	sealer, _ := NewSealer("secretKey")
+	sealed, _ := sealer.Seal("state", payload{Value: "hello"})
+
+	// execution
+	err := sealer.Open("result", sealed, &payload{})
+
+	// assertion
+	assert.ErrorIs(t, err, ErrInvalidSealed)
+}
+
+func TestSealer_Open_InvalidKey(t *testing.T) {
+	// setup
+	sealer, _ := NewSealer("secretKey")
+	otherSealer, _ := NewSealer("invalidKey")
+	sealed, _ := sealer.Seal("state", payload{Value: "hello"})
+
+	// execution
+	err := otherSealer.Open("state", sealed, &payload{})
+
+	// assertion
+	assert.ErrorIs(t, err, ErrInvalidSealed)
+}
+
+func TestSealer_Open_Tampered(t *testing.T) {
+	// setup
+	sealer, _ := NewSealer("secretKey")
+
+	// execution
+	err := sealer.Open("state", "invalidsealed", &payload{})
+
+	// assertion
+	assert.ErrorIs(t, err, ErrInvalidSealed)
+}
@@ -0,0 +1,268 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultOTLPBatchSize     = 256
+	DefaultOTLPFlushInterval = 5 * time.Second
+	// otlpQueueSize the spans waiting for the next batch, the spans ended while it is full are dropped.
+	otlpQueueSize = 4096
+	// otlpTracesPath the path of the OTLP/HTTP traces endpoint, appended to an endpoint without path.
+	otlpTracesPath = "/v1/traces"
+)
+
+// StdoutExporter writes every span as a JSON line, e.g. to stdout.
+type StdoutExporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+var _ Exporter = (*StdoutExporter)(nil)
+
+// NewStdoutExporter creates a StdoutExporter writing to w.
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{encoder: json.NewEncoder(w)}
+}
+
+func (e *StdoutExporter) Export(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_ = e.encoder.Encode(span)
+}
+
+func (e *StdoutExporter) Close() error {
+	return nil
+}
+
+// OTLPOptions the options of the OTLP exporter.
+type OTLPOptions struct {
+	// Endpoint the OTLP/HTTP traces endpoint, /v1/traces is appended to an url without path.
+	Endpoint string
+	// Headers the headers sent with every export, e.g. the API key of a hosted collector.
+	Headers map[string]string
+	// HTTPClient the client of the exports, http.DefaultClient if nil.
+	HTTPClient *http.Client
+	// BatchSize the spans sent per export, DefaultOTLPBatchSize if zero.
+	BatchSize int
+	// FlushInterval how long a span waits for a full batch, DefaultOTLPFlushInterval if zero.
+	FlushInterval time.Duration
+	// OnError is called with the errors of the exports, they are ignored if nil.
+	OnError func(err error)
+}
+
+// OTLPExporter sends the spans in batches to an OTLP/HTTP collector, encoded as JSON.
+type OTLPExporter struct {
+	opts  OTLPOptions
+	spans chan *Span
+	done  chan struct{}
+}
+
+var _ Exporter = (*OTLPExporter)(nil)
+
+// NewOTLPExporter creates an OTLPExporter and starts sending its batches.
+func NewOTLPExporter(opts OTLPOptions) *OTLPExporter {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultOTLPBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultOTLPFlushInterval
+	}
+	if i := strings.Index(opts.Endpoint, "://"); i < 0 || !strings.Contains(opts.Endpoint[i+3:], "/") {
+		opts.Endpoint = strings.TrimSuffix(opts.Endpoint, "/") + otlpTracesPath
+	}
+	e := &OTLPExporter{
+		opts:  opts,
+		spans: make(chan *Span, otlpQueueSize),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export queues the span for the next batch, it never blocks the request that ended the span.
+func (e *OTLPExporter) Export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+	}
+}
+
+// Close sends the queued spans and stops the exporter, no span may be exported after.
+func (e *OTLPExporter) Close() error {
+	close(e.spans)
+	<-e.done
+	return nil
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, e.opts.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil && e.opts.OnError != nil {
+			e.opts.OnError(err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case span, ok := <-e.spans:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if e.opts.BatchSize <= len(batch) {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (e *OTLPExporter) send(spans []*Span) error {
+	body, err := json.Marshal(newOTLPRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || 299 < resp.StatusCode {
+		return fmt.Errorf("OTLP export failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// region OTLP/HTTP JSON encoding
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	// Code 0 unset, 2 error.
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// newOTLPRequest groups the spans by service, the service.name of their resource.
+func newOTLPRequest(spans []*Span) otlpRequest {
+	var services []string
+	byService := make(map[string][]otlpSpan)
+	for _, span := range spans {
+		if _, found := byService[span.Service]; !found {
+			services = append(services, span.Service)
+		}
+		s := otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		}
+		for k, v := range span.Attributes {
+			s.Attributes = append(s.Attributes, otlpKeyValue{Key: k, Value: otlpAnyValue(v)})
+		}
+		if 0 < len(span.Error) {
+			s.Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+		byService[span.Service] = append(byService[span.Service], s)
+	}
+	req := otlpRequest{}
+	for _, service := range services {
+		req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+			Resource: otlpResource{Attributes: []otlpKeyValue{
+				{Key: "service.name", Value: otlpAnyValue(service)},
+			}},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/MuXiu1997/traefik-github-oauth-plugin"},
+				Spans: byService[service],
+			}},
+		})
+	}
+	return req
+}
+
+func otlpAnyValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
+
+// endregion OTLP/HTTP JSON encoding
@@ -0,0 +1,208 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//goland:noinspection GoSnakeCaseUsage
+const (
+	// SPAN_KIND_* the span kinds, numbered like OTLP.
+	SPAN_KIND_INTERNAL = 1
+	SPAN_KIND_SERVER   = 2
+	SPAN_KIND_CLIENT   = 3
+)
+
+// SpanContext identifies a span across processes, as carried by the W3C traceparent header.
+type SpanContext struct {
+	// TraceID the 32 hex digits of the trace.
+	TraceID string
+	// SpanID the 16 hex digits of the span.
+	SpanID  string
+	Sampled bool
+}
+
+// NewSpanContext starts a new sampled trace.
+func NewSpanContext() SpanContext {
+	return SpanContext{
+		TraceID: randomHex(16),
+		SpanID:  randomHex(8),
+		Sampled: true,
+	}
+}
+
+// Child returns a new span of the same trace.
+func (sc SpanContext) Child() SpanContext {
+	return SpanContext{
+		TraceID: sc.TraceID,
+		SpanID:  randomHex(8),
+		Sampled: sc.Sampled,
+	}
+}
+
+// IsValid reports whether the trace and span ids are set and not all zeros.
+func (sc SpanContext) IsValid() bool {
+	return isHexID(sc.TraceID, 32) && isHexID(sc.SpanID, 16)
+}
+
+// Traceparent formats the span context as a traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a traceparent header value, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceparent(traceparent string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	// later versions may append fields, version ff is invalid
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return SpanContext{}, false
+	}
+	sc := SpanContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Sampled: flags[0]&1 == 1,
+	}
+	return sc, sc.IsValid()
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx carrying the span context, the parent of the spans started from it.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by ctx.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Span a timed operation of a trace.
+type Span struct {
+	tracer  *Tracer
+	sampled bool
+
+	Service      string                 `json:"service"`
+	Name         string                 `json:"name"`
+	Kind         int                    `json:"kind"`
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	StartTime    time.Time              `json:"start_time"`
+	EndTime      time.Time              `json:"end_time"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	// Error the error the operation failed with, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// SetAttribute sets an attribute of the span, a nil span ignores it.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed with err, a nil err is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Error = err.Error()
+}
+
+// End ends the span and exports it, unless the trace is not sampled.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.EndTime = time.Now()
+	if s.sampled {
+		s.tracer.exporter.Export(s)
+	}
+}
+
+// Exporter sends the ended spans to a backend.
+type Exporter interface {
+	Export(span *Span)
+	// Close exports the spans still buffered.
+	Close() error
+}
+
+// Tracer starts the spans of a service. A nil Tracer starts no span, so tracing can be disabled without checks.
+type Tracer struct {
+	service  string
+	exporter Exporter
+}
+
+// NewTracer creates a Tracer exporting the spans of service.
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{
+		service:  service,
+		exporter: exporter,
+	}
+}
+
+// Start starts a span, a child of the span of ctx or the root of a new trace,
+// and returns a copy of ctx carrying it. The span must be ended with End.
+func (t *Tracer) Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	sc := NewSpanContext()
+	parentSpanID := ""
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		sc = parent.Child()
+		parentSpanID = parent.SpanID
+	}
+	span := &Span{
+		tracer:       t,
+		sampled:      sc.Sampled,
+		Service:      t.service,
+		Name:         name,
+		Kind:         kind,
+		TraceID:      sc.TraceID,
+		SpanID:       sc.SpanID,
+		ParentSpanID: parentSpanID,
+		StartTime:    time.Now(),
+	}
+	return ContextWithSpanContext(ctx, sc), span
+}
+
+// Close closes the exporter.
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.Close()
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isHexID(id string, length int) bool {
+	if len(id) != length || strings.Trim(id, "0") == "" {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
@@ -0,0 +1,111 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	// execution & assertion
+	sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.Equal(t, SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true}, sc)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	sc, ok = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.True(t, ok)
+	assert.False(t, sc.Sampled)
+
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestTracer_Start(t *testing.T) {
+	// setup
+	buf := &bytes.Buffer{}
+	tracer := NewTracer("test", NewStdoutExporter(buf))
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithSpanContext(context.Background(), remote)
+
+	// execution
+	ctx, server := tracer.Start(ctx, "GET /oauth/redirect", SPAN_KIND_SERVER)
+	_, client := tracer.Start(ctx, "oauth.exchange", SPAN_KIND_CLIENT)
+	client.SetError(errors.New("bad code"))
+	client.End()
+	server.SetAttribute("http.status_code", 500)
+	server.End()
+
+	// assertion
+	decoder := json.NewDecoder(buf)
+	var exchange, redirect Span
+	assert.NoError(t, decoder.Decode(&exchange))
+	assert.NoError(t, decoder.Decode(&redirect))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", redirect.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", redirect.ParentSpanID)
+	assert.Equal(t, redirect.TraceID, exchange.TraceID)
+	assert.Equal(t, redirect.SpanID, exchange.ParentSpanID)
+	assert.Equal(t, "bad code", exchange.Error)
+	assert.Equal(t, "test", exchange.Service)
+	assert.Equal(t, float64(500), redirect.Attributes["http.status_code"])
+
+	// a nil tracer starts no span
+	var disabled *Tracer
+	_, span := disabled.Start(context.Background(), "noop", SPAN_KIND_INTERNAL)
+	span.SetAttribute("ignored", true)
+	span.End()
+	assert.Nil(t, span)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	// setup
+	var received otlpRequest
+	var path, apiKey string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		apiKey = r.Header.Get("X-Api-Key")
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer collector.Close()
+	exporter := NewOTLPExporter(OTLPOptions{
+		Endpoint: collector.URL,
+		Headers:  map[string]string{"X-Api-Key": "secret"},
+	})
+	tracer := NewTracer("test", exporter)
+
+	// execution
+	_, span := tracer.Start(context.Background(), "oauth.user", SPAN_KIND_CLIENT)
+	span.SetAttribute("oauth.provider", "github")
+	span.End()
+	assert.NoError(t, tracer.Close())
+
+	// assertion
+	assert.Equal(t, "/v1/traces", path)
+	assert.Equal(t, "secret", apiKey)
+	if assert.Len(t, received.ResourceSpans, 1) {
+		resourceSpans := received.ResourceSpans[0]
+		assert.Equal(t, "test", resourceSpans.Resource.Attributes[0].Value["stringValue"])
+		spans := resourceSpans.ScopeSpans[0].Spans
+		if assert.Len(t, spans, 1) {
+			assert.Equal(t, span.TraceID, spans[0].TraceID)
+			assert.Equal(t, "oauth.user", spans[0].Name)
+			assert.Equal(t, SPAN_KIND_CLIENT, spans[0].Kind)
+			assert.Equal(t, []otlpKeyValue{{Key: "oauth.provider", Value: map[string]interface{}{"stringValue": "github"}}}, spans[0].Attributes)
+		}
+	}
+}
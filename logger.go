@@ -0,0 +1,222 @@
+package traefik_github_oauth_plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	gologger "github.com/apsdehal/go-logger"
+)
+
+const (
+	// LogFormatText the text lines of go-logger, the default.
+	LogFormatText = "text"
+	// LogFormatJson one JSON object per line, like the JSON logs of Traefik.
+	LogFormatJson = "json"
+)
+
+// logEntry a log entry of the middleware, the empty fields are left out.
+type logEntry struct {
+	Time       string `json:"time"`
+	Level      string `json:"level"`
+	Middleware string `json:"middleware"`
+	Message    string `json:"msg"`
+	RequestID  string `json:"request_id,omitempty"`
+	Host       string `json:"host,omitempty"`
+	Path       string `json:"path,omitempty"`
+	ClientIP   string `json:"client_ip,omitempty"`
+	UserID     string `json:"user_id,omitempty"`
+	UserLogin  string `json:"user_login,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+	Decision   string `json:"decision,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	// ServerCall and LatencyMs the server route the middleware called and how long the call took.
+	ServerCall string  `json:"server_call,omitempty"`
+	LatencyMs  float64 `json:"latency_ms,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// newRequestLogEntry creates the entry of a request Traefik is serving, with its user if known and the error if any.
+func newRequestLogEntry(req *http.Request, user *jwt.PayloadUser, err error) logEntry {
+	entry := logEntry{
+		RequestID: getRequestID(req),
+		Host:      req.Host,
+		Path:      req.URL.Path,
+		ClientIP:  getClientIP(req),
+	}
+	if user != nil {
+		entry.UserID = user.Id
+		entry.UserLogin = user.Login
+		entry.Provider = user.Provider
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// middlewareLogger writes the logs of a middleware as text or JSON lines, optionally redacting the users and the IPs.
+type middlewareLogger struct {
+	name   string
+	level  gologger.LogLevel
+	redact bool
+
+	// text is nil for the JSON format.
+	text *gologger.Logger
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// newMiddlewareLogger creates the logger of the middleware name.
+func newMiddlewareLogger(name string, config *Config, out io.Writer) (*middlewareLogger, error) {
+	level := gologger.InfoLevel
+	switch config.LogLevel {
+	case "DEBUG", "debug":
+		level = gologger.DebugLevel
+	case "INFO", "info":
+		level = gologger.InfoLevel
+	case "WARNING", "warning", "WARN", "warn":
+		level = gologger.WarningLevel
+	case "ERROR", "error":
+		level = gologger.ErrorLevel
+	}
+	l := &middlewareLogger{
+		name:   name,
+		level:  level,
+		redact: config.LogRedact,
+		out:    out,
+	}
+	switch config.LogFormat {
+	case "", LogFormatText:
+		text, err := gologger.New("TraefikGithubOauthMiddleware", out, 0)
+		if err != nil {
+			return nil, err
+		}
+		text.SetLogLevel(level)
+		text.SetFormat("[%{module}] | %{level} | %{time} | %{message}")
+		l.text = text
+	case LogFormatJson:
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", config.LogFormat)
+	}
+	return l, nil
+}
+
+func (l *middlewareLogger) Debug(msg string, entry logEntry) {
+	l.log(gologger.DebugLevel, "debug", msg, entry)
+}
+
+func (l *middlewareLogger) Info(msg string, entry logEntry) {
+	l.log(gologger.InfoLevel, "info", msg, entry)
+}
+
+func (l *middlewareLogger) Warning(msg string, entry logEntry) {
+	l.log(gologger.WarningLevel, "warn", msg, entry)
+}
+
+func (l *middlewareLogger) Error(msg string, entry logEntry) {
+	l.log(gologger.ErrorLevel, "error", msg, entry)
+}
+
+func (l *middlewareLogger) log(level gologger.LogLevel, levelName, msg string, entry logEntry) {
+	if l.level < level {
+		return
+	}
+	entry.Middleware = l.name
+	entry.Message = msg
+	if l.redact {
+		entry.UserID = redactUser(entry.UserID)
+		entry.UserLogin = redactUser(entry.UserLogin)
+		entry.ClientIP = redactIP(entry.ClientIP)
+	}
+
+	if l.text != nil {
+		l.text.Log(level, msg+formatLogFields(entry))
+		return
+	}
+	entry.Time = time.Now().Format(time.RFC3339)
+	entry.Level = levelName
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(append(b, '\n'))
+}
+
+// formatLogFields formats the fields of the text format as key=value pairs.
+func formatLogFields(entry logEntry) string {
+	var builder strings.Builder
+	add := func(key, value string) {
+		if len(value) == 0 {
+			return
+		}
+		builder.WriteString(" ")
+		builder.WriteString(key)
+		builder.WriteString("=")
+		if strings.ContainsAny(value, " \"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		builder.WriteString(value)
+	}
+	add("middleware", entry.Middleware)
+	add("request_id", entry.RequestID)
+	add("host", entry.Host)
+	add("path", entry.Path)
+	add("client_ip", entry.ClientIP)
+	add("user_id", entry.UserID)
+	add("user_login", entry.UserLogin)
+	add("provider", entry.Provider)
+	add("decision", entry.Decision)
+	add("reason", entry.Reason)
+	add("server_call", entry.ServerCall)
+	if 0 < entry.LatencyMs {
+		add("latency_ms", fmt.Sprintf("%.3f", entry.LatencyMs))
+	}
+	add("error", entry.Error)
+	return builder.String()
+}
+
+// redactUser replaces a user id or login with a pseudonym, the same user always gets the same one,
+// so the entries of a user still correlate without naming them.
+func redactUser(user string) string {
+	if len(user) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(user))
+	return "redacted-" + hex.EncodeToString(sum[:6])
+}
+
+// redactIP zeroes the host part of an IP: the last byte of an IPv4, the last 80 bits of an IPv6.
+// The port of an address is dropped.
+func redactIP(ip string) string {
+	if len(ip) == 0 {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "redacted"
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// millisecondsSince the latency of a call started at start, in milliseconds.
+func millisecondsSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
@@ -0,0 +1,145 @@
+package traefik_github_oauth_plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatLogFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		entry  logEntry
+		fields string
+	}{
+		{name: "empty", entry: logEntry{}, fields: ""},
+		{
+			name:   "request",
+			entry:  logEntry{Middleware: "whoami", Host: "whoami.example.com", Path: "/", ClientIP: "203.0.113.7", UserLogin: "alice"},
+			fields: " middleware=whoami host=whoami.example.com path=/ client_ip=203.0.113.7 user_login=alice",
+		},
+		{
+			name:   "quoted",
+			entry:  logEntry{Reason: "missing role: admin", Error: `invalid "token"`},
+			fields: ` reason="missing role: admin" error="invalid \"token\""`,
+		},
+		{
+			name:   "latency",
+			entry:  logEntry{ServerCall: "result", LatencyMs: 12.3456},
+			fields: " server_call=result latency_ms=12.346",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// execution & assertion
+			assert.Equal(t, tt.fields, formatLogFields(tt.entry))
+		})
+	}
+}
+
+// newTestLogger a logger of the format writing to the returned buffer.
+func newTestLogger(t *testing.T, format, level string, redact bool) (*middlewareLogger, *bytes.Buffer) {
+	t.Helper()
+	config := CreateConfig()
+	config.LogFormat = format
+	config.LogLevel = level
+	config.LogRedact = redact
+	out := &bytes.Buffer{}
+	logger, err := newMiddlewareLogger("whoami", config, out)
+	assert.NoError(t, err)
+	return logger, out
+}
+
+func TestMiddlewareLogger_Json(t *testing.T) {
+	// setup
+	logger, out := newTestLogger(t, LogFormatJson, "info", false)
+
+	// execution
+	logger.Debug("authorization", logEntry{UserLogin: "alice"})
+	logger.Warning("revocationPoller: fetch", logEntry{Error: "connection refused"})
+	logger.Info("authorization", logEntry{UserID: "1", UserLogin: "alice", ClientIP: "203.0.113.7", Decision: "allowed"})
+
+	// assertion
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	var warning, info map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &warning))
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &info))
+	assert.Equal(t, "warn", warning["level"])
+	assert.Equal(t, "connection refused", warning["error"])
+	assert.NotContains(t, warning, "user_login")
+	assert.Equal(t, "info", info["level"])
+	assert.Equal(t, "whoami", info["middleware"])
+	assert.Equal(t, "authorization", info["msg"])
+	assert.Equal(t, "alice", info["user_login"])
+	assert.Equal(t, "203.0.113.7", info["client_ip"])
+	assert.Equal(t, "allowed", info["decision"])
+	assert.NotEmpty(t, info["time"])
+}
+
+func TestMiddlewareLogger_Redact(t *testing.T) {
+	for _, format := range []string{LogFormatText, LogFormatJson} {
+		t.Run(format, func(t *testing.T) {
+			// setup
+			logger, out := newTestLogger(t, format, "info", true)
+
+			// execution
+			logger.Info("authorization", logEntry{UserID: "12345", UserLogin: "alice", ClientIP: "203.0.113.7"})
+
+			// assertion
+			assert.NotContains(t, out.String(), "12345")
+			assert.NotContains(t, out.String(), "alice")
+			assert.NotContains(t, out.String(), "203.0.113.7")
+			assert.Contains(t, out.String(), redactUser("alice"))
+			assert.Contains(t, out.String(), "203.0.113.0")
+		})
+	}
+}
+
+func TestNewMiddlewareLogger_UnknownFormat(t *testing.T) {
+	// setup
+	config := CreateConfig()
+	config.LogFormat = "xml"
+
+	// execution
+	_, err := newMiddlewareLogger("whoami", config, &bytes.Buffer{})
+
+	// assertion
+	assert.ErrorContains(t, err, "unknown log format: xml")
+}
+
+func TestRedactUser(t *testing.T) {
+	// execution
+	alice := redactUser("alice")
+
+	// assertion
+	assert.Equal(t, alice, redactUser("alice"))
+	assert.NotEqual(t, alice, redactUser("bob"))
+	assert.Regexp(t, "^redacted-[0-9a-f]{12}$", alice)
+	assert.Empty(t, redactUser(""))
+}
+
+func TestRedactIP(t *testing.T) {
+	tests := []struct {
+		ip       string
+		redacted string
+	}{
+		{ip: "", redacted: ""},
+		{ip: "203.0.113.7", redacted: "203.0.113.0"},
+		{ip: "203.0.113.7:54321", redacted: "203.0.113.0"},
+		{ip: "2001:db8:1234:5678::1", redacted: "2001:db8:1234::"},
+		{ip: "[2001:db8:1234:5678::1]:443", redacted: "2001:db8:1234::"},
+		{ip: "::ffff:203.0.113.7", redacted: "203.0.113.0"},
+		{ip: "not an ip", redacted: "redacted"},
+		{ip: "203.0.113", redacted: "redacted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			// execution & assertion
+			assert.Equal(t, tt.redacted, redactIP(tt.ip))
+		})
+	}
+}
@@ -3,52 +3,136 @@ package traefik_github_oauth_plugin
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit"
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwks"
 	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
-	gologger "github.com/apsdehal/go-logger"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/requestid"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/roles"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/tracing"
 	"github.com/dghubble/sling"
 	"github.com/scylladb/go-set/strset"
 )
 
 const (
 	DefaultConfigAuthPath = "/_auth"
+	// DefaultProvider the provider of the sessions issued before providers were introduced.
+	DefaultProvider = "github"
+	// DefaultRevocationPollIntervalSeconds how often the revocation list is polled, the delay before a revocation applies.
+	DefaultRevocationPollIntervalSeconds = 30
+	// DefaultRevocationMaxStalenessSeconds how long the last revocation list is trusted while the server cannot be reached.
+	DefaultRevocationMaxStalenessSeconds = 300
+)
+
+var (
+	ErrSessionRevoked = errors.New("session revoked")
+	// ErrRevocationListStale the sessions are refused while the revocation list is older than its max staleness.
+	ErrRevocationListStale = errors.New("the revocation list is stale, the server cannot be reached")
 )
 
 // Config the middleware configuration.
 type Config struct {
-	ApiBaseUrl   string          `json:"api_base_url,omitempty"`
-	ApiSecretKey string          `json:"api_secret_key,omitempty"`
-	AuthPath     string          `json:"auth_path,omitempty"`
-	JwtSecretKey string          `json:"jwt_secret_key,omitempty"`
-	LogLevel     string          `json:"log_level,omitempty"`
-	Whitelist    ConfigWhitelist `json:"whitelist,omitempty"`
+	ApiBaseUrl                    string                `json:"api_base_url,omitempty"`
+	ApiSecretKey                  string                `json:"api_secret_key,omitempty"`
+	AuthPath                      string                `json:"auth_path,omitempty"`
+	Client                        string                `json:"client,omitempty"`
+	Scopes                        []string              `json:"scopes,omitempty"`
+	AllowSignup                   bool                  `json:"allow_signup,omitempty"`
+	JwtSecretKey                  string                `json:"jwt_secret_key,omitempty"`
+	CookieEncryptionKeys          []string              `json:"cookie_encryption_keys,omitempty"`
+	JwksUrl                       string                `json:"jwks_url,omitempty"`
+	RevocationPollIntervalSeconds int                   `json:"revocation_poll_interval_seconds,omitempty"`
+	RevocationMaxStalenessSeconds int                   `json:"revocation_max_staleness_seconds,omitempty"`
+	BearerTokens                  bool                  `json:"bearer_tokens,omitempty"`
+	LogLevel                      string                `json:"log_level,omitempty"`
+	LogFormat                     string                `json:"log_format,omitempty"`
+	LogRedact                     bool                  `json:"log_redact,omitempty"`
+	AccessTokenHeader             string                `json:"access_token_header,omitempty"`
+	DebugEndpoint                 bool                  `json:"debug_endpoint,omitempty"`
+	Roles                         map[string]ConfigRole `json:"roles,omitempty"`
+	RolesHeader                   string                `json:"roles_header,omitempty"`
+	RequiredRoles                 []ConfigRequiredRole  `json:"required_roles,omitempty"`
+	LoginPage                     ConfigLoginPage       `json:"login_page,omitempty"`
+	Whitelist                     ConfigWhitelist       `json:"whitelist,omitempty"`
+	AuditLog                      ConfigAuditLog        `json:"audit_log,omitempty"`
+	Tls                           ConfigTls             `json:"tls,omitempty"`
+}
+
+// ConfigLoginPage the middleware configuration of the sign-in page the server shows before the provider login page.
+type ConfigLoginPage struct {
+	// Enabled shows the page even if the OAuth client of the server does not.
+	Enabled bool `json:"enabled,omitempty"`
+	// AppName the application name on the page, defaults to its host.
+	AppName string `json:"app_name,omitempty"`
+	// LogoUrl and Message replace the ones of the OAuth client.
+	LogoUrl string `json:"logo_url,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
 // ConfigWhitelist the middleware configuration whitelist.
+// An entry prefixed with a provider and a colon, e.g. gitea:alice, only matches users of that provider.
 type ConfigWhitelist struct {
-	// Ids the GitHub user id list.
+	// Ids the user id list.
 	Ids []string `json:"ids,omitempty"`
-	// Logins the GitHub user login list.
+	// Logins the user login list.
 	Logins []string `json:"logins,omitempty"`
+	// Groups the group list, e.g. GitHub orgs and org/team slugs.
+	Groups []string `json:"groups,omitempty"`
+	// Providers the providers the user must come from, empty for any.
+	Providers []string `json:"providers,omitempty"`
+}
+
+// ConfigAuditLog the middleware configuration audit log.
+type ConfigAuditLog struct {
+	// FilePath the audit log file, the file sink is disabled if empty.
+	FilePath string `json:"file_path,omitempty"`
+	// MaxSizeMB the size in megabytes after which the file is rotated.
+	MaxSizeMB int `json:"max_size_mb,omitempty"`
+	// MaxBackups the number of rotated files to keep.
+	MaxBackups int `json:"max_backups,omitempty"`
+}
+
+// ConfigTls the middleware configuration of the TLS connections to the server.
+type ConfigTls struct {
+	// CaFile a PEM bundle of the CAs trusted for the server certificate, in addition to the system ones.
+	CaFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile the PEM client certificate and key, for a server requiring them with TLS_CLIENT_CA_FILE.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
 }
 
 // CreateConfig creates the default middleware configuration.
 func CreateConfig() *Config {
 	return &Config{
-		ApiBaseUrl:   "",
-		ApiSecretKey: "",
-		AuthPath:     DefaultConfigAuthPath,
-		JwtSecretKey: getRandomString32(),
+		ApiBaseUrl:                    "",
+		ApiSecretKey:                  "",
+		AuthPath:                      DefaultConfigAuthPath,
+		Scopes:                        []string{},
+		AllowSignup:                   true,
+		JwtSecretKey:                  getRandomString32(),
+		RevocationPollIntervalSeconds: DefaultRevocationPollIntervalSeconds,
+		RevocationMaxStalenessSeconds: DefaultRevocationMaxStalenessSeconds,
 		Whitelist: ConfigWhitelist{
-			Ids:    []string{},
-			Logins: []string{},
+			Ids:       []string{},
+			Logins:    []string{},
+			Groups:    []string{},
+			Providers: []string{},
+		},
+		AuditLog: ConfigAuditLog{
+			MaxSizeMB:  100,
+			MaxBackups: 3,
 		},
 	}
 }
@@ -59,86 +143,250 @@ type TraefikGithubOauthMiddleware struct {
 	next http.Handler
 	name string
 
-	apiBaseUrl        string
-	apiSecretKey      string
-	authPath          string
-	jwtSecretKey      string
-	whitelistIdSet    *strset.Set
-	whitelistLoginSet *strset.Set
+	apiBaseUrl   string
+	apiSecretKey string
+	authPath     string
+	client       string
+	scopes       []string
+	allowSignup  bool
+	loginPage    *model.LoginPage
+	jwtSecretKey string
+	httpClient   *http.Client
+	sessionKeys  *jwks.Cache
+	// encrypter encrypts the session cookie, nil without cookie encryption keys
+	encrypter *jwt.Encrypter
+	// bearerTokens whether the session tokens of the device flow are accepted in the Authorization header
+	bearerTokens bool
+
+	accessTokenHeader string
+	accessTokens      *accessTokenCache
+
+	roles         roles.Mapping
+	rolesHeader   string
+	requiredRoles []ConfigRequiredRole
+
+	debugEndpoint bool
 
-	logger *gologger.Logger
+	// revocations is nil when the revocation list is not polled
+	revocations          *revocationPoller
+	whitelistIdSet       *strset.Set
+	whitelistLoginSet    *strset.Set
+	whitelistGroupSet    *strset.Set
+	whitelistProviderSet *strset.Set
+
+	logger      *middlewareLogger
+	auditLogger *audit.Logger
 }
 
 var _ http.Handler = (*TraefikGithubOauthMiddleware)(nil)
 
 // New creates a new TraefikGithubOauthMiddleware.
 func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
-	// region Setup logger
-	logLevel := gologger.InfoLevel
-	switch config.LogLevel {
-	case "DEBUG", "debug":
-		logLevel = gologger.DebugLevel
-	case "INFO", "info":
-		logLevel = gologger.InfoLevel
-	case "WARNING", "warning", "WARN", "warn":
-		logLevel = gologger.WarningLevel
-	case "ERROR", "error":
-		logLevel = gologger.ErrorLevel
-	}
-	logger, err := gologger.New("TraefikGithubOauthMiddleware", os.Stdout, 0)
+	logger, err := newMiddlewareLogger(name, config, os.Stdout)
 	if err != nil {
 		return nil, err
 	}
-	logger.SetLogLevel(logLevel)
-	logger.SetFormat("[%{module}] | %{level} | %{time} | %{message}")
-	// endregion Setup logger
 
 	authPath := config.AuthPath
 	if !strings.HasPrefix(authPath, "/") {
 		authPath = "/" + authPath
 	}
 
-	return &TraefikGithubOauthMiddleware{
+	jwksUrl := config.JwksUrl
+	if len(jwksUrl) == 0 {
+		jwksUrl = strings.TrimSuffix(config.ApiBaseUrl, "/") + "/" + constant.ROUTER_PATH_JWKS
+	}
+
+	httpClient, err := newHttpClient(config.Tls)
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypter *jwt.Encrypter
+	if 0 < len(config.CookieEncryptionKeys) {
+		encrypter, err = jwt.NewEncrypter(config.CookieEncryptionKeys...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the instances of every router and config reload share the file, an instance releases it when its context ends
+	auditLogger, err := audit.NewLoggerFromOptions(audit.Options{
+		FilePath:   config.AuditLog.FilePath,
+		MaxSizeMB:  config.AuditLog.MaxSizeMB,
+		MaxBackups: config.AuditLog.MaxBackups,
+		ShareFile:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if 0 < len(config.AuditLog.FilePath) {
+		go func() {
+			<-ctx.Done()
+			_ = auditLogger.Close()
+		}()
+	}
+
+	p := &TraefikGithubOauthMiddleware{
 		ctx:  ctx,
 		next: next,
 		name: name,
 
-		apiBaseUrl:        config.ApiBaseUrl,
-		apiSecretKey:      config.ApiSecretKey,
-		authPath:          authPath,
-		jwtSecretKey:      config.JwtSecretKey,
-		whitelistIdSet:    strset.New(config.Whitelist.Ids...),
-		whitelistLoginSet: strset.New(config.Whitelist.Logins...),
+		apiBaseUrl:           config.ApiBaseUrl,
+		apiSecretKey:         config.ApiSecretKey,
+		authPath:             authPath,
+		client:               config.Client,
+		scopes:               config.Scopes,
+		allowSignup:          config.AllowSignup,
+		loginPage:            newLoginPage(config.LoginPage),
+		jwtSecretKey:         config.JwtSecretKey,
+		httpClient:           httpClient,
+		sessionKeys:          jwks.NewCache(jwksUrl, httpClient),
+		encrypter:            encrypter,
+		bearerTokens:         config.BearerTokens,
+		accessTokenHeader:    http.CanonicalHeaderKey(config.AccessTokenHeader),
+		accessTokens:         newAccessTokenCache(),
+		roles:                newRoleMapping(config.Roles),
+		rolesHeader:          http.CanonicalHeaderKey(config.RolesHeader),
+		requiredRoles:        newRequiredRoles(config.RequiredRoles),
+		debugEndpoint:        config.DebugEndpoint,
+		whitelistIdSet:       strset.New(config.Whitelist.Ids...),
+		whitelistLoginSet:    strset.New(config.Whitelist.Logins...),
+		whitelistGroupSet:    strset.New(config.Whitelist.Groups...),
+		whitelistProviderSet: strset.New(config.Whitelist.Providers...),
 
-		logger: logger,
-	}, nil
+		logger:      logger,
+		auditLogger: auditLogger,
+	}
+	if 0 < config.RevocationPollIntervalSeconds {
+		// the middlewares of the same server and settings share a poller, an instance releases it when its context ends
+		p.revocations = acquireRevocationPoller(revocationPollerOptions{
+			apiBaseUrl:   config.ApiBaseUrl,
+			apiSecretKey: config.ApiSecretKey,
+			tls:          config.Tls,
+			interval:     time.Duration(config.RevocationPollIntervalSeconds) * time.Second,
+			maxStaleness: time.Duration(config.RevocationMaxStalenessSeconds) * time.Second,
+		}, httpClient, logger)
+		go func() {
+			<-ctx.Done()
+			p.revocations.release()
+		}()
+	}
+	return p, nil
+}
+
+// newLoginPage returns the sign-in page sent to the server, nil if the middleware leaves it to the OAuth client.
+func newLoginPage(config ConfigLoginPage) *model.LoginPage {
+	if config == (ConfigLoginPage{}) {
+		return nil
+	}
+	return &model.LoginPage{
+		Enabled: config.Enabled,
+		AppName: config.AppName,
+		LogoURL: config.LogoUrl,
+		Message: config.Message,
+	}
+}
+
+// newHttpClient creates the client of the server calls, the default client without TLS settings.
+func newHttpClient(config ConfigTls) (*http.Client, error) {
+	if len(config.CaFile) == 0 && len(config.CertFile) == 0 {
+		return http.DefaultClient, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if 0 < len(config.CaFile) {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(config.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate in %s", config.CaFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if 0 < len(config.CertFile) {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
 }
 
 // ServeHTTP implements http.Handler.
 func (p *TraefikGithubOauthMiddleware) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if req.URL.Path == p.authPath {
+	// the request id correlates the logs of the middleware, the server and the upstream
+	requestID := requestid.FromHeader(req.Header.Get(constant.HTTP_HEADER_X_REQUEST_ID))
+	req.Header.Set(constant.HTTP_HEADER_X_REQUEST_ID, requestID)
+	rw.Header().Set(constant.HTTP_HEADER_X_REQUEST_ID, requestID)
+	// only the middleware sets the access token and roles headers, whatever the client sent is dropped
+	if 0 < len(p.accessTokenHeader) {
+		req.Header.Del(p.accessTokenHeader)
+	}
+	if 0 < len(p.rolesHeader) {
+		req.Header.Del(p.rolesHeader)
+	}
+	switch {
+	case req.URL.Path == p.authPath:
 		p.handleAuthRequest(rw, req)
-		return
+	case req.URL.Path == p.authPath+userInfoPath:
+		p.handleUserInfoRequest(rw, req)
+	case req.URL.Path == p.authPath+debugPath && p.debugEndpoint:
+		p.handleDebugRequest(rw, req)
+	default:
+		p.handleRequest(rw, req)
 	}
-	p.handleRequest(rw, req)
 }
 
 // handleRequest
 func (p *TraefikGithubOauthMiddleware) handleRequest(rw http.ResponseWriter, req *http.Request) {
+	bearerUser, bearerErr := p.getBearerUser(req)
+	if bearerUser != nil {
+		p.handleBearerRequest(rw, req, bearerUser)
+		return
+	}
 	user, err := p.getGitHubUserFromCookie(req)
+	if err != nil && bearerErr != nil {
+		// a command-line client has no login page to be redirected to
+		p.logger.Debug("handleRequest: getBearerUser", newRequestLogEntry(req, nil, bearerErr))
+		p.audit(req, nil, audit.DECISION_DENIED, "invalid bearer token: "+bearerErr.Error())
+		setNoCacheHeaders(rw)
+		rw.Header().Set("WWW-Authenticate", constant.AUTHORIZATION_PREFIX_BEARER+` error="invalid_token"`)
+		httpError(rw, req, bearerErr.Error(), http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		p.logger.Debugf("handleRequest: getGitHubUserFromCookie: %s\n", err.Error())
+		p.logger.Debug("handleRequest: getGitHubUserFromCookie", newRequestLogEntry(req, nil, err))
+		p.audit(req, nil, audit.DECISION_DENIED, "unauthenticated: "+err.Error())
 		if req.Method == http.MethodGet {
 			p.redirectToOAuthPage(rw, req)
 		}
-		http.Error(rw, err.Error(), http.StatusUnauthorized)
+		httpError(rw, req, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	if !p.whitelistIdSet.Has(user.Id) && !p.whitelistLoginSet.Has(user.Login) {
-		setNoCacheHeaders(rw)
-		http.Error(rw, "not in whitelist", http.StatusForbidden)
+	if !p.authorize(rw, req, user) {
 		return
 	}
+	p.forwardAccessToken(rw, req, user)
+	p.forwardRoles(req, user)
+	p.next.ServeHTTP(rw, req)
+}
+
+// handleBearerRequest forwards the request of a command-line client authenticated with the session token of the device flow,
+// the token is not forwarded upstream.
+func (p *TraefikGithubOauthMiddleware) handleBearerRequest(rw http.ResponseWriter, req *http.Request, user *jwt.PayloadUser) {
+	if !p.authorize(rw, req, user) {
+		return
+	}
+	req.Header.Del(constant.HTTP_HEADER_AUTHORIZATION)
+	p.forwardRoles(req, user)
 	p.next.ServeHTTP(rw, req)
 }
 
@@ -146,49 +394,165 @@ func (p *TraefikGithubOauthMiddleware) handleRequest(rw http.ResponseWriter, req
 func (p *TraefikGithubOauthMiddleware) handleAuthRequest(rw http.ResponseWriter, req *http.Request) {
 	setNoCacheHeaders(rw)
 	rid := req.URL.Query().Get(constant.QUERY_KEY_REQUEST_ID)
-	result, err := p.getAuthResult(rid)
+	result, err := p.getAuthResult(req, rid)
 	if err != nil {
-		p.logger.Debugf("handleAuthRequest: getAuthResult: %s\n", err.Error())
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		p.logger.Debug("handleAuthRequest: getAuthResult", newRequestLogEntry(req, nil, err))
+		httpError(rw, req, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	tokenString, err := jwt.GenerateJwtTokenString(result.GitHubUserID, result.GitHubUserLogin, p.jwtSecretKey)
-	if err != nil {
-		p.logger.Debugf("handleAuthRequest: GenerateJwtTokenString: %s\n", err.Error())
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
-		return
+	user := &jwt.PayloadUser{
+		Id:       result.GitHubUserID,
+		Login:    result.GitHubUserLogin,
+		Provider: result.Provider,
+		Groups:   result.Groups,
 	}
-	http.SetCookie(rw, &http.Cookie{
+	tokenString := result.SessionToken
+	if len(tokenString) == 0 {
+		// the server predates the session tokens, the middleware signs the cookie itself
+		tokenString, err = jwt.GenerateUserJwtTokenString(user, p.jwtSecretKey, jwt.WithEncrypter(p.encrypter))
+		if err != nil {
+			p.logger.Debug("handleAuthRequest: GenerateUserJwtTokenString", newRequestLogEntry(req, user, err))
+			httpError(rw, req, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if p.encrypter != nil {
+		tokenString, err = p.encrypter.Encrypt(tokenString)
+		if err != nil {
+			p.logger.Debug("handleAuthRequest: Encrypt", newRequestLogEntry(req, user, err))
+			httpError(rw, req, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	setChunkedCookie(rw, req, &http.Cookie{
 		Name:     constant.COOKIE_NAME_JWT,
 		Value:    tokenString,
 		HttpOnly: true,
 	})
+	if 0 < len(p.accessTokenHeader) && 0 < len(result.SealedAccessToken) {
+		setAccessTokenCookie(rw, req, result.SealedAccessToken)
+	}
+	p.audit(req, user, audit.DECISION_BYPASSED, "login callback")
 	http.Redirect(rw, req, result.RedirectURI, http.StatusFound)
 }
 
+// authorization the outcome of the authorization of a user for a path.
+type authorization struct {
+	Allowed bool `json:"allowed"`
+	// Rules the whitelist entry and the required roles that allowed the user.
+	Rules []string `json:"rules,omitempty"`
+	// Explanation why the user was denied.
+	Explanation string `json:"explanation,omitempty"`
+	// reason the reason of the audit event.
+	reason string
+}
+
+// authorize checks the user is whitelisted and has one of the roles required by the path,
+// it answers 403 Forbidden otherwise.
+func (p *TraefikGithubOauthMiddleware) authorize(rw http.ResponseWriter, req *http.Request, user *jwt.PayloadUser) bool {
+	authz := p.authorizeUser(user, req.URL.Path)
+	if !authz.Allowed {
+		p.audit(req, user, audit.DECISION_DENIED, authz.reason)
+		setNoCacheHeaders(rw)
+		httpError(rw, req, authz.reason, http.StatusForbidden)
+		return false
+	}
+	p.audit(req, user, audit.DECISION_ALLOWED, authz.reason)
+	return true
+}
+
+// authorizeUser returns whether the user may access the path, and the rules that decided it.
+func (p *TraefikGithubOauthMiddleware) authorizeUser(user *jwt.PayloadUser, path string) *authorization {
+	rule, explanation := p.whitelistRule(user)
+	if len(rule) == 0 {
+		return &authorization{Explanation: explanation, reason: "not in whitelist"}
+	}
+	authz := &authorization{Allowed: true, Rules: []string{rule}, reason: "in whitelist"}
+	requiredRoles := p.requiredRolesOf(path)
+	if requiredRoles == nil {
+		return authz
+	}
+	if !roles.HasAny(user.Roles, requiredRoles.Roles) {
+		return &authorization{
+			Explanation: fmt.Sprintf("the path prefix %s requires one of the roles %s, the user has %s",
+				requiredRoles.PathPrefix, strings.Join(requiredRoles.Roles, ", "), strings.Join(user.Roles, ", ")),
+			reason: "missing role: " + strings.Join(requiredRoles.Roles, ", "),
+		}
+	}
+	authz.Rules = append(authz.Rules, fmt.Sprintf("requiredRoles %s: %s", requiredRoles.PathPrefix, strings.Join(requiredRoles.Roles, ", ")))
+	return authz
+}
+
+// whitelistRule returns the whitelist entry matching the user id, login or one of its groups,
+// as is or prefixed with the user provider, or why none does.
+func (p *TraefikGithubOauthMiddleware) whitelistRule(user *jwt.PayloadUser) (rule, explanation string) {
+	provider := user.Provider
+	if len(provider) == 0 {
+		provider = DefaultProvider
+	}
+	if !p.whitelistProviderSet.IsEmpty() && !p.whitelistProviderSet.Has(provider) {
+		return "", fmt.Sprintf("the provider %s is not in whitelist.providers", provider)
+	}
+	match := func(set *strset.Set, value string) string {
+		if set.Has(value) {
+			return value
+		}
+		if set.Has(provider + ":" + value) {
+			return provider + ":" + value
+		}
+		return ""
+	}
+	if entry := match(p.whitelistIdSet, user.Id); 0 < len(entry) {
+		return "whitelist.ids: " + entry, ""
+	}
+	if entry := match(p.whitelistLoginSet, user.Login); 0 < len(entry) {
+		return "whitelist.logins: " + entry, ""
+	}
+	for _, group := range user.Groups {
+		if entry := match(p.whitelistGroupSet, group); 0 < len(entry) {
+			return "whitelist.groups: " + entry, ""
+		}
+	}
+	return "", "neither the id, the login nor a group of the user is in the whitelist"
+}
+
 func (p *TraefikGithubOauthMiddleware) redirectToOAuthPage(rw http.ResponseWriter, req *http.Request) {
 	setNoCacheHeaders(rw)
-	oAuthPageURL, err := p.generateOAuthPageURL(getRawRequestUrl(req), p.getAuthURL(req))
+	oAuthPageURL, err := p.generateOAuthPageURL(req, getRawRequestUrl(req), p.getAuthURL(req), getClientIP(req))
 	if err != nil {
-		p.logger.Debugf("redirectToOAuthPage: generateOAuthPageURL: %s\n", err.Error())
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		p.logger.Debug("redirectToOAuthPage: generateOAuthPageURL", newRequestLogEntry(req, nil, err))
+		httpError(rw, req, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	http.Redirect(rw, req, oAuthPageURL, http.StatusFound)
 }
 
-func (p *TraefikGithubOauthMiddleware) generateOAuthPageURL(redirectURI, authURL string) (string, error) {
+func (p *TraefikGithubOauthMiddleware) generateOAuthPageURL(
+	parentReq *http.Request,
+	redirectURI, authURL, clientIP string,
+) (string, error) {
 	reqBody := model.RequestGenerateOAuthPageURL{
 		RedirectURI: redirectURI,
 		AuthURL:     authURL,
+		ClientIP:    clientIP,
+		Client:      p.client,
+
+		ForwardAccessToken: 0 < len(p.accessTokenHeader),
+		Scopes:             p.scopes,
+		DisallowSignup:     !p.allowSignup,
+		// a link can suggest the account to log in with, e.g. https://whoami.example.com/?login_hint=MuXiu1997
+		LoginHint: parentReq.URL.Query().Get(constant.QUERY_KEY_LOGIN_HINT),
+		LoginPage: p.loginPage,
 	}
-	req := sling.New().Base(p.apiBaseUrl).Post(constant.ROUTER_GROUP_PATH_OAUTH + "/" + constant.ROUTER_PATH_OAUTH_PAGE_URL)
+	req := sling.New().Client(p.httpClient).Base(p.apiBaseUrl).Post(constant.ROUTER_GROUP_PATH_OAUTH + "/" + constant.ROUTER_PATH_OAUTH_PAGE_URL)
 	if 0 < len(p.apiSecretKey) {
 		req.Set(constant.HTTP_HEADER_AUTHORIZATION, fmt.Sprintf("%s %s", constant.AUTHORIZATION_PREFIX_TOKEN, p.apiSecretKey))
 	}
+	setCorrelationHeaders(req, parentReq)
 	var respBody model.ResponseGenerateOAuthPageURL
 	var errRespBody model.ResponseError
+	start := time.Now()
 	_, err := req.BodyJSON(reqBody).Receive(&respBody, &errRespBody)
+	p.logServerCall(parentReq, constant.ROUTER_PATH_OAUTH_PAGE_URL, start, err)
 	if err != nil {
 		return "", err
 	}
@@ -198,11 +562,12 @@ func (p *TraefikGithubOauthMiddleware) generateOAuthPageURL(redirectURI, authURL
 	return respBody.OAuthPageURL, nil
 }
 
-func (p *TraefikGithubOauthMiddleware) getAuthResult(rid string) (*model.ResponseGetAuthResult, error) {
-	req := sling.New().Base(p.apiBaseUrl).Get(constant.ROUTER_GROUP_PATH_OAUTH + "/" + constant.ROUTER_PATH_OAUTH_RESULT)
+func (p *TraefikGithubOauthMiddleware) getAuthResult(parentReq *http.Request, rid string) (*model.ResponseGetAuthResult, error) {
+	req := sling.New().Client(p.httpClient).Base(p.apiBaseUrl).Get(constant.ROUTER_GROUP_PATH_OAUTH + "/" + constant.ROUTER_PATH_OAUTH_RESULT)
 	if 0 < len(p.apiSecretKey) {
 		req.Set(constant.HTTP_HEADER_AUTHORIZATION, fmt.Sprintf("%s %s", constant.AUTHORIZATION_PREFIX_TOKEN, p.apiSecretKey))
 	}
+	setCorrelationHeaders(req, parentReq)
 
 	// req.QueryStruct seems to panic in yaegi
 	httpRequest, err := req.Request()
@@ -215,7 +580,9 @@ func (p *TraefikGithubOauthMiddleware) getAuthResult(rid string) (*model.Respons
 
 	var respBody model.ResponseGetAuthResult
 	var errRespBody model.ResponseError
+	start := time.Now()
 	_, err = req.Do(httpRequest, &respBody, &errRespBody)
+	p.logServerCall(parentReq, constant.ROUTER_PATH_OAUTH_RESULT, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -225,12 +592,99 @@ func (p *TraefikGithubOauthMiddleware) getAuthResult(rid string) (*model.Respons
 	return &respBody, nil
 }
 
+// setCorrelationHeaders sends the request id of the request Traefik is serving with the server call, and propagates its trace:
+// the span of Traefik is the parent of the one of the server. Without a valid traceparent the server starts a new trace.
+func setCorrelationHeaders(s *sling.Sling, parentReq *http.Request) {
+	s.Set(constant.HTTP_HEADER_X_REQUEST_ID, getRequestID(parentReq))
+	if parent, ok := tracing.ParseTraceparent(parentReq.Header.Get(constant.HTTP_HEADER_TRACEPARENT)); ok {
+		s.Set(constant.HTTP_HEADER_TRACEPARENT, parent.Traceparent())
+	}
+}
+
+// logServerCall logs a call to the server with its latency, parentReq is the request Traefik is serving, nil for the polling.
+func (p *TraefikGithubOauthMiddleware) logServerCall(parentReq *http.Request, route string, start time.Time, err error) {
+	var entry logEntry
+	if parentReq != nil {
+		entry = newRequestLogEntry(parentReq, nil, err)
+	} else if err != nil {
+		entry.Error = err.Error()
+	}
+	entry.ServerCall = route
+	entry.LatencyMs = millisecondsSince(start)
+	p.logger.Debug("server call", entry)
+}
+
+// getRequestID returns the request id ServeHTTP set on the request.
+func getRequestID(req *http.Request) string {
+	return req.Header.Get(constant.HTTP_HEADER_X_REQUEST_ID)
+}
+
+// httpError replies with the error and the request id, so a screenshot of the page is enough to find its logs.
+func httpError(rw http.ResponseWriter, req *http.Request, message string, code int) {
+	http.Error(rw, fmt.Sprintf("%s\n\nrequest id: %s", message, getRequestID(req)), code)
+}
+
 func (p *TraefikGithubOauthMiddleware) getGitHubUserFromCookie(req *http.Request) (*jwt.PayloadUser, error) {
-	jwtCookie, err := req.Cookie(constant.COOKIE_NAME_JWT)
+	tokenString, err := readChunkedCookie(req, constant.COOKIE_NAME_JWT)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseSessionToken(req, tokenString)
+}
+
+// sessionTokenAudience the audience of the session tokens of the middleware, the name of its OAuth client.
+func (p *TraefikGithubOauthMiddleware) sessionTokenAudience() string {
+	if len(p.client) == 0 {
+		return constant.OAUTH_CLIENT_NAME_DEFAULT
+	}
+	return p.client
+}
+
+// parseSessionToken parses a session token signed by the server, or by the middleware with the jwt secret key,
+// encrypted or not, and refuses it if it is revoked.
+func (p *TraefikGithubOauthMiddleware) parseSessionToken(req *http.Request, tokenString string) (*jwt.PayloadUser, error) {
+	user, err := jwt.ParseTokenStringWithKeys(
+		req.Context(), tokenString, p.jwtSecretKey, p.sessionKeys,
+		jwt.WithEncrypter(p.encrypter), jwt.WithAudience(p.sessionTokenAudience()),
+	)
 	if err != nil {
 		return nil, err
 	}
-	return jwt.ParseTokenString(jwtCookie.Value, p.jwtSecretKey)
+	provider := user.Provider
+	if len(provider) == 0 {
+		provider = DefaultProvider
+	}
+	// the roles are never read from the token, a role mapping of the middleware only applies to its own routes
+	user.Roles = p.roles.Roles(provider, user.Id, user.Login, user.Groups)
+	if err := p.revocations.check(provider, user.Id, user.TokenID, user.IssuedAt); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// getBearerUser returns the user of the session token in the Authorization header if bearerTokens is enabled.
+// It returns neither a user nor an error without a bearer token, and the error of a bearer token that is not a session
+// token, e.g. a token of the upstream, the request is then authenticated by its cookie and keeps the header.
+func (p *TraefikGithubOauthMiddleware) getBearerUser(req *http.Request) (*jwt.PayloadUser, error) {
+	if !p.bearerTokens {
+		return nil, nil
+	}
+	bearerToken, ok := getBearerToken(req)
+	if !ok {
+		return nil, nil
+	}
+	return p.parseSessionToken(req, bearerToken)
+}
+
+// getBearerToken returns the token of an "Authorization: Bearer <token>" header.
+func getBearerToken(req *http.Request) (string, bool) {
+	authorization := req.Header.Get(constant.HTTP_HEADER_AUTHORIZATION)
+	scheme, token, found := strings.Cut(authorization, " ")
+	if !found || !strings.EqualFold(scheme, constant.AUTHORIZATION_PREFIX_BEARER) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, 0 < len(token)
 }
 
 func (p *TraefikGithubOauthMiddleware) getAuthURL(originalReq *http.Request) string {
@@ -246,6 +700,38 @@ func (p *TraefikGithubOauthMiddleware) getAuthURL(originalReq *http.Request) str
 	return builder.String()
 }
 
+// audit writes an authorization audit event for the request.
+func (p *TraefikGithubOauthMiddleware) audit(req *http.Request, user *jwt.PayloadUser, decision, reason string) {
+	event := audit.Event{
+		Source:     audit.SOURCE_MIDDLEWARE,
+		Event:      audit.EVENT_AUTHORIZATION,
+		Decision:   decision,
+		Reason:     reason,
+		Host:       req.Host,
+		Path:       req.URL.Path,
+		ClientIP:   getClientIP(req),
+		Middleware: p.name,
+	}
+	if user != nil {
+		event.UserID = user.Id
+		event.UserLogin = user.Login
+		event.Provider = user.Provider
+	}
+	if p.logger.redact {
+		event.UserID = redactUser(event.UserID)
+		event.UserLogin = redactUser(event.UserLogin)
+		event.ClientIP = redactIP(event.ClientIP)
+	}
+	entry := newRequestLogEntry(req, user, nil)
+	entry.Decision = decision
+	entry.Reason = reason
+	p.logger.Debug("authorization", entry)
+	if err := p.auditLogger.Log(event); err != nil {
+		entry.Error = err.Error()
+		p.logger.Error("audit", entry)
+	}
+}
+
 func setNoCacheHeaders(rw http.ResponseWriter) {
 	rw.Header().Set(constant.HTTP_HEADER_CACHE_CONTROL, "no-cache, no-store, must-revalidate, private")
 	rw.Header().Set(constant.HTTP_HEADER_PRAGMA, "no-cache")
@@ -265,6 +751,18 @@ func getRawRequestUrl(originalReq *http.Request) string {
 	return builder.String()
 }
 
+// getClientIP returns the IP of the client, preferring the X-Real-Ip header set by Traefik.
+func getClientIP(req *http.Request) string {
+	if realIP := req.Header.Get(constant.HTTP_HEADER_X_REAL_IP); 0 < len(realIP) {
+		return realIP
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
 func getRandomString32() string {
 	randBytes := make([]byte, 16)
 	_, _ = rand.Read(randBytes)
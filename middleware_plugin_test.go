@@ -0,0 +1,95 @@
+package traefik_github_oauth_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/audit"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// testUpstream records the last request the middleware forwarded, and answers 404 like an upstream without the path.
+type testUpstream struct {
+	req *http.Request
+}
+
+func (u *testUpstream) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	u.req = req
+	http.NotFound(rw, req)
+}
+
+// newTestMiddleware creates the middleware of the config with the whitelisted login alice.
+func newTestMiddleware(t *testing.T, configure func(config *Config)) (*Config, http.Handler, *testUpstream) {
+	t.Helper()
+	config := CreateConfig()
+	config.ApiBaseUrl = "http://oauth.example.com"
+	config.Whitelist.Logins = []string{"alice"}
+	if configure != nil {
+		configure(config)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	upstream := &testUpstream{}
+	handler, err := New(ctx, upstream, config, "test")
+	assert.NoError(t, err)
+	return config, handler, upstream
+}
+
+// newTestRequest a request to the path, with the session cookie of the user if any.
+func newTestRequest(t *testing.T, config *Config, path string, user *jwt.PayloadUser) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://whoami.example.com"+path, nil)
+	if user != nil {
+		tokenString, err := jwt.GenerateUserJwtTokenString(user, config.JwtSecretKey)
+		assert.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: constant.COOKIE_NAME_JWT, Value: tokenString})
+	}
+	return req
+}
+
+func TestTraefikGithubOauthMiddleware_ServeHTTP_NotInWhitelist(t *testing.T) {
+	// setup
+	config, handler, upstream := newTestMiddleware(t, nil)
+	req := newTestRequest(t, config, "/", &jwt.PayloadUser{Id: "2", Login: "mallory"})
+	rw := httptest.NewRecorder()
+
+	// execution
+	handler.ServeHTTP(rw, req)
+
+	// assertion
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Nil(t, upstream.req)
+}
+
+func TestTraefikGithubOauthMiddleware_ServeHTTP_AuditRedacted(t *testing.T) {
+	// setup
+	auditFile := filepath.Join(t.TempDir(), "audit.log")
+	config, handler, upstream := newTestMiddleware(t, func(config *Config) {
+		config.LogRedact = true
+		config.AuditLog.FilePath = auditFile
+	})
+	req := newTestRequest(t, config, "/", &jwt.PayloadUser{Id: "12345", Login: "alice"})
+	req.RemoteAddr = "203.0.113.7:54321"
+	rw := httptest.NewRecorder()
+
+	// execution
+	handler.ServeHTTP(rw, req)
+
+	// assertion
+	assert.NotNil(t, upstream.req)
+	b, err := os.ReadFile(auditFile)
+	assert.NoError(t, err)
+	var event audit.Event
+	assert.NoError(t, json.Unmarshal(b, &event))
+	assert.Equal(t, audit.DECISION_ALLOWED, event.Decision)
+	assert.Equal(t, redactUser("12345"), event.UserID)
+	assert.Equal(t, redactUser("alice"), event.UserLogin)
+	assert.Equal(t, "203.0.113.0", event.ClientIP)
+}
@@ -0,0 +1,174 @@
+package traefik_github_oauth_plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/app/traefik-github-oauth-server/model"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/constant"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/revocation"
+	"github.com/dghubble/sling"
+)
+
+// revocationPollers the pollers of the revocation lists by their options,
+// shared by the middlewares of every router and config reload.
+var revocationPollers = struct {
+	mu      sync.Mutex
+	pollers map[revocationPollerOptions]*revocationPoller
+}{pollers: make(map[revocationPollerOptions]*revocationPoller)}
+
+// revocationPollerOptions the settings of a poller, the middlewares share a poller only if they have the same ones.
+type revocationPollerOptions struct {
+	apiBaseUrl   string
+	apiSecretKey string
+	tls          ConfigTls
+	interval     time.Duration
+	// maxStaleness how long the last list is trusted while the server cannot be reached, 0 for ever.
+	maxStaleness time.Duration
+}
+
+// revocationPoller polls the revocation list of a server until the last middleware using it releases it.
+type revocationPoller struct {
+	options    revocationPollerOptions
+	httpClient *http.Client
+	logger     *middlewareLogger
+	refs       int
+	stop       chan struct{}
+	now        func() time.Time
+
+	mu   sync.RWMutex
+	list *revocation.List
+	etag string
+	// fetchedAt the time of the last successful fetch, or of the start of the poller.
+	fetchedAt time.Time
+}
+
+// acquireRevocationPoller returns the poller of the options, starting it for the first middleware having them.
+func acquireRevocationPoller(options revocationPollerOptions, httpClient *http.Client, logger *middlewareLogger) *revocationPoller {
+	options.apiBaseUrl = strings.TrimSuffix(options.apiBaseUrl, "/")
+	revocationPollers.mu.Lock()
+	defer revocationPollers.mu.Unlock()
+	poller, found := revocationPollers.pollers[options]
+	if !found {
+		poller = newRevocationPoller(options, httpClient, logger)
+		revocationPollers.pollers[options] = poller
+		go poller.poll()
+	}
+	poller.refs++
+	return poller
+}
+
+func newRevocationPoller(options revocationPollerOptions, httpClient *http.Client, logger *middlewareLogger) *revocationPoller {
+	return &revocationPoller{
+		options:    options,
+		httpClient: httpClient,
+		logger:     logger,
+		stop:       make(chan struct{}),
+		now:        time.Now,
+		fetchedAt:  time.Now(),
+	}
+}
+
+// release releases the poller of a middleware, the last release stops it.
+func (rp *revocationPoller) release() {
+	revocationPollers.mu.Lock()
+	defer revocationPollers.mu.Unlock()
+	rp.refs--
+	if rp.refs == 0 {
+		delete(revocationPollers.pollers, rp.options)
+		close(rp.stop)
+	}
+}
+
+// check returns ErrSessionRevoked if the session is in the last fetched list,
+// and ErrRevocationListStale for every session once the list is older than the max staleness.
+// A nil poller revokes nothing.
+func (rp *revocationPoller) check(provider, userID, jti string, issuedAt time.Time) error {
+	if rp == nil {
+		return nil
+	}
+	rp.mu.RLock()
+	defer rp.mu.RUnlock()
+	if rp.isStale() {
+		return ErrRevocationListStale
+	}
+	if rp.list.IsRevoked(provider, userID, jti, issuedAt) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// isStale reports whether the last successful fetch is older than the max staleness, rp.mu must be held.
+func (rp *revocationPoller) isStale() bool {
+	return 0 < rp.options.maxStaleness && rp.options.maxStaleness < rp.now().Sub(rp.fetchedAt)
+}
+
+// poll fetches the revocation list of the server every interval until the poller is stopped.
+// A failed poll keeps the last list until it is older than the max staleness, the sessions are refused then.
+func (rp *revocationPoller) poll() {
+	ticker := time.NewTicker(rp.options.interval)
+	defer ticker.Stop()
+	for {
+		if err := rp.fetch(); err != nil {
+			rp.mu.RLock()
+			stale := rp.isStale()
+			rp.mu.RUnlock()
+			if stale {
+				rp.logger.Error("revocationPoller: fetch, the revocation list is stale, every session is refused", logEntry{Error: err.Error()})
+			} else {
+				rp.logger.Warning("revocationPoller: fetch", logEntry{Error: err.Error()})
+			}
+		}
+		select {
+		case <-rp.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetch fetches the revocation list, unless it did not change since the last fetch.
+func (rp *revocationPoller) fetch() error {
+	req := sling.New().Client(rp.httpClient).Base(rp.options.apiBaseUrl).Get(constant.ROUTER_GROUP_PATH_OAUTH + "/" + constant.ROUTER_PATH_REVOCATIONS)
+	if 0 < len(rp.options.apiSecretKey) {
+		req.Set(constant.HTTP_HEADER_AUTHORIZATION, fmt.Sprintf("%s %s", constant.AUTHORIZATION_PREFIX_TOKEN, rp.options.apiSecretKey))
+	}
+	rp.mu.RLock()
+	etag := rp.etag
+	rp.mu.RUnlock()
+	if 0 < len(etag) {
+		req.Set(constant.HTTP_HEADER_IF_NONE_MATCH, etag)
+	}
+
+	var respBody revocation.List
+	var errRespBody model.ResponseError
+	start := time.Now()
+	resp, err := req.Receive(&respBody, &errRespBody)
+	entry := logEntry{ServerCall: constant.ROUTER_PATH_REVOCATIONS, LatencyMs: millisecondsSince(start)}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	rp.logger.Debug("server call", entry)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		rp.mu.Lock()
+		rp.fetchedAt = rp.now()
+		rp.mu.Unlock()
+		return nil
+	case http.StatusOK:
+		rp.mu.Lock()
+		rp.list = &respBody
+		rp.etag = resp.Header.Get(constant.HTTP_HEADER_ETAG)
+		rp.fetchedAt = rp.now()
+		rp.mu.Unlock()
+		return nil
+	default:
+		return fmt.Errorf("rpc failed, status: %s, message: %s", resp.Status, errRespBody.Message)
+	}
+}
@@ -0,0 +1,105 @@
+package traefik_github_oauth_plugin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/revocation"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRevocationPoller a poller of the server which is not started, with a manual clock.
+func newTestRevocationPoller(t *testing.T, apiBaseUrl string, maxStaleness time.Duration, now *time.Time) *revocationPoller {
+	t.Helper()
+	logger, err := newMiddlewareLogger("test", CreateConfig(), io.Discard)
+	assert.NoError(t, err)
+	rp := newRevocationPoller(revocationPollerOptions{
+		apiBaseUrl:   apiBaseUrl,
+		interval:     time.Minute,
+		maxStaleness: maxStaleness,
+	}, http.DefaultClient, logger)
+	rp.now = func() time.Time { return *now }
+	rp.fetchedAt = *now
+	return rp
+}
+
+func TestRevocationPoller_Check_Stale(t *testing.T) {
+	// setup
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !available {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(rw).Encode(revocation.List{Tokens: map[string]int64{"revoked": 0}})
+	}))
+	defer server.Close()
+	now := time.Unix(1700000000, 0)
+	rp := newTestRevocationPoller(t, server.URL, 5*time.Minute, &now)
+
+	// execution & assertion
+	assert.NoError(t, rp.fetch())
+	assert.ErrorIs(t, rp.check(DefaultProvider, "1", "revoked", now), ErrSessionRevoked)
+	assert.NoError(t, rp.check(DefaultProvider, "1", "other", now))
+	// the last list is kept while the server cannot be reached
+	available = false
+	now = now.Add(5 * time.Minute)
+	assert.Error(t, rp.fetch())
+	assert.ErrorIs(t, rp.check(DefaultProvider, "1", "revoked", now), ErrSessionRevoked)
+	assert.NoError(t, rp.check(DefaultProvider, "1", "other", now))
+	// every session is refused once the list is older than the max staleness
+	now = now.Add(time.Second)
+	assert.ErrorIs(t, rp.check(DefaultProvider, "1", "other", now), ErrRevocationListStale)
+	// until a fetch succeeds again
+	available = true
+	assert.NoError(t, rp.fetch())
+	assert.NoError(t, rp.check(DefaultProvider, "1", "other", now))
+}
+
+func TestRevocationPoller_Check_NoMaxStaleness(t *testing.T) {
+	// setup
+	now := time.Unix(1700000000, 0)
+	rp := newTestRevocationPoller(t, "http://oauth.example.com", 0, &now)
+
+	// execution
+	now = now.Add(24 * time.Hour)
+
+	// assertion
+	assert.NoError(t, rp.check(DefaultProvider, "1", "other", now))
+}
+
+func TestAcquireRevocationPoller_Options(t *testing.T) {
+	// setup
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+	logger, err := newMiddlewareLogger("test", CreateConfig(), io.Discard)
+	assert.NoError(t, err)
+	options := revocationPollerOptions{apiBaseUrl: server.URL, interval: time.Minute}
+	otherInterval := options
+	otherInterval.interval = time.Hour
+	otherSecret := options
+	otherSecret.apiSecretKey = "secret"
+	trailingSlash := options
+	trailingSlash.apiBaseUrl += "/"
+
+	// execution
+	poller := acquireRevocationPoller(options, http.DefaultClient, logger)
+	samePoller := acquireRevocationPoller(trailingSlash, http.DefaultClient, logger)
+	intervalPoller := acquireRevocationPoller(otherInterval, http.DefaultClient, logger)
+	secretPoller := acquireRevocationPoller(otherSecret, http.DefaultClient, logger)
+	for _, rp := range []*revocationPoller{poller, samePoller, intervalPoller, secretPoller} {
+		rp.release()
+	}
+
+	// assertion
+	assert.Same(t, poller, samePoller)
+	assert.NotSame(t, poller, intervalPoller)
+	assert.NotSame(t, poller, secretPoller)
+	assert.NotContains(t, revocationPollers.pollers, options)
+}
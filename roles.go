@@ -0,0 +1,77 @@
+package traefik_github_oauth_plugin
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/roles"
+)
+
+// ConfigRole the middleware configuration of the members of a role.
+type ConfigRole struct {
+	// Users the user id or login list.
+	Users []string `json:"users,omitempty"`
+	// Teams the org/team slug list.
+	Teams []string `json:"teams,omitempty"`
+	// Orgs the org list, every member of the org has the role.
+	Orgs []string `json:"orgs,omitempty"`
+}
+
+// ConfigRequiredRole the middleware configuration of the roles required by the paths under a prefix.
+type ConfigRequiredRole struct {
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// Roles the user must have one of them.
+	Roles []string `json:"roles,omitempty"`
+}
+
+func newRoleMapping(config map[string]ConfigRole) roles.Mapping {
+	if len(config) == 0 {
+		return nil
+	}
+	mapping := make(roles.Mapping, len(config))
+	for role, members := range config {
+		mapping[role] = roles.Members{
+			Users: members.Users,
+			Teams: members.Teams,
+			Orgs:  members.Orgs,
+		}
+	}
+	return mapping
+}
+
+// newRequiredRoles returns the required roles by decreasing path prefix length, the longest matching prefix applies.
+func newRequiredRoles(config []ConfigRequiredRole) []ConfigRequiredRole {
+	requiredRoles := append([]ConfigRequiredRole{}, config...)
+	sort.SliceStable(requiredRoles, func(i, j int) bool {
+		return len(requiredRoles[j].PathPrefix) < len(requiredRoles[i].PathPrefix)
+	})
+	return requiredRoles
+}
+
+// requiredRolesOf returns the required roles of the longest path prefix of the path, nil if any user may access it.
+func (p *TraefikGithubOauthMiddleware) requiredRolesOf(path string) *ConfigRequiredRole {
+	for i := range p.requiredRoles {
+		if hasPathPrefix(path, p.requiredRoles[i].PathPrefix) {
+			return &p.requiredRoles[i]
+		}
+	}
+	return nil
+}
+
+// hasPathPrefix reports whether the path is under the prefix on a segment boundary, /admin matches /admin/users but not /administrator.
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// forwardRoles sets the roles of the user on the roles header forwarded upstream, comma separated.
+func (p *TraefikGithubOauthMiddleware) forwardRoles(req *http.Request, user *jwt.PayloadUser) {
+	if len(p.rolesHeader) == 0 || len(user.Roles) == 0 {
+		return
+	}
+	req.Header.Set(p.rolesHeader, strings.Join(user.Roles, ","))
+}
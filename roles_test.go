@@ -0,0 +1,93 @@
+package traefik_github_oauth_plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequiredRolesOf(t *testing.T) {
+	// setup
+	p := &TraefikGithubOauthMiddleware{
+		requiredRoles: newRequiredRoles([]ConfigRequiredRole{
+			{PathPrefix: "/admin", Roles: []string{"admin"}},
+			{PathPrefix: "/admin/reports", Roles: []string{"viewer"}},
+			{PathPrefix: "/api/", Roles: []string{"api"}},
+		}),
+	}
+	tests := []struct {
+		path  string
+		roles []string
+	}{
+		{path: "/", roles: nil},
+		{path: "/admin", roles: []string{"admin"}},
+		{path: "/admin/users", roles: []string{"admin"}},
+		{path: "/admin/reports", roles: []string{"viewer"}},
+		{path: "/admin/reports/2023", roles: []string{"viewer"}},
+		{path: "/admin/reportsarchive", roles: []string{"admin"}},
+		{path: "/administrator", roles: nil},
+		{path: "/api/users", roles: []string{"api"}},
+		{path: "/api", roles: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// execution
+			requiredRoles := p.requiredRolesOf(tt.path)
+
+			// assertion
+			if tt.roles == nil {
+				assert.Nil(t, requiredRoles)
+			} else if assert.NotNil(t, requiredRoles) {
+				assert.Equal(t, tt.roles, requiredRoles.Roles)
+			}
+		})
+	}
+}
+
+func TestTraefikGithubOauthMiddleware_ServeHTTP_Roles(t *testing.T) {
+	// setup
+	config, handler, upstream := newTestMiddleware(t, func(config *Config) {
+		config.Whitelist.Logins = append(config.Whitelist.Logins, "bob")
+		config.RolesHeader = "X-Forwarded-Roles"
+		config.Roles = map[string]ConfigRole{
+			"admin":  {Users: []string{"alice"}},
+			"viewer": {Orgs: []string{"acme"}},
+		}
+		config.RequiredRoles = []ConfigRequiredRole{{PathPrefix: "/admin", Roles: []string{"admin"}}}
+	})
+	tests := []struct {
+		name  string
+		user  *jwt.PayloadUser
+		path  string
+		code  int
+		roles string
+	}{
+		{name: "roles", user: testUser, path: "/admin/users", code: http.StatusNotFound, roles: "admin,viewer"},
+		{name: "no roles", user: &jwt.PayloadUser{Id: "3", Login: "bob"}, path: "/", code: http.StatusNotFound, roles: ""},
+		{name: "missing role", user: &jwt.PayloadUser{Id: "3", Login: "bob"}, path: "/admin", code: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			upstream.req = nil
+			req := newTestRequest(t, config, tt.path, tt.user)
+			// the client cannot grant itself roles
+			req.Header.Set("X-Forwarded-Roles", "admin")
+			rw := httptest.NewRecorder()
+
+			// execution
+			handler.ServeHTTP(rw, req)
+
+			// assertion
+			assert.Equal(t, tt.code, rw.Code)
+			if tt.code == http.StatusForbidden {
+				assert.Nil(t, upstream.req)
+				return
+			}
+			assert.Equal(t, tt.roles, upstream.req.Header.Get("X-Forwarded-Roles"))
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package traefik_github_oauth_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+)
+
+const (
+	// userInfoPath and debugPath the identity endpoints, under the auth path.
+	userInfoPath = "/userinfo"
+	debugPath    = "/debug"
+	// queryKeyPath the path the user is authorized for by the identity endpoints, defaults to /.
+	queryKeyPath = "path"
+)
+
+// userInfo the identity of the user of a session and its authorization.
+type userInfo struct {
+	Id       string   `json:"id"`
+	Login    string   `json:"login"`
+	Provider string   `json:"provider"`
+	Groups   []string `json:"groups,omitempty"`
+	Roles    []string `json:"roles,omitempty"`
+	// TokenID the jti of the session token, the admin api revokes the session by it.
+	TokenID   string     `json:"token_id,omitempty"`
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	Authorization *authorization `json:"authorization"`
+}
+
+// debugInfo explains the authorization of the request, without a session or when the user is denied.
+type debugInfo struct {
+	Path        string    `json:"path"`
+	Explanation string    `json:"explanation,omitempty"`
+	User        *userInfo `json:"user,omitempty"`
+	RequestID   string    `json:"request_id"`
+}
+
+type userInfoError struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+// handleUserInfoRequest answers the identity of the user of the session, for the frontends behind the middleware.
+// A user the path query parameter is denied to gets 403 Forbidden, like any other request.
+func (p *TraefikGithubOauthMiddleware) handleUserInfoRequest(rw http.ResponseWriter, req *http.Request) {
+	setNoCacheHeaders(rw)
+	user, err := p.getSessionUser(req)
+	if err != nil {
+		p.logger.Debug("handleUserInfoRequest: getSessionUser", newRequestLogEntry(req, nil, err))
+		writeJSON(rw, http.StatusUnauthorized, userInfoError{Error: err.Error(), RequestID: getRequestID(req)})
+		return
+	}
+	authz := p.authorizeUser(user, getInfoPath(req))
+	if !authz.Allowed {
+		writeJSON(rw, http.StatusForbidden, userInfoError{Error: authz.reason, RequestID: getRequestID(req)})
+		return
+	}
+	writeJSON(rw, http.StatusOK, newUserInfo(user, authz))
+}
+
+// handleDebugRequest explains why the request is allowed or denied for the path query parameter,
+// including the rules of the middleware, so it is only served with the debug endpoint enabled.
+func (p *TraefikGithubOauthMiddleware) handleDebugRequest(rw http.ResponseWriter, req *http.Request) {
+	setNoCacheHeaders(rw)
+	info := debugInfo{
+		Path:      getInfoPath(req),
+		RequestID: getRequestID(req),
+	}
+	user, err := p.getSessionUser(req)
+	if err != nil {
+		info.Explanation = "no valid session: " + err.Error()
+	} else {
+		info.User = newUserInfo(user, p.authorizeUser(user, info.Path))
+	}
+	writeJSON(rw, http.StatusOK, info)
+}
+
+// getSessionUser returns the user of the bearer token or, if it is not a session token, of the session cookie of the request.
+func (p *TraefikGithubOauthMiddleware) getSessionUser(req *http.Request) (*jwt.PayloadUser, error) {
+	user, bearerErr := p.getBearerUser(req)
+	if user != nil {
+		return user, nil
+	}
+	user, err := p.getGitHubUserFromCookie(req)
+	if err != nil && bearerErr != nil {
+		return nil, bearerErr
+	}
+	return user, err
+}
+
+func newUserInfo(user *jwt.PayloadUser, authz *authorization) *userInfo {
+	info := &userInfo{
+		Id:            user.Id,
+		Login:         user.Login,
+		Provider:      user.Provider,
+		Groups:        user.Groups,
+		Roles:         user.Roles,
+		TokenID:       user.TokenID,
+		Authorization: authz,
+	}
+	if len(info.Provider) == 0 {
+		info.Provider = DefaultProvider
+	}
+	if !user.IssuedAt.IsZero() {
+		info.IssuedAt = &user.IssuedAt
+	}
+	if !user.ExpiresAt.IsZero() {
+		info.ExpiresAt = &user.ExpiresAt
+	}
+	return info
+}
+
+func getInfoPath(req *http.Request) string {
+	path := req.URL.Query().Get(queryKeyPath)
+	if len(path) == 0 {
+		return "/"
+	}
+	return path
+}
+
+func writeJSON(rw http.ResponseWriter, code int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_ = json.NewEncoder(rw).Encode(v)
+}
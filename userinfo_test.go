@@ -0,0 +1,104 @@
+package traefik_github_oauth_plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MuXiu1997/traefik-github-oauth-plugin/internal/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+var testUser = &jwt.PayloadUser{Id: "1", Login: "alice", Groups: []string{"acme"}}
+
+func TestHandleUserInfoRequest(t *testing.T) {
+	// setup
+	config, handler, upstream := newTestMiddleware(t, nil)
+	req := newTestRequest(t, config, "/_auth/userinfo", testUser)
+	rw := httptest.NewRecorder()
+
+	// execution
+	handler.ServeHTTP(rw, req)
+
+	// assertion
+	var info userInfo
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &info))
+	assert.Equal(t, "alice", info.Login)
+	assert.Equal(t, DefaultProvider, info.Provider)
+	assert.True(t, info.Authorization.Allowed)
+	assert.Nil(t, upstream.req)
+}
+
+func TestHandleUserInfoRequest_NoSession(t *testing.T) {
+	// setup
+	config, handler, _ := newTestMiddleware(t, nil)
+	req := newTestRequest(t, config, "/_auth/userinfo", nil)
+	rw := httptest.NewRecorder()
+
+	// execution
+	handler.ServeHTTP(rw, req)
+
+	// assertion
+	var infoErr userInfoError
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &infoErr))
+	assert.NotEmpty(t, infoErr.Error)
+	assert.NotEmpty(t, infoErr.RequestID)
+}
+
+func TestHandleUserInfoRequest_DeniedPath(t *testing.T) {
+	// setup
+	config, handler, _ := newTestMiddleware(t, func(config *Config) {
+		config.RequiredRoles = []ConfigRequiredRole{{PathPrefix: "/admin", Roles: []string{"admin"}}}
+	})
+	req := newTestRequest(t, config, "/_auth/userinfo?path=/admin/users", testUser)
+	rw := httptest.NewRecorder()
+
+	// execution
+	handler.ServeHTTP(rw, req)
+
+	// assertion
+	var infoErr userInfoError
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &infoErr))
+	assert.Equal(t, "missing role: admin", infoErr.Error)
+}
+
+func TestHandleDebugRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		debugEndpoint bool
+		code          int
+		forwarded     bool
+	}{
+		{name: "enabled", debugEndpoint: true, code: http.StatusOK},
+		{name: "disabled", debugEndpoint: false, code: http.StatusNotFound, forwarded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			config, handler, upstream := newTestMiddleware(t, func(config *Config) {
+				config.DebugEndpoint = tt.debugEndpoint
+				config.RequiredRoles = []ConfigRequiredRole{{PathPrefix: "/admin", Roles: []string{"admin"}}}
+			})
+			req := newTestRequest(t, config, "/_auth/debug?path=/admin", testUser)
+			rw := httptest.NewRecorder()
+
+			// execution
+			handler.ServeHTTP(rw, req)
+
+			// assertion
+			assert.Equal(t, tt.code, rw.Code)
+			assert.Equal(t, tt.forwarded, upstream.req != nil)
+			if tt.debugEndpoint {
+				var info debugInfo
+				assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &info))
+				assert.Equal(t, "/admin", info.Path)
+				assert.False(t, info.User.Authorization.Allowed)
+				assert.Contains(t, info.User.Authorization.Explanation, "requires one of the roles admin")
+			}
+		})
+	}
+}